@@ -0,0 +1,216 @@
+package analyzer
+
+import "time"
+
+// RemovedCallsReport is the content of removed_calls.json written by CreateJsonFile
+type RemovedCallsReport struct {
+	RemovedPerFunction map[string][]string `json:"removedPerFunction"`
+	UniqueRemovedCalls []string            `json:"uniqueRemovedCalls"`
+}
+
+// Data source kinds reported in DataSource.Kind
+const (
+	SourceScan           = "scan"             // analyzed from source
+	SourceSnapshotBinary = "functionmap.bin"  // loaded from the binary snapshot
+	SourceSnapshotJSON   = "functionmap.json" // loaded from the JSON snapshot
+	SourceSnapshotFile   = "snapshot-file"    // snapshot files served without a source tree (read-only)
+	SourceRefCache       = "ref-cache"        // a git ref's commit analyzed earlier, from the commit cache
+)
+
+// DataSource describes where the loaded relations came from
+type DataSource struct {
+	Kind        string     `json:"kind"`                  // SourceScan, SourceSnapshotBinary, SourceSnapshotJSON, SourceSnapshotFile or SourceRefCache
+	Files       []string   `json:"files,omitempty"`       // the files served (SourceSnapshotFile only)
+	Ref         string     `json:"ref,omitempty"`         // git ref analyzed instead of the working tree
+	Commit      string     `json:"commit,omitempty"`      // commit Ref resolved to
+	Fresh       bool       `json:"fresh"`                 // matched the source tree when loaded (scans always do)
+	Reason      string     `json:"reason,omitempty"`      // why snapshots were skipped, or why a stale one is served
+	GeneratedAt *time.Time `json:"generatedAt,omitempty"` // when the relations were analyzed, if the snapshot records it
+}
+
+// RelationsResponse is the body of GET /api/relations
+type RelationsResponse struct {
+	Page             int            `json:"page"`
+	PageSize         int            `json:"pageSize"`
+	Kinds            []string       `json:"kinds,omitempty"` // root kinds asked for; all when omitted
+	TotalRoots       int            `json:"totalRoots"`      // roots of the kinds asked for
+	KindCounts       map[string]int `json:"kindCounts"`      // roots of each kind, before filtering
+	Roots            []OutRelation  `json:"roots"`           // by kind (main, init, handler, test, api, unknown), then name
+	RootKinds        []string       `json:"rootKinds"`       // kind of each root, in the same order
+	Data             []OutRelation  `json:"data"`
+	MaxDepth         int            `json:"maxDepth,omitempty"` // levels of callees below each root, when limited
+	MaxNodes         int            `json:"maxNodes,omitempty"` // cap on the closure's size, when limited
+	Truncated        []string       `json:"truncated"`          // name@filePath of nodes in data with callees left out; GET /api/expand?id= loads them
+	LoadedAt         time.Time      `json:"loadedAt"`
+	ContentHash      string         `json:"contentHash"`
+	Source           DataSource     `json:"source"`
+	IncludeInternals bool           `json:"includeInternals"`
+}
+
+// SearchResponse is the body of GET /api/search
+type SearchResponse struct {
+	Query             string        `json:"query"`
+	Page              int           `json:"page"`
+	PageSize          int           `json:"pageSize"`
+	TotalResults      int           `json:"totalResults"`
+	MatchingFunctions []OutRelation `json:"matchingFunctions"`
+	Data              []OutRelation `json:"data"`
+	MaxDepth          int           `json:"maxDepth,omitempty"` // levels of callees below each match, when limited
+	MaxNodes          int           `json:"maxNodes,omitempty"` // cap on the closure's size, when limited
+	Truncated         []string      `json:"truncated"`          // name@filePath of nodes in data with callees left out; GET /api/expand?id= loads them
+	LoadedAt          time.Time     `json:"loadedAt"`
+	ContentHash       string        `json:"contentHash"`
+	Source            DataSource    `json:"source"`
+}
+
+// ExpandResponse is the body of GET /api/expand: one more level below a truncated node
+type ExpandResponse struct {
+	Function         OutRelation   `json:"function"`
+	Data             []OutRelation `json:"data"`      // the function and its callees
+	Truncated        []string      `json:"truncated"` // name@filePath of nodes in data with callees left out
+	LoadedAt         time.Time     `json:"loadedAt"`
+	ContentHash      string        `json:"contentHash"`
+	Source           DataSource    `json:"source"`
+	IncludeInternals bool          `json:"includeInternals"`
+}
+
+// CallersResponse is the body of GET /api/callers: the caller tree of one function
+type CallersResponse struct {
+	Function         OutRelation   `json:"function"`     // the function asked for; without calls of its own it has no called list
+	Depth            int           `json:"depth"`        // levels of callers walked; 0 walks up to the roots
+	Page             int           `json:"page"`         // page of the direct callers
+	PageSize         int           `json:"pageSize"`     // direct callers per page
+	TotalCallers     int           `json:"totalCallers"` // direct callers, internal helpers included
+	Callers          []OutRelation `json:"callers"`      // direct callers on this page
+	Data             []OutRelation `json:"data"`         // every function reaching a caller on the page within depth, callers included; called lists give the tree's edges
+	Roots            []OutRelation `json:"roots"`        // entry points (uncalled, non-internal) in data
+	LoadedAt         time.Time     `json:"loadedAt"`
+	ContentHash      string        `json:"contentHash"`
+	Source           DataSource    `json:"source"`
+	IncludeInternals bool          `json:"includeInternals"`
+}
+
+// NeighborhoodResponse is the body of GET /api/neighborhood: the ego graph of one function
+type NeighborhoodResponse struct {
+	Function         OutRelation        `json:"function"`
+	Up               int                `json:"up"`   // caller hops walked
+	Down             int                `json:"down"` // callee hops walked
+	Nodes            []NeighborhoodNode `json:"nodes"`
+	Edges            []NeighborhoodEdge `json:"edges"`
+	LoadedAt         time.Time          `json:"loadedAt"`
+	ContentHash      string             `json:"contentHash"`
+	Source           DataSource         `json:"source"`
+	IncludeInternals bool               `json:"includeInternals"`
+}
+
+// Reload job states
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// ReloadJob is the body of POST /api/reload and GET /api/jobs/{id}: one asynchronous rescan
+type ReloadJob struct {
+	ID          string      `json:"id"`
+	Status      string      `json:"status"`           // JobQueued, JobRunning, JobSucceeded, JobFailed or JobCancelled
+	Reason      string      `json:"reason"`           // "reload", "watch", "ref" (POST /api/ref), "initial" (first load of a repository added by -config or POST /api/repos) or "analyze" (a commit GET /api/diff needs)
+	Commit      string      `json:"commit,omitempty"` // commit an "analyze" job analyzes; it is not served
+	Phase       string      `json:"phase,omitempty"`  // current analysis phase (PhaseWalking ... PhaseBuildingRelations) while running
+	Files       []string    `json:"files,omitempty"`  // changed paths that triggered a watch reload, relative to the repository root
+	FilesTotal  int         `json:"filesTotal"`       // Go files found so far
+	FilesParsed int         `json:"filesParsed"`      // files parsed or restored from the analysis cache
+	Requests    int         `json:"requests"`         // reload requests coalesced into this job
+	CreatedAt   time.Time   `json:"createdAt"`
+	StartedAt   *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time  `json:"finishedAt,omitempty"`
+	ElapsedMs   int64       `json:"elapsedMs"` // running time so far, or total once finished
+	Error       string      `json:"error,omitempty"`
+	ContentHash string      `json:"contentHash,omitempty"` // hash of the loaded relations once succeeded
+	LoadedAt    *time.Time  `json:"loadedAt,omitempty"`
+	Source      *DataSource `json:"source,omitempty"` // where the loaded relations came from once succeeded
+}
+
+// JobsResponse is the body of GET /api/jobs
+type JobsResponse struct {
+	Jobs []ReloadJob `json:"jobs"` // newest first
+}
+
+// ChangeEvent is the data of a "change" event on GET /api/events, sent whenever a reload changes
+// the loaded relations
+type ChangeEvent struct {
+	Reason      string    `json:"reason"`          // "watch" for file-watch reloads, "reload" for POST /api/reload, "ref" for POST /api/ref, "initial" for a hosted repository's first load, "connected" for the initial "ready" event
+	Files       []string  `json:"files,omitempty"` // changed paths relative to the repository root ("watch" only; "." means unknown)
+	ContentHash string    `json:"contentHash"`
+	LoadedAt    time.Time `json:"loadedAt"`
+}
+
+// RepoSpec configures one repository hosted by the server: an entry of the -config file and the body of
+// POST /api/repos. Exactly one of Path and SnapshotFiles is set; omitted analysis settings default to
+// the server's command-line flags.
+type RepoSpec struct {
+	ID              string       `json:"id"`                      // URL segment of /api/repos/{id}: letters, digits, '.', '_' and '-'
+	Path            string       `json:"path,omitempty"`          // repository root to analyze
+	SnapshotFiles   []string     `json:"snapshotFiles,omitempty"` // relation files served read-only instead of a source tree
+	IncludeExternal bool         `json:"includeExternal,omitempty"`
+	SkipFolders     []string     `json:"skipFolders,omitempty"`
+	Snapshot        string       `json:"snapshot,omitempty"` // "prefer", "ignore" or "only", as the -snapshot flag
+	Watch           bool         `json:"watch,omitempty"`
+	Ref             string       `json:"ref,omitempty"`         // git commit, branch or tag to analyze instead of the working tree
+	EntryPoints     *EntryPoints `json:"entryPoints,omitempty"` // how roots are found and classified (default: every kind, nothing internal)
+}
+
+// ReposConfig is the content of the server's -config file
+type ReposConfig struct {
+	Repos []RepoSpec `json:"repos"` // the first one is also served by the unprefixed /api routes
+}
+
+// Repository states reported in RepoInfo.Status
+const (
+	RepoLoading = "loading" // the first load has not finished yet
+	RepoReady   = "ready"   // relations are being served
+	RepoFailed  = "failed"  // the first load failed; Error says why
+)
+
+// RepoInfo describes one hosted repository: an entry of GET /api/repos and the body of
+// GET, POST and DELETE /api/repos/{id} and POST /api/analyze
+type RepoInfo struct {
+	ID            string         `json:"id"`
+	Path          string         `json:"path,omitempty"`
+	SnapshotFiles []string       `json:"snapshotFiles,omitempty"`
+	Default       bool           `json:"default"`  // also served by the unprefixed /api routes
+	ReadOnly      bool           `json:"readOnly"` // no source tree: reload and jobs are disabled
+	Uploaded      bool           `json:"uploaded"` // analyzed from an archive sent to POST /api/analyze
+	Watch         bool           `json:"watch"`
+	Ref           string         `json:"ref,omitempty"`   // selected git ref; "" serves the working tree
+	Status        string         `json:"status"`          // RepoLoading, RepoReady or RepoFailed
+	Error         string         `json:"error,omitempty"` // why the latest load failed
+	ContentHash   string         `json:"contentHash,omitempty"`
+	LoadedAt      *time.Time     `json:"loadedAt,omitempty"`
+	Source        *DataSource    `json:"source,omitempty"`
+	Stats         *SnapshotStats `json:"stats,omitempty"`
+}
+
+// ReposResponse is the body of GET /api/repos
+type ReposResponse struct {
+	Repos []RepoInfo `json:"repos"` // in registration order
+}
+
+// RefsResponse is the body of GET /api/refs
+type RefsResponse struct {
+	Ref    string   `json:"ref,omitempty"`    // selected git ref; "" serves the working tree
+	Commit string   `json:"commit,omitempty"` // commit of the loaded relations when they came from a ref
+	Refs   []GitRef `json:"refs"`             // branches, then tags
+}
+
+// RefRequest is the body of POST /api/ref
+type RefRequest struct {
+	Ref string `json:"ref"` // commit, branch or tag; "" switches back to the working tree
+}
+
+// ErrorResponse is the body of every API error
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
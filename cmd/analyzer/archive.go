@@ -0,0 +1,178 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MaxArchiveEntries caps the files and directories one archive may unpack
+const MaxArchiveEntries = 100000
+
+// ErrArchiveTooLarge is returned when an archive unpacks beyond its size limit or MaxArchiveEntries
+var ErrArchiveTooLarge = errors.New("archive unpacks beyond the size limit")
+
+// ExtractArchive extracts a zip or tar.gz file, detected from its first bytes, into dir. Entries that
+// would land outside dir are rejected and links and special files are skipped. The unpacked files may
+// total at most limit bytes (no limit when limit <= 0) and MaxArchiveEntries entries.
+func ExtractArchive(archive, dir string, limit int64) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return errors.New("archive is not a zip or tar.gz file")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	u := newUnpacker(dir, limit)
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		stat, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return u.zip(f, stat.Size())
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("invalid tar.gz archive: %w", err)
+		}
+		defer gz.Close()
+		return u.tar(gz)
+	default:
+		return errors.New("archive is not a zip or tar.gz file")
+	}
+}
+
+// ExtractTar extracts an uncompressed tar stream into dir, with the same safeguards as ExtractArchive
+func ExtractTar(r io.Reader, dir string, limit int64) error {
+	u := newUnpacker(dir, limit)
+	return u.tar(r)
+}
+
+// unpacker writes archive entries below dir, keeping count of the space and entries left
+type unpacker struct {
+	dir       string
+	remaining int64
+	entries   int
+}
+
+func newUnpacker(dir string, limit int64) *unpacker {
+	if limit <= 0 {
+		limit = math.MaxInt64 - 1
+	}
+	return &unpacker{dir: dir, remaining: limit}
+}
+
+func (u *unpacker) zip(r io.ReaderAt, size int64) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	for _, f := range archive.File {
+		mode := f.Mode()
+		if mode.IsDir() {
+			if err := u.mkdir(f.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		err = u.file(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unpacker) tar(r io.Reader) error {
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = u.mkdir(header.Name)
+		case tar.TypeReg:
+			err = u.file(header.Name, archive)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// target maps an entry name to its path below u.dir, rejecting absolute names and ".." escapes
+func (u *unpacker) target(name string) (string, error) {
+	u.entries++
+	if u.entries > MaxArchiveEntries {
+		return "", fmt.Errorf("%w: more than %d entries", ErrArchiveTooLarge, MaxArchiveEntries)
+	}
+	clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(clean) != "" {
+		return "", fmt.Errorf("archive entry %q escapes the archive root", name)
+	}
+	return filepath.Join(u.dir, filepath.FromSlash(clean)), nil
+}
+
+func (u *unpacker) mkdir(name string) error {
+	target, err := u.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0o755)
+}
+
+func (u *unpacker) file(name string, content io.Reader) error {
+	target, err := u.target(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	// Read one byte past the budget to tell "exactly full" from "too large"
+	n, err := io.Copy(f, io.LimitReader(content, u.remaining+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if n > u.remaining {
+		return ErrArchiveTooLarge
+	}
+	u.remaining -= n
+	return nil
+}
@@ -0,0 +1,183 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type archiveEntry struct {
+	name, body, link string
+	dir              bool
+}
+
+func writeTarGz(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.dir:
+			header.Typeflag, header.Mode, header.Size = tar.TypeDir, 0o755, 0
+		case e.link != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, e.link, 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte(e.body))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	path := filepath.Join(t.TempDir(), "upload.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeZip(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		body := e.body
+		switch {
+		case e.dir:
+			header.Name += "/"
+			header.SetMode(os.ModeDir | 0o755)
+		case e.link != "":
+			header.SetMode(os.ModeSymlink | 0o777)
+			body = e.link
+		default:
+			header.SetMode(0o644)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	zw.Close()
+	path := filepath.Join(t.TempDir(), "upload.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		limit   int64
+		wantErr string // substring; "" for success
+		want    map[string]string
+		absent  []string
+	}{
+		{
+			name:    "module",
+			entries: []archiveEntry{{name: "mod", dir: true}, {name: "mod/go.mod", body: "module x\n"}, {name: "mod/a/a.go", body: "package a\n"}},
+			want:    map[string]string{"mod/go.mod": "module x\n", "mod/a/a.go": "package a\n"},
+		},
+		{
+			name:    "parent traversal",
+			entries: []archiveEntry{{name: "../evil.go", body: "x"}},
+			wantErr: "escapes the archive root",
+		},
+		{
+			name:    "nested traversal",
+			entries: []archiveEntry{{name: "a/../../evil.go", body: "x"}},
+			wantErr: "escapes the archive root",
+		},
+		{
+			name:    "absolute path",
+			entries: []archiveEntry{{name: "/etc/evil.go", body: "x"}},
+			wantErr: "escapes the archive root",
+		},
+		{
+			name:    "backslash traversal",
+			entries: []archiveEntry{{name: `..\evil.go`, body: "x"}},
+			wantErr: "escapes the archive root",
+		},
+		{
+			name:    "symlinks are skipped",
+			entries: []archiveEntry{{name: "link", link: "/etc"}, {name: "link/passwd", body: "x"}, {name: "go.mod", body: "module x\n"}},
+			want:    map[string]string{"go.mod": "module x\n", "link/passwd": "x"},
+		},
+		{
+			name:    "size limit",
+			entries: []archiveEntry{{name: "a.go", body: "12345"}, {name: "b.go", body: "67890"}},
+			limit:   8,
+			wantErr: ErrArchiveTooLarge.Error(),
+		},
+		{
+			name:    "exactly at the limit",
+			entries: []archiveEntry{{name: "a.go", body: "1234"}, {name: "b.go", body: "5678"}},
+			limit:   8,
+			want:    map[string]string{"a.go": "1234", "b.go": "5678"},
+		},
+	}
+	for _, format := range []struct {
+		name  string
+		write func(*testing.T, []archiveEntry) string
+	}{{"tar.gz", writeTarGz}, {"zip", writeZip}} {
+		for _, tt := range tests {
+			t.Run(format.name+"/"+tt.name, func(t *testing.T) {
+				dir := filepath.Join(t.TempDir(), "src")
+				err := ExtractArchive(format.write(t, tt.entries), dir, tt.limit)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("ExtractArchive() error = %v, want %q", err, tt.wantErr)
+					}
+					if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil.go")); err == nil {
+						t.Fatal("entry written outside the extraction directory")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ExtractArchive() error = %v", err)
+				}
+				for name, body := range tt.want {
+					got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+					if err != nil || string(got) != body {
+						t.Errorf("%s = %q, %v; want %q", name, got, err, body)
+					}
+				}
+				// Nothing extracted may be a link, so no later write can follow one out of dir
+				filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+					if err == nil && info.Mode()&os.ModeSymlink != 0 {
+						t.Errorf("%s is a symlink", path)
+					}
+					return nil
+				})
+			})
+		}
+	}
+}
+
+func TestExtractArchiveSizeLimitError(t *testing.T) {
+	path := writeTarGz(t, []archiveEntry{{name: "a.go", body: strings.Repeat("x", 100)}})
+	if err := ExtractArchive(path, t.TempDir(), 10); !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("ExtractArchive() error = %v, want ErrArchiveTooLarge", err)
+	}
+}
+
+func TestExtractArchiveRejectsOtherFormats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain.txt")
+	os.WriteFile(path, []byte("not an archive"), 0o644)
+	if err := ExtractArchive(path, t.TempDir(), 0); err == nil {
+		t.Fatal("ExtractArchive() accepted a plain file")
+	}
+}
@@ -0,0 +1,359 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// BinarySnapshotVersion is the layout version of functionmap.bin. Version 1 stored uncalled functions as
+// roots and version 2 no roots; version 3 stores the roots a RootClassifier found, with their kinds.
+const BinarySnapshotVersion = 3
+
+// BinarySnapshotFile is the file name the CLI writes and the server prefers when it matches the tree
+const BinarySnapshotFile = "functionmap.bin"
+
+const binarySnapshotMagic = "GMMSNAP\x00"
+
+// ErrCorruptSnapshot is returned when a binary snapshot fails its magic or checksum
+var ErrCorruptSnapshot = errors.New("corrupt binary snapshot")
+
+// Layout (all integers are uvarints):
+//
+//	magic "GMMSNAP\0" | version | header length | header JSON
+//	string count | (length, bytes)...                    interned names and file paths
+//	relation count | node count | (name id, file id, line)...
+//	per relation: out-degree | target node ids...          adjacency lists
+//	classifier string id | root count | (relation index, kind string id)...
+//	SHA-256 of everything above (32 bytes)
+//
+// Nodes [0, relation count) are the relations in canonical order, so a node id doubles as the index
+// position; further nodes are call targets that have no relation of their own. The classifier is the
+// Digest of the RootClassifier that found the roots, "" when the snapshot has none.
+
+// WriteBinarySnapshot encodes snapshot and its prebuilt roots, if any, in the compact binary layout
+func WriteBinarySnapshot(w io.Writer, snapshot Snapshot) error {
+	headerData, err := json.Marshal(snapshot.Header)
+	if err != nil {
+		return err
+	}
+
+	// Intern strings and assign node ids
+	strs := newStringTable()
+	type node struct{ name, file, line int }
+	type nodeKey struct {
+		name, file string
+		line       int
+	}
+	nodeIDs := make(map[nodeKey]int, len(snapshot.Relations))
+	var nodes []node
+	addNode := func(name, file string, line int) int {
+		key := nodeKey{name, file, line}
+		if id, ok := nodeIDs[key]; ok {
+			return id
+		}
+		id := len(nodes)
+		nodeIDs[key] = id
+		nodes = append(nodes, node{strs.id(name), strs.id(file), line})
+		return id
+	}
+	for _, r := range snapshot.Relations {
+		addNode(r.Name, r.FilePath, r.Line)
+	}
+	adjacency := make([][]int, len(snapshot.Relations))
+	for i, r := range snapshot.Relations {
+		targets := make([]int, len(r.Called))
+		for j, c := range r.Called {
+			targets[j] = addNode(c.Name, c.FilePath, c.Line)
+		}
+		adjacency[i] = targets
+	}
+	var roots SnapshotRoots
+	if snapshot.Roots != nil {
+		roots = *snapshot.Roots
+	}
+	classifier := strs.id(roots.Classifier)
+	kinds := make([]int, len(roots.Roots))
+	for i, root := range roots.Roots {
+		if int(root.ID) >= len(snapshot.Relations) {
+			return fmt.Errorf("root %d is not a relation", root.ID)
+		}
+		kinds[i] = strs.id(root.Kind)
+	}
+
+	h := sha256.New()
+	bw := bufio.NewWriterSize(io.MultiWriter(w, h), 64*1024)
+	enc := uvarintWriter{w: bw}
+
+	bw.WriteString(binarySnapshotMagic)
+	enc.put(BinarySnapshotVersion)
+	enc.put(len(headerData))
+	bw.Write(headerData)
+
+	enc.put(len(strs.values))
+	for _, s := range strs.values {
+		enc.put(len(s))
+		bw.WriteString(s)
+	}
+
+	enc.put(len(snapshot.Relations))
+	enc.put(len(nodes))
+	for _, n := range nodes {
+		enc.put(n.name)
+		enc.put(n.file)
+		enc.put(n.line)
+	}
+	for _, targets := range adjacency {
+		enc.put(len(targets))
+		for _, t := range targets {
+			enc.put(t)
+		}
+	}
+
+	enc.put(classifier)
+	enc.put(len(roots.Roots))
+	for i, root := range roots.Roots {
+		enc.put(int(root.ID))
+		enc.put(kinds[i])
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	_, err = w.Write(h.Sum(nil))
+	return err
+}
+
+// ReadBinarySnapshot decodes a binary snapshot, with its prebuilt roots, and verifies its checksum. Names and file paths are
+// shared between relations and their call entries, so the decoded graph holds each string once. The
+// input is read whole and checked before anything is decoded, and every count is bounded by the bytes
+// left, so corrupt or crafted input fails with ErrCorruptSnapshot instead of exhausting memory.
+func ReadBinarySnapshot(r io.Reader) (Snapshot, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(data) < len(binarySnapshotMagic)+sha256.Size || string(data[:len(binarySnapshotMagic)]) != binarySnapshotMagic {
+		return Snapshot{}, fmt.Errorf("%w: bad magic", ErrCorruptSnapshot)
+	}
+	body, sum := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	dec := &binaryDecoder{data: body, pos: len(binarySnapshotMagic)}
+	if version := dec.get(); version != BinarySnapshotVersion {
+		if dec.err != nil {
+			return Snapshot{}, dec.err
+		}
+		return Snapshot{}, fmt.Errorf("%w: binary layout version %d, supported %d", ErrIncompatibleSnapshot, version, BinarySnapshotVersion)
+	}
+	if want := sha256.Sum256(body); !bytes.Equal(sum, want[:]) {
+		return Snapshot{}, fmt.Errorf("%w: checksum mismatch", ErrCorruptSnapshot)
+	}
+
+	var snap Snapshot
+	headerData := dec.bytes(dec.get())
+	if dec.err != nil {
+		return Snapshot{}, dec.err
+	}
+	if err := json.Unmarshal(headerData, &snap.Header); err != nil {
+		return Snapshot{}, fmt.Errorf("%w: header: %v", ErrCorruptSnapshot, err)
+	}
+	if err := checkSchemaVersion(snap.Header); err != nil {
+		return Snapshot{}, err
+	}
+
+	// Each string takes at least its length byte, each node three bytes
+	strs := make([]string, dec.count(1))
+	for i := range strs {
+		strs[i] = string(dec.bytes(dec.get()))
+	}
+	str := func(id int) string {
+		if id < 0 || id >= len(strs) {
+			dec.fail()
+			return ""
+		}
+		return strs[id]
+	}
+
+	relationCount := dec.get()
+	nodes := make([]OutCalled, dec.count(3))
+	if dec.err == nil && relationCount > len(nodes) {
+		return Snapshot{}, fmt.Errorf("%w: %d relations but %d nodes", ErrCorruptSnapshot, relationCount, len(nodes))
+	}
+	for i := range nodes {
+		nodes[i] = OutCalled{Name: str(dec.get()), FilePath: str(dec.get()), Line: dec.get()}
+	}
+	if dec.err != nil {
+		return Snapshot{}, dec.err
+	}
+
+	snap.Relations = make([]OutRelation, relationCount)
+	var edges []OutCalled // one backing array for every call list
+	offsets := make([]int, relationCount+1)
+	for i := 0; i < relationCount && dec.err == nil; i++ {
+		degree := dec.count(1)
+		for j := 0; j < degree && dec.err == nil; j++ {
+			target := dec.get()
+			if target < 0 || target >= len(nodes) {
+				dec.fail()
+				break
+			}
+			edges = append(edges, nodes[target])
+		}
+		offsets[i+1] = len(edges)
+	}
+	if dec.err != nil {
+		return Snapshot{}, dec.err
+	}
+	for i := range snap.Relations {
+		n := nodes[i]
+		snap.Relations[i] = OutRelation{Name: n.Name, Line: n.Line, FilePath: n.FilePath}
+		if offsets[i+1] > offsets[i] {
+			snap.Relations[i].Called = edges[offsets[i]:offsets[i+1]:offsets[i+1]]
+		}
+	}
+
+	classifier := str(dec.get())
+	roots := make([]Root, dec.count(2))
+	for i := range roots {
+		roots[i] = Root{ID: NodeID(dec.get()), Kind: str(dec.get())}
+		if int(roots[i].ID) >= relationCount {
+			dec.fail()
+		}
+	}
+	if dec.err != nil {
+		return Snapshot{}, dec.err
+	}
+	if classifier != "" {
+		snap.Roots = &SnapshotRoots{Classifier: classifier, Roots: roots}
+	}
+
+	if dec.pos != len(dec.data) {
+		return Snapshot{}, fmt.Errorf("%w: %d trailing bytes", ErrCorruptSnapshot, len(dec.data)-dec.pos)
+	}
+	return snap, nil
+}
+
+// SaveBinarySnapshot writes snapshot to path
+func SaveBinarySnapshot(path string, snapshot Snapshot) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteBinarySnapshot(f, snapshot); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadBinarySnapshot reads a binary snapshot from path
+func LoadBinarySnapshot(path string) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+	return ReadBinarySnapshot(f)
+}
+
+// LoadSnapshotFile reads a relation file of any supported format: a .bin binary snapshot, or a
+// functionmap.json document or bare relation array
+func LoadSnapshotFile(path string) (Snapshot, error) {
+	if strings.HasSuffix(path, ".bin") {
+		return LoadBinarySnapshot(path)
+	}
+	return LoadSnapshot(path)
+}
+
+type stringTable struct {
+	ids    map[string]int
+	values []string
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{ids: make(map[string]int)}
+}
+
+func (t *stringTable) id(s string) int {
+	if id, ok := t.ids[s]; ok {
+		return id
+	}
+	id := len(t.values)
+	t.ids[s] = id
+	t.values = append(t.values, s)
+	return id
+}
+
+type uvarintWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (e *uvarintWriter) put(v int) {
+	n := binary.PutUvarint(e.buf[:], uint64(v))
+	e.w.Write(e.buf[:n])
+}
+
+// binaryDecoder decodes uvarints and byte runs from an in-memory snapshot. The first error is sticky so
+// callers can decode a whole section and check err once.
+type binaryDecoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (d *binaryDecoder) get() int {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.err = fmt.Errorf("%w: truncated or overlong varint at offset %d", ErrCorruptSnapshot, d.pos)
+		return 0
+	}
+	d.pos += n
+	if v > math.MaxInt32 {
+		// No count, index or line of a valid snapshot comes close
+		d.fail()
+		return 0
+	}
+	return int(v)
+}
+
+// count decodes the length of a section whose elements take at least size bytes each, failing when the
+// bytes left could not hold that many
+func (d *binaryDecoder) count(size int) int {
+	n := d.get()
+	if d.err == nil && n > (len(d.data)-d.pos)/size {
+		d.err = fmt.Errorf("%w: count %d exceeds the %d bytes left", ErrCorruptSnapshot, n, len(d.data)-d.pos)
+		return 0
+	}
+	return n
+}
+
+// bytes returns the next n bytes, shared with the input
+func (d *binaryDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data)-d.pos {
+		d.err = fmt.Errorf("%w: %d bytes wanted at offset %d, %d left", ErrCorruptSnapshot, n, d.pos, len(d.data)-d.pos)
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *binaryDecoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: value out of range", ErrCorruptSnapshot)
+	}
+}
@@ -0,0 +1,157 @@
+package analyzer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testSnapshot() Snapshot {
+	relations := []OutRelation{
+		{Name: "main.main", Line: 10, FilePath: "cmd/main.go", Called: []OutCalled{
+			{Name: "svc.Run", Line: 3, FilePath: "svc/svc.go"},
+			{Name: "fmt.Println", Line: 0, FilePath: "external"},
+		}},
+		{Name: "svc.Run", Line: 3, FilePath: "svc/svc.go", Called: []OutCalled{
+			{Name: "svc.helper", Line: 20, FilePath: "svc/svc.go"},
+		}},
+		{Name: "svc.helper", Line: 20, FilePath: "svc/svc.go", Called: []OutCalled{
+			{Name: "svc.Run", Line: 3, FilePath: "svc/svc.go"},
+		}},
+	}
+	SortRelations(relations)
+	header := SnapshotHeader{
+		SchemaVersion:   SchemaVersion,
+		Module:          "example.com/m",
+		AnalyzerVersion: Version,
+		Run:             &SnapshotRun{Toolchain: "go1.23.0", GeneratedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Stats:           ComputeStats(relations, 3),
+	}
+	roots := &SnapshotRoots{Classifier: "digest", Roots: []Root{{ID: 0, Kind: RootMain}, {ID: 2, Kind: RootUnknown}}}
+	return Snapshot{Header: header, Relations: relations, Roots: roots}
+}
+
+func encodeSnapshot(t *testing.T, snapshot Snapshot) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteBinarySnapshot(&buf, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBinarySnapshotRoundTrip(t *testing.T) {
+	want := testSnapshot()
+	got, err := ReadBinarySnapshot(bytes.NewReader(encodeSnapshot(t, want)))
+	if err != nil {
+		t.Fatalf("ReadBinarySnapshot() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Relations, want.Relations) {
+		t.Errorf("relations = %+v, want %+v", got.Relations, want.Relations)
+	}
+	if !reflect.DeepEqual(got.Header, want.Header) {
+		t.Errorf("header = %+v, want %+v", got.Header, want.Header)
+	}
+	if !reflect.DeepEqual(got.Roots, want.Roots) {
+		t.Errorf("roots = %+v, want %+v", got.Roots, want.Roots)
+	}
+}
+
+func TestBinarySnapshotRootsNeedRelations(t *testing.T) {
+	snapshot := testSnapshot()
+	snapshot.Roots.Roots = append(snapshot.Roots.Roots, Root{ID: NodeID(len(snapshot.Relations)), Kind: RootAPI})
+	if err := WriteBinarySnapshot(&bytes.Buffer{}, snapshot); err == nil {
+		t.Error("WriteBinarySnapshot accepted a root that is not a relation")
+	}
+}
+
+func TestBinarySnapshotEmpty(t *testing.T) {
+	want := Snapshot{Header: SnapshotHeader{SchemaVersion: SchemaVersion}}
+	got, err := ReadBinarySnapshot(bytes.NewReader(encodeSnapshot(t, want)))
+	if err != nil {
+		t.Fatalf("ReadBinarySnapshot() error = %v", err)
+	}
+	if len(got.Relations) != 0 || got.Roots != nil {
+		t.Errorf("relations = %+v, roots = %+v, want none", got.Relations, got.Roots)
+	}
+}
+
+func TestBinarySnapshotTruncated(t *testing.T) {
+	data := encodeSnapshot(t, testSnapshot())
+	for n := 0; n < len(data); n++ {
+		if _, err := ReadBinarySnapshot(bytes.NewReader(data[:n])); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Fatalf("%d of %d bytes: error = %v, want ErrCorruptSnapshot", n, len(data), err)
+		}
+	}
+}
+
+func TestBinarySnapshotCorrupt(t *testing.T) {
+	data := encodeSnapshot(t, testSnapshot())
+	for i := range data {
+		corrupt := bytes.Clone(data)
+		corrupt[i] ^= 0x40
+		_, err := ReadBinarySnapshot(bytes.NewReader(corrupt))
+		if !errors.Is(err, ErrCorruptSnapshot) && !errors.Is(err, ErrIncompatibleSnapshot) {
+			t.Fatalf("byte %d flipped: error = %v, want ErrCorruptSnapshot", i, err)
+		}
+	}
+}
+
+// resealed builds a file from raw body bytes after the magic, with a valid checksum, so the decoder's
+// bounds are reached instead of the checksum test
+func resealed(parts ...[]byte) []byte {
+	body := []byte(binarySnapshotMagic)
+	for _, p := range parts {
+		body = append(body, p...)
+	}
+	sum := sha256.Sum256(body)
+	return append(body, sum[:]...)
+}
+
+func uvarint(v uint64) []byte {
+	return binary.AppendUvarint(nil, v)
+}
+
+func TestBinarySnapshotHugeCounts(t *testing.T) {
+	header := []byte(`{"schemaVersion":2}`)
+	prefix := [][]byte{uvarint(BinarySnapshotVersion), uvarint(uint64(len(header))), header}
+	tests := []struct {
+		name  string
+		parts [][]byte
+	}{
+		{"string count", [][]byte{uvarint(1 << 30)}},
+		{"string length", [][]byte{uvarint(1), uvarint(1 << 30)}},
+		{"node count", [][]byte{uvarint(0), uvarint(0), uvarint(1 << 30)}},
+		{"relation count", [][]byte{uvarint(0), uvarint(1 << 30), uvarint(0)}},
+		{"out-degree", [][]byte{uvarint(1), uvarint(1), []byte("a"), uvarint(1), uvarint(1), uvarint(0), uvarint(0), uvarint(1), uvarint(1 << 30)}},
+		{"header length", nil},
+		{"root count", [][]byte{uvarint(1), uvarint(0), uvarint(0), uvarint(0), uvarint(0), uvarint(1 << 30)}},
+		{"root index", [][]byte{uvarint(1), uvarint(1), []byte("a"), uvarint(1), uvarint(1), uvarint(0), uvarint(0), uvarint(0), uvarint(0), uvarint(0), uvarint(1), uvarint(1), uvarint(0)}},
+		{"root kind", [][]byte{uvarint(1), uvarint(1), []byte("a"), uvarint(1), uvarint(1), uvarint(0), uvarint(0), uvarint(0), uvarint(0), uvarint(0), uvarint(1), uvarint(0), uvarint(1)}},
+		{"varint overflow", [][]byte{bytes.Repeat([]byte{0xff}, 11)}},
+		{"trailing bytes", [][]byte{uvarint(1), uvarint(0), uvarint(0), uvarint(0), uvarint(0), uvarint(0), []byte("junk")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := append(append([][]byte{}, prefix...), tt.parts...)
+			if tt.parts == nil {
+				parts = [][]byte{uvarint(BinarySnapshotVersion), uvarint(1 << 40)}
+			}
+			_, err := ReadBinarySnapshot(bytes.NewReader(resealed(parts...)))
+			if !errors.Is(err, ErrCorruptSnapshot) {
+				t.Fatalf("error = %v, want ErrCorruptSnapshot", err)
+			}
+		})
+	}
+}
+
+func TestBinarySnapshotVersion(t *testing.T) {
+	data := resealed(uvarint(BinarySnapshotVersion + 1))
+	if _, err := ReadBinarySnapshot(bytes.NewReader(data)); !errors.Is(err, ErrIncompatibleSnapshot) {
+		t.Fatalf("error = %v, want ErrIncompatibleSnapshot", err)
+	}
+}
@@ -0,0 +1,322 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+)
+
+// GraphDiff is the structural difference between two relation sets: the output of the diff command and
+// the body of GET /api/diff. Functions are matched by name; a function whose name survives in the same
+// directory (package) but whose file or line changed is reported as moved rather than as removed and
+// added. Edges are compared by
+// caller and callee name, so moving a function does not change its edges.
+type GraphDiff struct {
+	From             DiffSide        `json:"from"`
+	To               DiffSide        `json:"to"`
+	Summary          DiffSummary     `json:"summary"`
+	AddedFunctions   []DiffFunction  `json:"addedFunctions"`
+	RemovedFunctions []DiffFunction  `json:"removedFunctions"`
+	MovedFunctions   []MovedFunction `json:"movedFunctions"`
+	AddedEdges       []DiffEdge      `json:"addedEdges"`
+	RemovedEdges     []DiffEdge      `json:"removedEdges"`
+	AddedRoots       []string        `json:"addedRoots"`   // entry points only in To
+	RemovedRoots     []string        `json:"removedRoots"` // entry points only in From
+}
+
+// DiffSide describes one of the compared relation sets
+type DiffSide struct {
+	Label       string `json:"label"` // what was compared: a file, a git ref or "current"
+	Commit      string `json:"commit,omitempty"`
+	ContentHash string `json:"contentHash"`
+	Functions   int    `json:"functions"` // distinct functions in relations and calls
+	Edges       int    `json:"edges"`     // distinct caller-callee name pairs
+	Roots       int    `json:"roots"`
+}
+
+// DiffSummary counts the entries of each GraphDiff list
+type DiffSummary struct {
+	AddedFunctions   int `json:"addedFunctions"`
+	RemovedFunctions int `json:"removedFunctions"`
+	MovedFunctions   int `json:"movedFunctions"`
+	AddedEdges       int `json:"addedEdges"`
+	RemovedEdges     int `json:"removedEdges"`
+	AddedRoots       int `json:"addedRoots"`
+	RemovedRoots     int `json:"removedRoots"`
+}
+
+// DiffFunction is a function that exists on one side only
+type DiffFunction struct {
+	Name     string `json:"name"`
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+}
+
+// MovedFunction is a function found on both sides at different locations
+type MovedFunction struct {
+	Name        string           `json:"name"`
+	From        FunctionLocation `json:"from"`
+	To          FunctionLocation `json:"to"`
+	FileChanged bool             `json:"fileChanged"` // moved to another file of the same directory, not just to another line
+}
+
+// DiffEdge is a call from Caller to Callee, by function name
+type DiffEdge struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+}
+
+// DiffSnapshots compares two snapshots; see DiffGraphs. The snapshots' relations must be in canonical
+// order and have their strings interned in place by NewGraph.
+func DiffSnapshots(from, to Snapshot) GraphDiff {
+	return DiffGraphs(NewGraph(from.Relations, nil), NewGraph(to.Relations, nil))
+}
+
+// DiffGraphs compares two call graphs. Lists are sorted by name (edges by caller, then callee) so equal
+// inputs always produce the same output. The sides' labels are left for the caller to fill in.
+func DiffGraphs(from, to *Graph) GraphDiff {
+	d := GraphDiff{
+		AddedFunctions:   []DiffFunction{},
+		RemovedFunctions: []DiffFunction{},
+		MovedFunctions:   []MovedFunction{},
+		AddedEdges:       []DiffEdge{},
+		RemovedEdges:     []DiffEdge{},
+		AddedRoots:       []string{},
+		RemovedRoots:     []string{},
+	}
+
+	fromFuncs, toFuncs := graphFunctions(from), graphFunctions(to)
+	for _, name := range unionKeys(fromFuncs, toFuncs) {
+		added, removed, moved := matchLocations(name, fromFuncs[name], toFuncs[name])
+		d.AddedFunctions = append(d.AddedFunctions, added...)
+		d.RemovedFunctions = append(d.RemovedFunctions, removed...)
+		d.MovedFunctions = append(d.MovedFunctions, moved...)
+	}
+
+	fromEdges, toEdges := graphEdges(from), graphEdges(to)
+	for edge := range toEdges {
+		if !fromEdges[edge] {
+			d.AddedEdges = append(d.AddedEdges, edge)
+		}
+	}
+	for edge := range fromEdges {
+		if !toEdges[edge] {
+			d.RemovedEdges = append(d.RemovedEdges, edge)
+		}
+	}
+	sortEdges(d.AddedEdges)
+	sortEdges(d.RemovedEdges)
+
+	fromRoots, toRoots := rootNames(from), rootNames(to)
+	for name := range toRoots {
+		if !fromRoots[name] {
+			d.AddedRoots = append(d.AddedRoots, name)
+		}
+	}
+	for name := range fromRoots {
+		if !toRoots[name] {
+			d.RemovedRoots = append(d.RemovedRoots, name)
+		}
+	}
+	sort.Strings(d.AddedRoots)
+	sort.Strings(d.RemovedRoots)
+
+	d.From = DiffSide{Functions: countLocations(fromFuncs), Edges: len(fromEdges), Roots: len(fromRoots)}
+	d.To = DiffSide{Functions: countLocations(toFuncs), Edges: len(toEdges), Roots: len(toRoots)}
+	d.Summary = DiffSummary{
+		AddedFunctions:   len(d.AddedFunctions),
+		RemovedFunctions: len(d.RemovedFunctions),
+		MovedFunctions:   len(d.MovedFunctions),
+		AddedEdges:       len(d.AddedEdges),
+		RemovedEdges:     len(d.RemovedEdges),
+		AddedRoots:       len(d.AddedRoots),
+		RemovedRoots:     len(d.RemovedRoots),
+	}
+	return d
+}
+
+// Empty reports whether the two sides have the same structure
+func (d GraphDiff) Empty() bool {
+	return d.Summary == DiffSummary{}
+}
+
+// WriteSummary writes the diff in human-readable form: counts first, then every change
+func (d GraphDiff) WriteSummary(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	side := func(s DiffSide) string {
+		if s.Commit != "" && s.Commit != s.Label {
+			return fmt.Sprintf("%s (%.12s)", s.Label, s.Commit)
+		}
+		return s.Label
+	}
+	fmt.Fprintf(out, "Comparing %s -> %s\n", side(d.From), side(d.To))
+	fmt.Fprintf(out, "  functions: %d -> %d (+%d -%d, %d moved)\n", d.From.Functions, d.To.Functions, d.Summary.AddedFunctions, d.Summary.RemovedFunctions, d.Summary.MovedFunctions)
+	fmt.Fprintf(out, "  edges:     %d -> %d (+%d -%d)\n", d.From.Edges, d.To.Edges, d.Summary.AddedEdges, d.Summary.RemovedEdges)
+	fmt.Fprintf(out, "  roots:     %d -> %d (+%d -%d)\n", d.From.Roots, d.To.Roots, d.Summary.AddedRoots, d.Summary.RemovedRoots)
+	if d.Empty() {
+		fmt.Fprintln(out, "No structural changes")
+		return out.Flush()
+	}
+
+	section := func(title string, n int) bool {
+		if n == 0 {
+			return false
+		}
+		fmt.Fprintf(out, "\n%s (%d):\n", title, n)
+		return true
+	}
+	if section("Added functions", len(d.AddedFunctions)) {
+		for _, f := range d.AddedFunctions {
+			fmt.Fprintf(out, "  + %s  %s:%d\n", f.Name, f.FilePath, f.Line)
+		}
+	}
+	if section("Removed functions", len(d.RemovedFunctions)) {
+		for _, f := range d.RemovedFunctions {
+			fmt.Fprintf(out, "  - %s  %s:%d\n", f.Name, f.FilePath, f.Line)
+		}
+	}
+	if section("Moved functions", len(d.MovedFunctions)) {
+		for _, f := range d.MovedFunctions {
+			fmt.Fprintf(out, "  ~ %s  %s:%d -> %s:%d\n", f.Name, f.From.FilePath, f.From.Line, f.To.FilePath, f.To.Line)
+		}
+	}
+	if section("Added calls", len(d.AddedEdges)) {
+		for _, e := range d.AddedEdges {
+			fmt.Fprintf(out, "  + %s -> %s\n", e.Caller, e.Callee)
+		}
+	}
+	if section("Removed calls", len(d.RemovedEdges)) {
+		for _, e := range d.RemovedEdges {
+			fmt.Fprintf(out, "  - %s -> %s\n", e.Caller, e.Callee)
+		}
+	}
+	if section("New roots", len(d.AddedRoots)) {
+		for _, name := range d.AddedRoots {
+			fmt.Fprintf(out, "  + %s\n", name)
+		}
+	}
+	if section("Removed roots", len(d.RemovedRoots)) {
+		for _, name := range d.RemovedRoots {
+			fmt.Fprintf(out, "  - %s\n", name)
+		}
+	}
+	return out.Flush()
+}
+
+// graphFunctions collects the distinct locations of every function name among the graph's relations and
+// the calls they make, each list sorted by file and line
+func graphFunctions(g *Graph) map[string][]FunctionLocation {
+	seen := make(map[DiffFunction]bool)
+	functions := make(map[string][]FunctionLocation)
+	add := func(name, filePath string, line int) {
+		key := DiffFunction{Name: name, FilePath: filePath, Line: line}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		functions[name] = append(functions[name], FunctionLocation{FilePath: filePath, Line: line})
+	}
+	for _, r := range g.Relations {
+		add(r.Name, r.FilePath, r.Line)
+		for _, c := range r.Called {
+			add(c.Name, c.FilePath, c.Line)
+		}
+	}
+	for _, locations := range functions {
+		sort.Slice(locations, func(i, j int) bool {
+			if locations[i].FilePath != locations[j].FilePath {
+				return locations[i].FilePath < locations[j].FilePath
+			}
+			return locations[i].Line < locations[j].Line
+		})
+	}
+	return functions
+}
+
+// matchLocations pairs the locations of one name on both sides: identical locations first, then
+// locations in the same file (moved within it), then locations in the same directory (moved to another
+// file of the package). Same-named functions of other directories belong to other packages and are
+// never paired; whatever is left over was added or removed.
+func matchLocations(name string, from, to []FunctionLocation) (added, removed []DiffFunction, moved []MovedFunction) {
+	fromLeft := append([]FunctionLocation(nil), from...)
+	toLeft := append([]FunctionLocation(nil), to...)
+	pair := func(match func(a, b FunctionLocation) bool, record func(a, b FunctionLocation)) {
+		for i := 0; i < len(fromLeft); i++ {
+			for j := 0; j < len(toLeft); j++ {
+				if match(fromLeft[i], toLeft[j]) {
+					record(fromLeft[i], toLeft[j])
+					fromLeft = append(fromLeft[:i], fromLeft[i+1:]...)
+					toLeft = append(toLeft[:j], toLeft[j+1:]...)
+					i--
+					break
+				}
+			}
+		}
+	}
+	pair(func(a, b FunctionLocation) bool { return a == b }, func(a, b FunctionLocation) {})
+	pair(func(a, b FunctionLocation) bool { return a.FilePath == b.FilePath }, func(a, b FunctionLocation) {
+		moved = append(moved, MovedFunction{Name: name, From: a, To: b})
+	})
+	pair(func(a, b FunctionLocation) bool { return path.Dir(a.FilePath) == path.Dir(b.FilePath) }, func(a, b FunctionLocation) {
+		moved = append(moved, MovedFunction{Name: name, From: a, To: b, FileChanged: true})
+	})
+	for _, l := range toLeft {
+		added = append(added, DiffFunction{Name: name, FilePath: l.FilePath, Line: l.Line})
+	}
+	for _, l := range fromLeft {
+		removed = append(removed, DiffFunction{Name: name, FilePath: l.FilePath, Line: l.Line})
+	}
+	return added, removed, moved
+}
+
+// graphEdges returns the distinct caller-callee name pairs of the graph's relations
+func graphEdges(g *Graph) map[DiffEdge]bool {
+	edges := make(map[DiffEdge]bool)
+	for _, r := range g.Relations {
+		for _, c := range r.Called {
+			edges[DiffEdge{Caller: r.Name, Callee: c.Name}] = true
+		}
+	}
+	return edges
+}
+
+func rootNames(g *Graph) map[string]bool {
+	roots := make(map[string]bool)
+	for _, id := range g.Roots() {
+		roots[g.Relations[id].Name] = true
+	}
+	return roots
+}
+
+func unionKeys(a, b map[string][]FunctionLocation) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func countLocations(functions map[string][]FunctionLocation) int {
+	n := 0
+	for _, locations := range functions {
+		n += len(locations)
+	}
+	return n
+}
+
+func sortEdges(edges []DiffEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Caller != edges[j].Caller {
+			return edges[i].Caller < edges[j].Caller
+		}
+		return edges[i].Callee < edges[j].Callee
+	})
+}
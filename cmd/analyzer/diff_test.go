@@ -0,0 +1,123 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchLocations(t *testing.T) {
+	loc := func(file string, line int) FunctionLocation { return FunctionLocation{FilePath: file, Line: line} }
+	fn := func(file string, line int) DiffFunction {
+		return DiffFunction{Name: "h.Handle", FilePath: file, Line: line}
+	}
+	tests := []struct {
+		name     string
+		from, to []FunctionLocation
+		added    []DiffFunction
+		removed  []DiffFunction
+		moved    []MovedFunction
+	}{
+		{
+			name: "unchanged",
+			from: []FunctionLocation{loc("h/a.go", 3)},
+			to:   []FunctionLocation{loc("h/a.go", 3)},
+		},
+		{
+			name:  "moved within a file",
+			from:  []FunctionLocation{loc("h/a.go", 3)},
+			to:    []FunctionLocation{loc("h/a.go", 9)},
+			moved: []MovedFunction{{Name: "h.Handle", From: loc("h/a.go", 3), To: loc("h/a.go", 9)}},
+		},
+		{
+			name:  "moved to another file of the package",
+			from:  []FunctionLocation{loc("h/a.go", 3)},
+			to:    []FunctionLocation{loc("h/b.go", 5)},
+			moved: []MovedFunction{{Name: "h.Handle", From: loc("h/a.go", 3), To: loc("h/b.go", 5), FileChanged: true}},
+		},
+		{
+			name:    "same name in another directory",
+			from:    []FunctionLocation{loc("api/h/a.go", 3)},
+			to:      []FunctionLocation{loc("web/h/a.go", 3)},
+			added:   []DiffFunction{fn("web/h/a.go", 3)},
+			removed: []DiffFunction{fn("api/h/a.go", 3)},
+		},
+		{
+			name:    "identical locations are paired first",
+			from:    []FunctionLocation{loc("api/h/a.go", 3), loc("web/h/a.go", 3)},
+			to:      []FunctionLocation{loc("web/h/a.go", 3), loc("web/h/a.go", 20)},
+			added:   []DiffFunction{fn("web/h/a.go", 20)},
+			removed: []DiffFunction{fn("api/h/a.go", 3)},
+		},
+		{
+			name: "same file before same directory",
+			from: []FunctionLocation{loc("h/a.go", 3), loc("h/b.go", 3)},
+			to:   []FunctionLocation{loc("h/b.go", 8), loc("h/c.go", 1)},
+			moved: []MovedFunction{
+				{Name: "h.Handle", From: loc("h/b.go", 3), To: loc("h/b.go", 8)},
+				{Name: "h.Handle", From: loc("h/a.go", 3), To: loc("h/c.go", 1), FileChanged: true},
+			},
+		},
+		{
+			name:  "added",
+			to:    []FunctionLocation{loc("h/a.go", 3)},
+			added: []DiffFunction{fn("h/a.go", 3)},
+		},
+		{
+			name:    "removed",
+			from:    []FunctionLocation{loc("h/a.go", 3)},
+			removed: []DiffFunction{fn("h/a.go", 3)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed, moved := matchLocations("h.Handle", tt.from, tt.to)
+			if !reflect.DeepEqual(added, tt.added) {
+				t.Errorf("added = %+v, want %+v", added, tt.added)
+			}
+			if !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("removed = %+v, want %+v", removed, tt.removed)
+			}
+			if !reflect.DeepEqual(moved, tt.moved) {
+				t.Errorf("moved = %+v, want %+v", moved, tt.moved)
+			}
+		})
+	}
+}
+
+func TestDiffGraphs(t *testing.T) {
+	from := []OutRelation{
+		{Name: "main.main", Line: 5, FilePath: "main.go", Called: []OutCalled{{Name: "svc.Run", Line: 3, FilePath: "svc/svc.go"}}},
+		{Name: "svc.Run", Line: 3, FilePath: "svc/svc.go", Called: []OutCalled{{Name: "svc.old", Line: 10, FilePath: "svc/svc.go"}}},
+		{Name: "svc.old", Line: 10, FilePath: "svc/svc.go"},
+	}
+	to := []OutRelation{
+		{Name: "main.main", Line: 5, FilePath: "main.go", Called: []OutCalled{{Name: "svc.Run", Line: 1, FilePath: "svc/run.go"}}},
+		{Name: "svc.Run", Line: 1, FilePath: "svc/run.go", Called: []OutCalled{{Name: "svc.fresh", Line: 8, FilePath: "svc/run.go"}}},
+		{Name: "svc.fresh", Line: 8, FilePath: "svc/run.go"},
+		{Name: "tool.Main", Line: 1, FilePath: "tool/tool.go"},
+	}
+	SortRelations(from)
+	SortRelations(to)
+	d := DiffGraphs(NewGraph(from, nil), NewGraph(to, nil))
+
+	wantAdded := []DiffFunction{{Name: "svc.fresh", FilePath: "svc/run.go", Line: 8}, {Name: "tool.Main", FilePath: "tool/tool.go", Line: 1}}
+	if !reflect.DeepEqual(d.AddedFunctions, wantAdded) {
+		t.Errorf("AddedFunctions = %+v, want %+v", d.AddedFunctions, wantAdded)
+	}
+	if wantRemoved := []DiffFunction{{Name: "svc.old", FilePath: "svc/svc.go", Line: 10}}; !reflect.DeepEqual(d.RemovedFunctions, wantRemoved) {
+		t.Errorf("RemovedFunctions = %+v, want %+v", d.RemovedFunctions, wantRemoved)
+	}
+	wantMoved := []MovedFunction{{Name: "svc.Run", From: FunctionLocation{"svc/svc.go", 3}, To: FunctionLocation{"svc/run.go", 1}, FileChanged: true}}
+	if !reflect.DeepEqual(d.MovedFunctions, wantMoved) {
+		t.Errorf("MovedFunctions = %+v, want %+v", d.MovedFunctions, wantMoved)
+	}
+	if want := []DiffEdge{{Caller: "svc.Run", Callee: "svc.fresh"}}; !reflect.DeepEqual(d.AddedEdges, want) {
+		t.Errorf("AddedEdges = %+v, want %+v", d.AddedEdges, want)
+	}
+	if want := []DiffEdge{{Caller: "svc.Run", Callee: "svc.old"}}; !reflect.DeepEqual(d.RemovedEdges, want) {
+		t.Errorf("RemovedEdges = %+v, want %+v", d.RemovedEdges, want)
+	}
+	if want := []string{"tool.Main"}; !reflect.DeepEqual(d.AddedRoots, want) {
+		t.Errorf("AddedRoots = %v, want %v", d.AddedRoots, want)
+	}
+}
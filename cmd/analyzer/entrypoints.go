@@ -0,0 +1,332 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Root kinds, in the order roots are listed
+const (
+	RootMain    = "main"    // main.main
+	RootInit    = "init"    // package initializers
+	RootHandler = "handler" // uncalled HTTP handlers, found by their declarations (see DefaultHandlerPatterns)
+	RootTest    = "test"    // tests of _test.go files, when their relations are added (see TestIndex.Relations)
+	RootAPI     = "api"     // uncalled exported functions of importable packages
+	RootUnknown = "unknown" // uncalled functions of no other kind
+	RootNone    = "none"    // not a root; only used by EntryRule to exclude functions
+)
+
+// RootKinds lists the root kinds in listing order
+var RootKinds = []string{RootMain, RootInit, RootHandler, RootTest, RootAPI, RootUnknown}
+
+// reInitDecl matches the declaration of a package initializer, which has no receiver
+var reInitDecl = regexp.MustCompile(`^\s*func\s+init\s*\(`)
+
+// DefaultHandlerPatterns match the declaration of HTTP handlers in net/http, gin, echo and fiber, and
+// of functions returning one
+var DefaultHandlerPatterns = []string{
+	`\bhttp\.ResponseWriter\b`,
+	`\*gin\.Context\b`,
+	`\becho\.Context\b`,
+	`\*fiber\.Ctx\b`,
+	`\)\s*(http\.Handler|http\.HandlerFunc|gin\.HandlerFunc|echo\.HandlerFunc|fiber\.Handler)\s*\{`,
+}
+
+// EntryPoints configures how a repository's roots are found and classified: the entryPoints field of
+// RepoSpec. The zero value lists every kind and hides nothing.
+type EntryPoints struct {
+	Kinds    []string    `json:"kinds,omitempty"`    // kinds listed as roots (default: all of RootKinds)
+	Internal []string    `json:"internal,omitempty"` // name prefixes of helpers hidden from roots and closures unless includeInternals is set
+	Handlers []string    `json:"handlers,omitempty"` // regular expressions matching handler declarations, in addition to DefaultHandlerPatterns
+	Rules    []EntryRule `json:"rules,omitempty"`    // explicit kinds, checked in order before the built-in ones
+}
+
+// EntryRule gives the functions it matches a kind, whether or not they have callers
+type EntryRule struct {
+	Name string `json:"name,omitempty"` // regular expression matched against the function name
+	File string `json:"file,omitempty"` // regular expression matched against the file path
+	Kind string `json:"kind"`           // one of RootKinds, or RootNone to never list the functions as roots
+}
+
+// Root is an entry point and its kind
+type Root struct {
+	ID   NodeID
+	Kind string
+}
+
+// RootClassifier is a compiled EntryPoints
+type RootClassifier struct {
+	digest   string
+	kinds    map[string]bool
+	internal []string
+	handlers []*regexp.Regexp
+	rules    []rootRule
+}
+
+type rootRule struct {
+	name, file *regexp.Regexp
+	kind       string
+}
+
+// NewRootClassifier validates and compiles cfg
+func NewRootClassifier(cfg EntryPoints) (*RootClassifier, error) {
+	c := &RootClassifier{kinds: make(map[string]bool), internal: cfg.Internal}
+	config, err := json.Marshal(struct {
+		DefaultHandlers []string
+		EntryPoints
+	}{DefaultHandlerPatterns, cfg})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(config)
+	c.digest = hex.EncodeToString(sum[:])
+	for _, kind := range cfg.Kinds {
+		if !slices.Contains(RootKinds, kind) {
+			return nil, fmt.Errorf("invalid root kind %q: want one of %s", kind, strings.Join(RootKinds, ", "))
+		}
+		c.kinds[kind] = true
+	}
+	if len(cfg.Kinds) == 0 {
+		for _, kind := range RootKinds {
+			c.kinds[kind] = true
+		}
+	}
+	for _, pattern := range append(slices.Clone(DefaultHandlerPatterns), cfg.Handlers...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid handler pattern %q: %w", pattern, err)
+		}
+		c.handlers = append(c.handlers, re)
+	}
+	for i, rule := range cfg.Rules {
+		if rule.Kind != RootNone && !slices.Contains(RootKinds, rule.Kind) {
+			return nil, fmt.Errorf("rule %d: invalid kind %q: want one of %s or %s", i+1, rule.Kind, strings.Join(RootKinds, ", "), RootNone)
+		}
+		if rule.Name == "" && rule.File == "" {
+			return nil, fmt.Errorf("rule %d: set name, file or both", i+1)
+		}
+		compiled := rootRule{kind: rule.Kind}
+		var err error
+		if rule.Name != "" {
+			if compiled.name, err = regexp.Compile(rule.Name); err != nil {
+				return nil, fmt.Errorf("rule %d: invalid name pattern: %w", i+1, err)
+			}
+		}
+		if rule.File != "" {
+			if compiled.file, err = regexp.Compile(rule.File); err != nil {
+				return nil, fmt.Errorf("rule %d: invalid file pattern: %w", i+1, err)
+			}
+		}
+		c.rules = append(c.rules, compiled)
+	}
+	return c, nil
+}
+
+// Digest identifies the classifier's configuration: classifiers with the same digest find the same roots
+func (c *RootClassifier) Digest() string {
+	return c.digest
+}
+
+// PrebuiltRoots returns the roots stored with a snapshot when c classified them, so the source need not be
+// read again
+func (c *RootClassifier) PrebuiltRoots(snapshot Snapshot) ([]Root, bool) {
+	if snapshot.Roots == nil || snapshot.Roots.Classifier != c.digest {
+		return nil, false
+	}
+	return snapshot.Roots.Roots, true
+}
+
+// Lists reports whether roots of kind are listed
+func (c *RootClassifier) Lists(kind string) bool {
+	return c.kinds[kind]
+}
+
+// IsInternal reports whether name starts with one of the configured internal prefixes
+func (c *RootClassifier) IsInternal(name string) bool {
+	for _, p := range c.internal {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Roots classifies the nodes of g and returns the roots of the configured kinds, ordered by kind as in
+// RootKinds, then by node. main, init and test functions are roots even when the analysis found callers
+// for them; the others only when a function other than a test calls them, since handlers are registered
+// rather than called and a called function matching a handler pattern is a helper. read, when not nil,
+// supplies the source handler patterns are matched against and that tells initializers from init
+// methods; without it handlers are only found by rules.
+func (c *RootClassifier) Roots(g *Graph, read SourceReader) []Root {
+	var declarations func(OutRelation) string
+	if read != nil {
+		declarations = declarationReader(read)
+	}
+	byKind := make(map[string][]NodeID)
+	for i := 0; i < g.Len(); i++ {
+		id := NodeID(i)
+		if kind := c.kind(g, id, declarations); c.kinds[kind] {
+			byKind[kind] = append(byKind[kind], id)
+		}
+	}
+	var roots []Root
+	for _, kind := range RootKinds {
+		for _, id := range byKind[kind] {
+			roots = append(roots, Root{ID: id, Kind: kind})
+		}
+	}
+	return roots
+}
+
+// WithTestRoots adds the tests among the nodes of g to roots classified without them, such as those a
+// binary snapshot stores, keeping the order of Roots
+func (c *RootClassifier) WithTestRoots(g *Graph, roots []Root) []Root {
+	if !c.Lists(RootTest) {
+		return roots
+	}
+	var tests []Root
+	for i := 0; i < g.Len(); i++ {
+		if c.kind(g, NodeID(i), nil) == RootTest {
+			tests = append(tests, Root{ID: NodeID(i), Kind: RootTest})
+		}
+	}
+	if len(tests) == 0 {
+		return roots
+	}
+	after := slices.Index(RootKinds, RootTest)
+	at := slices.IndexFunc(roots, func(r Root) bool { return slices.Index(RootKinds, r.Kind) > after })
+	if at < 0 {
+		at = len(roots)
+	}
+	return slices.Concat(roots[:at], tests, roots[at:])
+}
+
+// SourceFiles returns the sorted files Roots reads declarations from: those of init functions and of
+// the uncalled nodes that rules and name-based kinds leave to handler matching. Callers reading from
+// git can fetch them in one go.
+func (c *RootClassifier) SourceFiles(g *Graph) []string {
+	seen := make(map[string]bool)
+	record := func(r OutRelation) string {
+		seen[r.FilePath] = true
+		return ""
+	}
+	for i := 0; i < g.Len(); i++ {
+		c.kind(g, NodeID(i), record)
+	}
+	files := make([]string, 0, len(seen))
+	for file := range seen {
+		files = append(files, file)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// kind returns the kind of node id as a root, or RootNone
+func (c *RootClassifier) kind(g *Graph, id NodeID, declarations func(OutRelation) string) string {
+	r := g.Relations[id]
+	for _, rule := range c.rules {
+		if (rule.name == nil || rule.name.MatchString(r.Name)) && (rule.file == nil || rule.file.MatchString(r.FilePath)) {
+			return rule.kind
+		}
+	}
+	if g.IsInternal(id) {
+		return RootNone
+	}
+	pkg, fn := r.Name, r.Name
+	if i := strings.LastIndex(r.Name, "."); i >= 0 {
+		pkg, fn = r.Name[:i], r.Name[i+1:]
+	}
+	switch {
+	case isTestFile(r.FilePath):
+		return RootTest
+	case r.Name == "main.main":
+		return RootMain
+	case fn == "init" && (declarations == nil || reInitDecl.MatchString(declarations(r))):
+		return RootInit
+	}
+	if calledOutsideTests(g, id) {
+		return RootNone
+	}
+	if declarations != nil {
+		decl := declarations(r)
+		for _, re := range c.handlers {
+			if re.MatchString(decl) {
+				return RootHandler
+			}
+		}
+	}
+	if first, _ := utf8.DecodeRuneInString(fn); unicode.IsUpper(first) && pkg != "main" && !isInternalPackage(r.FilePath) {
+		return RootAPI
+	}
+	return RootUnknown
+}
+
+// calledOutsideTests reports whether a function other than a test calls node id: tests exercise entry
+// points rather than make them reachable
+func calledOutsideTests(g *Graph, id NodeID) bool {
+	for _, caller := range g.Callers(id) {
+		if !isTestFile(g.Relations[caller].FilePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestFile reports whether filePath is a _test.go file, which only test relations come from
+func isTestFile(filePath string) bool {
+	return strings.HasSuffix(filePath, "_test.go")
+}
+
+// isInternalPackage reports whether the file belongs to an internal package, which other modules cannot
+// import, or to a dependency's source
+func isInternalPackage(filePath string) bool {
+	if strings.HasPrefix(filePath, "external") {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(filePath), "/") {
+		if dir == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+// maxDeclarationLines bounds how far a signature split over several lines is followed
+const maxDeclarationLines = 64
+
+// declarationReader returns the declaration of a relation from its line to the end of the signature, so
+// parameters and results spread over several lines are matched too; files are read once, and "" is
+// returned when the file cannot be read
+func declarationReader(read SourceReader) func(OutRelation) string {
+	files := make(map[string][]string)
+	return func(r OutRelation) string {
+		lines, ok := files[r.FilePath]
+		if !ok {
+			if src, err := read(r.FilePath); err == nil {
+				lines = strings.Split(string(src), "\n")
+			}
+			files[r.FilePath] = lines
+		}
+		if r.Line < 1 || r.Line > len(lines) {
+			return ""
+		}
+		// The signature ends on the first line that closes every parenthesis opened so far: the one
+		// closing the parameters, or the results when they are parenthesized too
+		end := r.Line - 1
+		for depth := 0; end < len(lines) && end-r.Line+1 < maxDeclarationLines; end++ {
+			depth += strings.Count(lines[end], "(") - strings.Count(lines[end], ")")
+			if depth <= 0 {
+				break
+			}
+		}
+		return strings.Join(lines[r.Line-1:min(end+1, len(lines))], " ")
+	}
+}
@@ -0,0 +1,195 @@
+package analyzer
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRootClassifierRoots(t *testing.T) {
+	source := map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+		"api/handlers.go": "package api\n\nfunc List(w http.ResponseWriter, r *http.Request) {}\n\nfunc Get(c *gin.Context) {}\n\n" +
+			"func (s *Server) Stream(\n\tw http.ResponseWriter,\n\tr *http.Request,\n) {}\n",
+		"api/client.go":     "package api\n\nfunc Fetch() {}\n\nfunc init() {}\n\nfunc (c *Client) init() {}\n",
+		"internal/x/x.go":   "package x\n\nfunc Run() {}\n",
+		"api/unexported.go": "package api\n\nfunc helper() {}\n",
+	}
+	read := func(rel string) ([]byte, error) {
+		if src, ok := source[rel]; ok {
+			return []byte(src), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	relations := []OutRelation{
+		{Name: "api.Fetch", FilePath: "api/client.go", Line: 3},
+		{Name: "api.Get", FilePath: "api/handlers.go", Line: 5},
+		{Name: "api.List", FilePath: "api/handlers.go", Line: 3},
+		{Name: "api.Stream", FilePath: "api/handlers.go", Line: 7},
+		{Name: "api.helper", FilePath: "api/unexported.go", Line: 3},
+		{Name: "api.init", FilePath: "api/client.go", Line: 5},
+		{Name: "api.init", FilePath: "api/client.go", Line: 7},
+		{Name: "main.main", FilePath: "main.go", Line: 3, Called: []OutCalled{{Name: "x.Run", FilePath: "internal/x/x.go", Line: 3}}},
+		{Name: "x.Run", FilePath: "internal/x/x.go", Line: 3},
+		// Added by TestIndex.Relations: calls from tests leave api.Fetch a root
+		{Name: "api.TestFetch", FilePath: "api/client_test.go", Line: 5, Called: []OutCalled{{Name: "api.Fetch", FilePath: "api/client.go", Line: 3}}},
+	}
+	type root struct{ name, kind string }
+
+	tests := []struct {
+		name string
+		cfg  EntryPoints
+		read SourceReader
+		want []root
+	}{
+		{
+			name: "defaults",
+			read: read,
+			want: []root{
+				{"main.main", RootMain}, {"api.init", RootInit},
+				{"api.Get", RootHandler}, {"api.List", RootHandler}, {"api.Stream", RootHandler},
+				{"api.TestFetch", RootTest},
+				{"api.Fetch", RootAPI}, {"api.helper", RootUnknown}, {"api.init", RootUnknown},
+			},
+		},
+		{
+			name: "without source handlers are api roots and init methods initializers",
+			want: []root{
+				{"main.main", RootMain}, {"api.init", RootInit}, {"api.init", RootInit},
+				{"api.TestFetch", RootTest},
+				{"api.Fetch", RootAPI}, {"api.Get", RootAPI}, {"api.List", RootAPI}, {"api.Stream", RootAPI}, {"api.helper", RootUnknown},
+			},
+		},
+		{
+			name: "kinds filter",
+			cfg:  EntryPoints{Kinds: []string{RootMain, RootHandler, RootTest}},
+			read: read,
+			want: []root{
+				{"main.main", RootMain}, {"api.Get", RootHandler}, {"api.List", RootHandler}, {"api.Stream", RootHandler},
+				{"api.TestFetch", RootTest},
+			},
+		},
+		{
+			name: "internal prefixes are hidden",
+			cfg:  EntryPoints{Internal: []string{"api.h"}},
+			read: read,
+			want: []root{
+				{"main.main", RootMain}, {"api.init", RootInit},
+				{"api.Get", RootHandler}, {"api.List", RootHandler}, {"api.Stream", RootHandler},
+				{"api.TestFetch", RootTest},
+				{"api.Fetch", RootAPI}, {"api.init", RootUnknown},
+			},
+		},
+		{
+			name: "rules come first",
+			cfg: EntryPoints{Rules: []EntryRule{
+				{Name: `^api\.Fetch$`, Kind: RootHandler},
+				{File: `^api/handlers\.go$`, Kind: RootNone},
+				{Name: `^x\.Run$`, Kind: RootMain},
+				{File: `_test\.go$`, Kind: RootNone},
+			}},
+			read: read,
+			want: []root{
+				{"main.main", RootMain}, {"x.Run", RootMain}, {"api.init", RootInit},
+				{"api.Fetch", RootHandler}, {"api.helper", RootUnknown}, {"api.init", RootUnknown},
+			},
+		},
+		{
+			name: "custom handler pattern",
+			cfg:  EntryPoints{Handlers: []string{`^func Fetch\(`}},
+			read: read,
+			want: []root{
+				{"main.main", RootMain}, {"api.init", RootInit},
+				{"api.Fetch", RootHandler}, {"api.Get", RootHandler}, {"api.List", RootHandler}, {"api.Stream", RootHandler},
+				{"api.TestFetch", RootTest},
+				{"api.helper", RootUnknown}, {"api.init", RootUnknown},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewRootClassifier(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := NewGraph(relations, c.IsInternal)
+			var got []root
+			for _, r := range c.Roots(g, tt.read) {
+				got = append(got, root{g.Relations[r.ID].Name, r.Kind})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("roots = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootClassifierSourceFiles(t *testing.T) {
+	c, err := NewRootClassifier(EntryPoints{Rules: []EntryRule{{File: `^gen/`, Kind: RootNone}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGraph([]OutRelation{
+		{Name: "main.main", FilePath: "main.go", Line: 3, Called: []OutCalled{{Name: "api.Called", FilePath: "api/b.go", Line: 1}}},
+		{Name: "api.Called", FilePath: "api/b.go", Line: 1},
+		{Name: "api.List", FilePath: "api/a.go", Line: 1},
+		{Name: "api.init", FilePath: "api/c.go", Line: 1},
+		{Name: "gen.Stub", FilePath: "gen/stub.go", Line: 1},
+		{Name: "web.Serve", FilePath: "web/serve.go", Line: 1},
+	}, c.IsInternal)
+	want := []string{"api/a.go", "api/c.go", "web/serve.go"}
+	if got := c.SourceFiles(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("SourceFiles = %v, want %v", got, want)
+	}
+}
+
+func TestNewRootClassifierInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  EntryPoints
+	}{
+		{"unknown kind", EntryPoints{Kinds: []string{"tests"}}},
+		{"rule kind", EntryPoints{Rules: []EntryRule{{Name: "x", Kind: "tests"}}}},
+		{"empty rule", EntryPoints{Rules: []EntryRule{{Kind: RootMain}}}},
+		{"bad pattern", EntryPoints{Handlers: []string{"("}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRootClassifier(tt.cfg); err == nil {
+				t.Error("NewRootClassifier succeeded")
+			}
+		})
+	}
+}
+
+func TestRootClassifierWithTestRoots(t *testing.T) {
+	relations := []OutRelation{
+		{Name: "a.Run", FilePath: "a/a.go", Line: 3},
+		{Name: "main.main", FilePath: "main.go", Line: 3},
+		{Name: "a.TestRun", FilePath: "a/a_test.go", Line: 5, Called: []OutCalled{{Name: "a.Run", FilePath: "a/a.go", Line: 3}}},
+	}
+	prebuilt := []Root{{ID: 1, Kind: RootMain}, {ID: 0, Kind: RootAPI}}
+	tests := []struct {
+		name string
+		cfg  EntryPoints
+		want []Root
+	}{
+		{"between handlers and api roots", EntryPoints{}, []Root{{1, RootMain}, {2, RootTest}, {0, RootAPI}}},
+		{"not listed", EntryPoints{Kinds: []string{RootMain, RootAPI}}, prebuilt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewRootClassifier(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := NewGraph(relations, c.IsInternal)
+			if got := c.WithTestRoots(g, prebuilt); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WithTestRoots = %v, want %v", got, tt.want)
+			}
+			if got := c.Roots(g, nil); !reflect.DeepEqual(got, c.WithTestRoots(g, prebuilt)) {
+				t.Errorf("Roots = %v, want the prebuilt roots with tests", got)
+			}
+		})
+	}
+}
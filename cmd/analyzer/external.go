@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -222,12 +223,19 @@ func encodeModulePath(path string) string {
 
 // ScanExternalModule scans an external module for Go functions
 func ScanExternalModule(modulePath string, moduleInfo ExternalModuleInfo) ([]FunctionInfo, error) {
-	var functions []FunctionInfo
+	return scanExternalModuleFiles(context.Background(), 0, modulePath, moduleInfo)
+}
 
+// scanExternalModuleFiles scans a module's files on at most workers goroutines, keeping walk order
+func scanExternalModuleFiles(ctx context.Context, workers int, modulePath string, moduleInfo ExternalModuleInfo) ([]FunctionInfo, error) {
+	var paths []string
 	err := filepath.Walk(modulePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 
 		// Skip directories, test files, and non-Go files
 		if info.IsDir() || strings.HasSuffix(path, "_test.go") || !strings.HasSuffix(path, ".go") {
@@ -239,32 +247,68 @@ func ScanExternalModule(modulePath string, moduleInfo ExternalModuleInfo) ([]Fun
 			return nil
 		}
 
-		funcs, err := scanExternalGoFile(path, modulePath, moduleInfo.ModulePath)
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	perFile := make([][]FunctionInfo, len(paths))
+	resolver := newImportResolver()
+	err = forEachParallel(ctx, workers, len(paths), func(i int) error {
+		funcs, err := scanExternalGoFile(paths[i], modulePath, moduleInfo.ModulePath, resolver)
 		if err != nil {
 			// Log error but continue scanning other files
-			fmt.Printf("Warning: failed to scan %s: %v\n", path, err)
+			fmt.Fprintf(os.Stderr, "Warning: failed to scan %s: %v\n", paths[i], err)
 			return nil
 		}
-
-		functions = append(functions, funcs...)
+		perFile[i] = funcs
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return functions, err
+	var functions []FunctionInfo
+	for _, funcs := range perFile {
+		functions = append(functions, funcs...)
+	}
+	return functions, nil
 }
 
 // ScanExternalModuleRecursively scans external modules and recursively analyzes their dependencies
 func ScanExternalModuleRecursively(modulePath string, moduleInfo ExternalModuleInfo, scannedModules map[string]bool, allExternalModules map[string]ExternalModuleInfo) ([]FunctionInfo, error) {
+	scanner := &moduleScanner{ctx: context.Background(), scanned: scannedModules, allModules: allExternalModules}
+	return scanner.scan(modulePath, moduleInfo)
+}
+
+// moduleScanner carries the state of one recursive external module scan
+type moduleScanner struct {
+	ctx        context.Context
+	workers    int
+	cache      *FileCache // module versions scanned by earlier runs; may be nil
+	scanned    map[string]bool
+	allModules map[string]ExternalModuleInfo
+}
+
+// scan scans a module and, recursively, the modules its functions call
+func (s *moduleScanner) scan(modulePath string, moduleInfo ExternalModuleInfo) ([]FunctionInfo, error) {
 	// Avoid infinite recursion
-	if scannedModules[moduleInfo.ModulePath] {
+	if s.scanned[moduleInfo.ModulePath] {
 		return nil, nil
 	}
-	scannedModules[moduleInfo.ModulePath] = true
+	s.scanned[moduleInfo.ModulePath] = true
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	var allFunctions []FunctionInfo
 
 	// First scan the current module
-	functions, err := ScanExternalModule(modulePath, moduleInfo)
+	functions, err := s.cache.moduleFunctions(moduleInfo, func() ([]FunctionInfo, error) {
+		return scanExternalModuleFiles(s.ctx, s.workers, modulePath, moduleInfo)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -285,17 +329,21 @@ func ScanExternalModuleRecursively(modulePath string, moduleInfo ExternalModuleI
 		}
 	}
 
-	// Recursively scan the external modules that are called
-	for calledModule := range externalCalls {
-		for extModulePath, extModuleInfo := range allExternalModules {
+	// Recursively scan the external modules that are called, in name order so the scan is reproducible
+	for _, calledModule := range sortedKeys(externalCalls) {
+		for _, extModulePath := range sortedKeys(s.allModules) {
+			extModuleInfo := s.allModules[extModulePath]
 			if strings.HasSuffix(extModulePath, calledModule) || strings.Contains(extModulePath, calledModule) {
 				extLocalPath, err := FindModuleInGoPath(extModuleInfo)
 				if err != nil {
 					continue // Skip modules that can't be found
 				}
 
-				recursiveFunctions, err := ScanExternalModuleRecursively(extLocalPath, extModuleInfo, scannedModules, allExternalModules)
+				recursiveFunctions, err := s.scan(extLocalPath, extModuleInfo)
 				if err != nil {
+					if s.ctx.Err() != nil {
+						return nil, s.ctx.Err()
+					}
 					continue // Skip modules that can't be scanned
 				}
 				allFunctions = append(allFunctions, recursiveFunctions...)
@@ -307,7 +355,7 @@ func ScanExternalModuleRecursively(modulePath string, moduleInfo ExternalModuleI
 }
 
 // scanExternalGoFile scans a single Go file in an external module
-func scanExternalGoFile(filePath, modulePath, moduleImportPath string) ([]FunctionInfo, error) {
+func scanExternalGoFile(filePath, modulePath, moduleImportPath string, resolver *importResolver) ([]FunctionInfo, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
@@ -341,7 +389,13 @@ func scanExternalGoFile(filePath, modulePath, moduleImportPath string) ([]Functi
 	}
 
 	// Note: We use the moduleImportPath directly for external functions
-	// to maintain consistency with Go import paths
+	// to maintain consistency with Go import paths, so imports of the module's
+	// own packages are qualified with the module path as well
+	imports, err := resolver.parse(filePath, modulePath, moduleImportPath)
+	if err != nil {
+		imports = ImportTable{}
+	}
+	imports.LocalQualifier = moduleImportPath
 
 	// Collect all exported function names in this file
 	var localFunctions []string
@@ -391,10 +445,13 @@ func scanExternalGoFile(filePath, modulePath, moduleImportPath string) ([]Functi
 								break
 							}
 						}
-					} else {
-						resolvedCalls = append(resolvedCalls, call)
+					} else if resolved, keep := imports.ResolveCall(call); keep {
+						resolvedCalls = appendUnique(resolvedCalls, resolved)
 					}
 				}
+				for _, call := range imports.ResolveDotImportCalls(lines[start+1 : end]) {
+					resolvedCalls = appendUnique(resolvedCalls, call)
+				}
 				funcInfo.Calls = resolvedCalls
 			}
 
@@ -0,0 +1,382 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fileCacheVersion changes whenever the per-file extraction logic stores different results
+const fileCacheVersion = 2
+
+// FileCache is a persistent per-file analysis cache. Each project file's ParseProject results
+// (functions, calls, type information and interface facts) are stored under the SHA-256 of its content,
+// and external modules under module@version, so a re-analysis only reparses files that changed.
+//
+// Extraction also depends on the package clause of imported directories, so the whole file section
+// is dropped when the module path or any package clause changes. Files with dot imports depend on
+// their sibling and imported packages and are never reused.
+//
+// The later stages are incremental as well: the type resolution of each distinct call is kept while the
+// project's type facts are unchanged, and Relations only rebuilds the relations of changed
+// functions and of their callers. A nil *FileCache disables caching.
+type FileCache struct {
+	path string
+
+	mu     sync.Mutex
+	data   fileCacheData
+	files  map[string]bool // project files seen in this run
+	seen   map[string]bool // module keys used in this run
+	hits   int
+	misses int
+
+	relationsReused, relationsRebuilt int
+}
+
+type fileCacheData struct {
+	Version         int
+	AnalyzerVersion string
+	Context         string // digest of module path and package clauses
+	Files           map[string]*fileCacheEntry
+	Modules         map[string]*moduleCacheEntry
+	Calls           *callResolutions // EnhanceProjectFunctionsWithTypeInfo
+	Relations       *relationBuild   // Relations
+}
+
+// callResolutions are the resolved calls of a run, valid for runs with the same type facts
+type callResolutions struct {
+	Digest string // see typeFactsDigest
+	Calls  map[string]resolvedCall
+}
+
+// resolvedCall is how EnhanceProjectFunctionsWithTypeInfo rewrites a call
+type resolvedCall struct {
+	Resolved        string
+	Implementations []FunctionInfo // implementations of the interface method the call targets
+}
+
+type fileCacheEntry struct {
+	Hash     string
+	Volatile bool // has dot imports: results depend on other files
+
+	Functions   []FunctionInfo
+	HasAllCalls bool
+	AllCalls    []FunctionInfo
+	Types       *FileTypeInfo
+	Impl        *fileImplFacts
+	ParseErr    string
+}
+
+type moduleCacheEntry struct {
+	HasFunctions bool
+	Functions    []FunctionInfo // ScanExternalModule
+	HasTypes     bool
+	Types        map[string]TypeInfo // parseExternalModuleForTypes
+}
+
+// DefaultFileCacheDir returns the directory used when no cache directory is configured
+func DefaultFileCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gomindmapper"), nil
+}
+
+// OpenFileCache loads the cache for the repository at root (absolute) from dir (DefaultFileCacheDir when
+// empty). A missing, corrupt or outdated cache file starts an empty cache.
+func OpenFileCache(dir, root string) (*FileCache, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultFileCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	rootSum := sha256.Sum256([]byte(root))
+	c := &FileCache{
+		path:  filepath.Join(dir, "files-"+hex.EncodeToString(rootSum[:8])+".gob"),
+		files: make(map[string]bool),
+		seen:  make(map[string]bool),
+	}
+
+	if f, err := os.Open(c.path); err == nil {
+		if err := gob.NewDecoder(f).Decode(&c.data); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring unreadable analysis cache %s: %v\n", c.path, err)
+			c.data = fileCacheData{}
+		}
+		f.Close()
+	}
+	if c.data.Version != fileCacheVersion || c.data.AnalyzerVersion != Version {
+		c.data = fileCacheData{}
+	}
+	c.data.Version = fileCacheVersion
+	c.data.AnalyzerVersion = Version
+	if c.data.Files == nil {
+		c.data.Files = make(map[string]*fileCacheEntry)
+	}
+	if c.data.Modules == nil {
+		c.data.Modules = make(map[string]*moduleCacheEntry)
+	}
+	return c, nil
+}
+
+// prepare drops all file entries when the module path or any package clause ("rel=package") changed
+func (c *FileCache) prepare(module string, clauses []string) {
+	sorted := append([]string(nil), clauses...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(module + "\n" + strings.Join(sorted, "\n")))
+	context := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data.Context != context {
+		c.data.Files = make(map[string]*fileCacheEntry)
+		c.data.Context = context
+	}
+}
+
+// restore fills file from its cache entry when the content hash matches; needAllCalls requires the
+// unfiltered calls to be cached as well
+func (c *FileCache) restore(file *ParsedFile, hash string, needAllCalls bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[file.RelPath] = true
+	entry := c.data.Files[file.RelPath]
+	if entry == nil || entry.Hash != hash || entry.Volatile || (needAllCalls && !entry.HasAllCalls) {
+		c.misses++
+		return false
+	}
+	c.hits++
+	file.Functions = cloneFunctions(entry.Functions)
+	if entry.HasAllCalls {
+		file.AllCalls = cloneFunctions(entry.AllCalls)
+		file.hasAllCalls = true
+	}
+	file.Types = entry.Types
+	file.impl = entry.Impl
+	if entry.ParseErr != "" {
+		file.ParseErr = errors.New(entry.ParseErr)
+	}
+	return true
+}
+
+// record stores a freshly parsed file under its content hash
+func (c *FileCache) record(file *ParsedFile, hash string) {
+	entry := &fileCacheEntry{
+		Hash:        hash,
+		Volatile:    file.volatile,
+		Functions:   cloneFunctions(file.Functions),
+		HasAllCalls: file.hasAllCalls,
+		AllCalls:    cloneFunctions(file.AllCalls),
+		Types:       file.Types,
+		Impl:        file.impl,
+	}
+	if file.ParseErr != nil {
+		entry.ParseErr = file.ParseErr.Error()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data.Files[file.RelPath] = entry
+}
+
+// Save writes the cache back to disk, dropping files that no longer exist and modules not used in this run
+func (c *FileCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for rel := range c.data.Files {
+		if !c.files[rel] {
+			delete(c.data.Files, rel)
+		}
+	}
+	for key := range c.data.Modules {
+		if !c.seen[key] {
+			delete(c.data.Modules, key)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(c.data); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Stats returns how many file and module lookups were served from the cache and how many were recomputed
+func (c *FileCache) Stats() (hits, misses int) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Relations returns what BuildRelations(functions, includeExternal) returns, rebuilding only
+// the relations of functions that changed since the previous run and of their callers, on at most
+// workers goroutines. The result is recorded for the next run. A nil cache builds every relation.
+func (c *FileCache) Relations(ctx context.Context, workers int, functions []FunctionInfo, includeExternal bool) ([]OutRelation, error) {
+	var prev *relationBuild
+	if c != nil {
+		c.mu.Lock()
+		prev = c.data.Relations
+		c.mu.Unlock()
+	}
+	relations, build, rebuilt, err := rebuildRelations(ctx, workers, prev, functions, includeExternal)
+	if err != nil || c == nil {
+		return relations, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data.Relations = build
+	c.relationsReused, c.relationsRebuilt = len(functions)-rebuilt, rebuilt
+	return relations, nil
+}
+
+// RelationStats returns how many relations the last Relations call reused and how many it rebuilt
+func (c *FileCache) RelationStats() (reused, rebuilt int) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.relationsReused, c.relationsRebuilt
+}
+
+// resolvedCalls returns the calls the previous run resolved with the type facts digest, or nil. The map
+// must not be modified.
+func (c *FileCache) resolvedCalls(digest string) map[string]resolvedCall {
+	if c == nil || digest == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data.Calls == nil || c.data.Calls.Digest != digest {
+		return nil
+	}
+	return c.data.Calls.Calls
+}
+
+// recordResolvedCalls keeps the calls resolved in this run for the next one
+func (c *FileCache) recordResolvedCalls(digest string, calls map[string]resolvedCall) {
+	if c == nil || digest == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data.Calls = &callResolutions{Digest: digest, Calls: calls}
+}
+
+// moduleFunctions returns the functions of a module version, calling scan only when they are not cached.
+// Modules without a version are always scanned.
+func (c *FileCache) moduleFunctions(moduleInfo ExternalModuleInfo, scan func() ([]FunctionInfo, error)) ([]FunctionInfo, error) {
+	key := moduleCacheKey(moduleInfo.ModulePath, moduleInfo.Version)
+	if c == nil || key == "" {
+		return scan()
+	}
+	if entry := c.module(key, func(e *moduleCacheEntry) bool { return e.HasFunctions }); entry != nil {
+		return cloneFunctions(entry.Functions), nil
+	}
+	funcs, err := scan()
+	if err != nil {
+		return nil, err
+	}
+	c.storeModule(key, func(e *moduleCacheEntry) {
+		e.HasFunctions = true
+		e.Functions = cloneFunctions(funcs)
+	})
+	return funcs, nil
+}
+
+// moduleTypes returns the exported types of a module version, calling parse only when they are not
+// cached. The result must not be modified.
+func (c *FileCache) moduleTypes(moduleInfo ExternalModuleInfo, parse func() (map[string]TypeInfo, error)) (map[string]TypeInfo, error) {
+	key := moduleCacheKey(moduleInfo.ModulePath, moduleInfo.Version)
+	if c == nil || key == "" {
+		return parse()
+	}
+	if entry := c.module(key, func(e *moduleCacheEntry) bool { return e.HasTypes }); entry != nil {
+		return entry.Types, nil
+	}
+	types, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	c.storeModule(key, func(e *moduleCacheEntry) {
+		e.HasTypes = true
+		e.Types = types
+	})
+	return types, nil
+}
+
+func (c *FileCache) module(key string, has func(*moduleCacheEntry) bool) *moduleCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[key] = true
+	if entry := c.data.Modules[key]; entry != nil && has(entry) {
+		c.hits++
+		return entry
+	}
+	c.misses++
+	return nil
+}
+
+func (c *FileCache) storeModule(key string, set func(*moduleCacheEntry)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.data.Modules[key]
+	if entry == nil {
+		entry = &moduleCacheEntry{}
+		c.data.Modules[key] = entry
+	}
+	set(entry)
+}
+
+// moduleCacheKey identifies an immutable module version; modules without a version are not cached
+func moduleCacheKey(modulePath, version string) string {
+	if version == "" {
+		return ""
+	}
+	return modulePath + "@" + version
+}
+
+// packageClause returns the package name declared in Go source, as FindFunctions reads it
+func packageClause(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "package ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "package "))
+		}
+	}
+	return ""
+}
+
+// cloneFunctions copies functions and their call lists so cached entries never share backing arrays
+// with slices the caller may append to
+func cloneFunctions(functions []FunctionInfo) []FunctionInfo {
+	out := make([]FunctionInfo, len(functions))
+	for i, f := range functions {
+		if f.Calls != nil {
+			f.Calls = append([]string(nil), f.Calls...)
+		}
+		out[i] = f
+	}
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -74,12 +75,12 @@ func CreateJsonFile(functions []FunctionInfo, includeExternal bool) {
 
 	data, err := json.MarshalIndent(functions, "", "  ")
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		return
 	}
 	err = os.WriteFile("functions.json", data, 0644)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 	}
 
 	// Write a report of removed calls only if we filtered calls
@@ -88,9 +89,10 @@ func CreateJsonFile(functions []FunctionInfo, includeExternal bool) {
 		for c := range removedSet {
 			removedList = append(removedList, c)
 		}
-		report := map[string]interface{}{
-			"removedPerFunction": removedPerFunc,
-			"uniqueRemovedCalls": removedList,
+		sort.Strings(removedList)
+		report := RemovedCallsReport{
+			RemovedPerFunction: removedPerFunc,
+			UniqueRemovedCalls: removedList,
 		}
 		rdata, rerr := json.MarshalIndent(report, "", "  ")
 		if rerr == nil {
@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SourceFingerprint hashes every input that affects analysis of root: the relative path and content of
+// each non-test .go file and of every go.mod and go.sum. Two trees with equal fingerprints produce the
+// same relations for the same flags, so a snapshot recording the fingerprint can stand in for a rescan.
+func SourceFingerprint(root string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isFingerprintInput(info.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// path and size delimit each file so concatenations cannot collide
+		io.WriteString(h, filepath.ToSlash(rel))
+		h.Write([]byte{0})
+		io.WriteString(h, strconv.FormatInt(info.Size(), 10))
+		h.Write([]byte{0})
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isFingerprintInput(name string) bool {
+	if name == "go.mod" || name == "go.sum" {
+		return true
+	}
+	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
+}
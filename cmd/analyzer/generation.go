@@ -1,6 +1,10 @@
 package analyzer
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,50 +12,85 @@ import (
 	"strings"
 )
 
+// Patterns for function and method declarations in project files
+var (
+	reFuncDecl   = regexp.MustCompile(`^\s*func\s+(\w+)`)
+	reMethodDecl = regexp.MustCompile(`^\s*func\s+\([^)]+\)\s+(\w+)`)
+)
+
 // FindFunctions scans a Go source file and returns functions/methods with resolved local calls
 func FindFunctions(filePath, absPath, module string) ([]FunctionInfo, error) {
-	content, err := os.ReadFile(filePath)
+	lines, relPath, imports, err := readSourceForScan(filePath, absPath, module)
 	if err != nil {
 		return nil, err
 	}
+	return extractFunctions(lines, relPath, imports), nil
+}
 
-	lines := strings.Split(string(content), "\n")
-	var funcs []FunctionInfo
-	// Match regular functions and methods
-	reFunc := regexp.MustCompile(`^\s*func\s+(\w+)`)
-	reMethod := regexp.MustCompile(`^\s*func\s+\([^)]+\)\s+(\w+)`)
-	relPath, err := filepath.Rel(absPath, filePath)
+// FindFunctionsWithAllCalls is similar to FindFunctions but keeps all calls without filtering, standard
+// library calls included. Aliased package calls are still rewritten to their import path so external
+// modules can be matched.
+func FindFunctionsWithAllCalls(filePath, absPath, module string) ([]FunctionInfo, error) {
+	lines, relPath, imports, err := readSourceForScan(filePath, absPath, module)
 	if err != nil {
 		return nil, err
 	}
+	return extractAllCalls(lines, relPath, imports), nil
+}
 
-	// Find package name
-	var packageName string
+// readSourceForScan reads a file's lines, its path relative to absPath and its import table
+// (files that fail to parse keep calls as written)
+func readSourceForScan(filePath, absPath, module string) ([]string, string, ImportTable, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, "", ImportTable{}, err
+	}
+	relPath, err := filepath.Rel(absPath, filePath)
+	if err != nil {
+		return nil, "", ImportTable{}, err
+	}
+	imports, err := ParseImportTable(filePath, absPath, module)
+	if err != nil {
+		imports = ImportTable{}
+	}
+	return strings.Split(string(content), "\n"), relPath, imports, nil
+}
+
+// packageNameOf returns the package clause found in lines
+func packageNameOf(lines []string) string {
 	for _, line := range lines {
 		if strings.HasPrefix(line, "package ") {
-			packageName = strings.TrimSpace(strings.TrimPrefix(line, "package "))
-			break
+			return strings.TrimSpace(strings.TrimPrefix(line, "package "))
 		}
 	}
+	return ""
+}
+
+// declaredFunction returns the function or method name declared on line, if any
+func declaredFunction(line string) string {
+	if matches := reFuncDecl.FindStringSubmatch(line); matches != nil {
+		return matches[1]
+	} else if matches := reMethodDecl.FindStringSubmatch(line); matches != nil {
+		return matches[1]
+	}
+	return ""
+}
+
+// extractFunctions returns the functions declared in lines with resolved local calls
+func extractFunctions(lines []string, relPath string, imports ImportTable) []FunctionInfo {
+	var funcs []FunctionInfo
+	packageName := packageNameOf(lines)
 
 	// Collect all function names in this file for reference resolution
 	var localFunctions []string
 	for _, line := range lines {
-		if matches := reFunc.FindStringSubmatch(line); matches != nil {
-			localFunctions = append(localFunctions, matches[1])
-		} else if matches := reMethod.FindStringSubmatch(line); matches != nil {
-			localFunctions = append(localFunctions, matches[1])
+		if name := declaredFunction(line); name != "" {
+			localFunctions = append(localFunctions, name)
 		}
 	}
 
 	for i, line := range lines {
-		var functionName string
-		if matches := reFunc.FindStringSubmatch(line); matches != nil {
-			functionName = matches[1]
-		} else if matches := reMethod.FindStringSubmatch(line); matches != nil {
-			functionName = matches[1]
-		}
-
+		functionName := declaredFunction(line)
 		if functionName != "" {
 			fi := FunctionInfo{
 				Name:     packageName + "." + functionName,
@@ -64,60 +103,38 @@ func FindFunctions(filePath, absPath, module string) ([]FunctionInfo, error) {
 				calls := FindCalls(lines[start+1 : end])
 
 				// Resolve local function references by adding package prefix
+				// and rewrite alias.Func calls through the file's import table
 				var resolvedCalls []string
 				for _, call := range calls {
 					if !strings.Contains(call, ".") {
 						for _, localFunc := range localFunctions {
 							if call == localFunc {
-								resolvedCalls = append(resolvedCalls, packageName+"."+call)
+								resolvedCalls = appendUnique(resolvedCalls, packageName+"."+call)
 								break
 							}
 						}
-					} else {
-						resolvedCalls = append(resolvedCalls, call)
+					} else if resolved, keep := imports.ResolveCall(call); keep {
+						resolvedCalls = appendUnique(resolvedCalls, resolved)
 					}
 				}
+				for _, call := range imports.ResolveDotImportCalls(lines[start+1 : end]) {
+					resolvedCalls = appendUnique(resolvedCalls, call)
+				}
 				fi.Calls = resolvedCalls
 			}
 			funcs = append(funcs, fi)
 		}
 	}
-	return funcs, nil
+	return funcs
 }
 
-// FindFunctionsWithAllCalls is similar to FindFunctions but keeps all calls without filtering
-func FindFunctionsWithAllCalls(filePath, absPath string) ([]FunctionInfo, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
-
-	lines := strings.Split(string(content), "\n")
+// extractAllCalls returns the functions declared in lines with every call kept (see FindFunctionsWithAllCalls)
+func extractAllCalls(lines []string, relPath string, imports ImportTable) []FunctionInfo {
 	var funcs []FunctionInfo
-	reFunc := regexp.MustCompile(`^\s*func\s+(\w+)`)
-	reMethod := regexp.MustCompile(`^\s*func\s+\([^)]+\)\s+(\w+)`)
-	relPath, err := filepath.Rel(absPath, filePath)
-	if err != nil {
-		return nil, err
-	}
-
-	// Find package name
-	var packageName string
-	for _, line := range lines {
-		if strings.HasPrefix(line, "package ") {
-			packageName = strings.TrimSpace(strings.TrimPrefix(line, "package "))
-			break
-		}
-	}
+	packageName := packageNameOf(lines)
 
 	for i, line := range lines {
-		var functionName string
-		if matches := reFunc.FindStringSubmatch(line); matches != nil {
-			functionName = matches[1]
-		} else if matches := reMethod.FindStringSubmatch(line); matches != nil {
-			functionName = matches[1]
-		}
-
+		functionName := declaredFunction(line)
 		if functionName != "" {
 			funcInfo := FunctionInfo{
 				Name:     packageName + "." + functionName,
@@ -126,44 +143,57 @@ func FindFunctionsWithAllCalls(filePath, absPath string) ([]FunctionInfo, error)
 			}
 			start, end := FindFunctionBody(lines, i)
 			if start != -1 && end != -1 && start+1 < end && end < len(lines) {
-				calls := FindCalls(lines[start+1 : end])
+				var calls []string
+				for _, call := range FindCalls(lines[start+1 : end]) {
+					calls = appendUnique(calls, imports.RewriteCall(call))
+				}
+				for _, call := range imports.ResolveDotImportCalls(lines[start+1 : end]) {
+					calls = appendUnique(calls, call)
+				}
 				funcInfo.Calls = calls
 			}
 			funcs = append(funcs, funcInfo)
 		}
 	}
-	return funcs, nil
+	return funcs
 }
 
 // ScanExternalModules scans external modules when include-external is enabled
-// This function recursively finds all go.mod files in the repository and scans their dependencies
-func ScanExternalModules(projectPath string, functions []FunctionInfo, skipPatterns []string) ([]FunctionInfo, error) {
+// This function recursively finds all go.mod files in the repository and scans their dependencies.
+// Project calls come from the parsed project (ParseOptions.AllCalls); module versions scanned by an
+// earlier run are served from project.Cache. It stops with ctx.Err() when ctx is cancelled.
+func ScanExternalModules(ctx context.Context, project *Project, skipPatterns []string) ([]FunctionInfo, error) {
+	projectPath := project.Root
+
 	// Find all go.mod files recursively in the repository
 	var goModPaths []string
 	err := filepath.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if !info.IsDir() && info.Name() == "go.mod" {
 			goModPaths = append(goModPaths, filepath.Dir(path))
 		}
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to find go.mod files: %v", err)
+		return nil, fmt.Errorf("failed to find go.mod files: %w", err)
 	}
 
-	fmt.Printf("Found %d go.mod files in repository\n", len(goModPaths))
+	fmt.Fprintf(os.Stderr, "Found %d go.mod files in repository\n", len(goModPaths))
 
 	// Collect all external modules from all go.mod files
 	allModules := make(map[string]ExternalModuleInfo)
 	for _, modPath := range goModPaths {
 		modules, err := GetExternalModules(modPath)
 		if err != nil {
-			fmt.Printf("Warning: failed to get external modules from %s: %v\n", modPath, err)
+			fmt.Fprintf(os.Stderr, "Warning: failed to get external modules from %s: %v\n", modPath, err)
 			continue
 		}
-		fmt.Printf("Found %d modules in %s\n", len(modules), modPath)
+		fmt.Fprintf(os.Stderr, "Found %d modules in %s\n", len(modules), modPath)
 
 		for modName, modInfo := range modules {
 			if _, exists := allModules[modName]; !exists {
@@ -172,37 +202,27 @@ func ScanExternalModules(projectPath string, functions []FunctionInfo, skipPatte
 		}
 	}
 
-	fmt.Printf("Total unique external modules found: %d\n", len(allModules))
+	fmt.Fprintf(os.Stderr, "Total unique external modules found: %d\n", len(allModules))
 
 	// Filter out modules matching skip patterns
 	if len(skipPatterns) > 0 {
 		allModules = FilterModulesBySkipPatterns(allModules, skipPatterns)
-		fmt.Printf("After filtering skip patterns, scanning %d modules\n", len(allModules))
+		fmt.Fprintf(os.Stderr, "After filtering skip patterns, scanning %d modules\n", len(allModules))
 	}
 
 	// Parse type information for better call resolution
-	fmt.Println("Analyzing type information...")
-	typeInfo, err := ParseTypeInformation(projectPath, allModules)
+	fmt.Fprintln(os.Stderr, "Analyzing type information...")
+	typeInfo, err := project.typeInformation(ctx, allModules)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
-		fmt.Printf("Warning: failed to parse type information: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to parse type information: %v\n", err)
 		typeInfo = make(map[string]TypeInfo)
 	}
 
 	// Collect external calls from the raw function data before filtering
-	var allFunctions []FunctionInfo
-	err = filepath.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-			funcs, err := FindFunctionsWithAllCalls(path, projectPath)
-			if err != nil {
-				return err
-			}
-			allFunctions = append(allFunctions, funcs...)
-		}
-		return nil
-	})
+	allFunctions, err := project.allCallFunctions()
 	if err != nil {
 		return nil, fmt.Errorf("failed to re-scan project for external calls: %v", err)
 	}
@@ -211,24 +231,37 @@ func ScanExternalModules(projectPath string, functions []FunctionInfo, skipPatte
 	relevantModules := FilterRelevantExternalModules(allFunctions, allModules, skipPatterns)
 
 	var externalFunctions []FunctionInfo
-	scannedModules := make(map[string]bool)
+	scanner := &moduleScanner{
+		ctx:        ctx,
+		workers:    project.Workers,
+		cache:      project.Cache,
+		scanned:    make(map[string]bool),
+		allModules: allModules,
+	}
 
-	for modulePath, moduleInfo := range relevantModules {
-		fmt.Printf("Scanning module: %s@%s\n", modulePath, moduleInfo.Version)
+	for _, modulePath := range sortedKeys(relevantModules) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		moduleInfo := relevantModules[modulePath]
+		fmt.Fprintf(os.Stderr, "Scanning module: %s@%s\n", modulePath, moduleInfo.Version)
 
 		localPath, err := FindModuleInGoPath(moduleInfo)
 		if err != nil {
-			fmt.Printf("Warning: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 			continue
 		}
 
-		moduleFunctions, err := ScanExternalModuleRecursively(localPath, moduleInfo, scannedModules, allModules)
+		moduleFunctions, err := scanner.scan(localPath, moduleInfo)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if err != nil {
-			fmt.Printf("Warning: failed to scan module %s: %v\n", modulePath, err)
+			fmt.Fprintf(os.Stderr, "Warning: failed to scan module %s: %v\n", modulePath, err)
 			continue
 		}
 
-		fmt.Printf("Found %d functions (including dependencies) in module %s\n", len(moduleFunctions), modulePath)
+		fmt.Fprintf(os.Stderr, "Found %d functions (including dependencies) in module %s\n", len(moduleFunctions), modulePath)
 		externalFunctions = append(externalFunctions, moduleFunctions...)
 	}
 
@@ -243,58 +276,66 @@ func enhanceExternalFunctionsWithTypeInfo(functions []FunctionInfo, _ map[string
 	return functions
 }
 
-// EnhanceProjectFunctionsWithTypeInfo enhances project functions with type resolution and interface implementation detection
-func EnhanceProjectFunctionsWithTypeInfo(functions []FunctionInfo, projectPath string) []FunctionInfo {
-	// Parse type information for the project
-	typeInfo, err := ParseTypeInformation(projectPath, make(map[string]ExternalModuleInfo))
+// EnhanceProjectFunctionsWithTypeInfo enhances project functions with type resolution and interface implementation detection.
+// Type and interface information comes from the parsed project. It stops with ctx.Err() when ctx is cancelled.
+func EnhanceProjectFunctionsWithTypeInfo(ctx context.Context, functions []FunctionInfo, project *Project) ([]FunctionInfo, error) {
+	// Type information for the project
+	typeInfo, err := project.typeInformation(ctx, nil)
 	if err != nil {
-		fmt.Printf("Warning: failed to parse project type information: %v\n", err)
-		return functions
+		fmt.Fprintf(os.Stderr, "Warning: failed to parse project type information: %v\n", err)
+		return functions, nil
 	}
 
-	// Parse comprehensive file information
+	// Comprehensive file information (files that can't be parsed are skipped)
 	fileInfoMap := make(map[string]FileTypeInfo)
-	err = filepath.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-			relPath, _ := filepath.Rel(projectPath, path)
-			fileInfo, err := ParseGoFileForTypesAndImports(path, projectPath)
-			if err != nil {
-				return nil // Skip files that can't be parsed
-			}
-			fileInfoMap[relPath] = fileInfo
+	for _, file := range project.Files {
+		if file.Types != nil {
+			fileInfoMap[file.RelPath] = *file.Types
 		}
-		return nil
-	})
-	if err != nil {
-		fmt.Printf("Warning: failed to parse comprehensive file information: %v\n", err)
-		return functions
 	}
 
 	// Find interface implementations
-	implementations, err := FindInterfaceImplementations(projectPath)
-	if err != nil {
-		fmt.Printf("Warning: failed to find interface implementations: %v\n", err)
-		implementations = make(map[string][]InterfaceImplementation)
+	implementations := project.interfaceImplementations()
+
+	// A call resolves the same way wherever it appears, so each distinct call is resolved once per run,
+	// and not at all when the previous run saw the same type facts
+	digest := typeFactsDigest(typeInfo, fileInfoMap, implementations)
+	previous := project.Cache.resolvedCalls(digest)
+	resolved := make(map[string]resolvedCall)
+	resolve := func(call string) resolvedCall {
+		if r, ok := resolved[call]; ok {
+			return r
+		}
+		r, ok := previous[call]
+		if !ok {
+			r = resolvedCall{
+				Resolved:        ResolveMethodCall(call, fileInfoMap, typeInfo, implementations),
+				Implementations: GetImplementationCalls(call, implementations),
+			}
+		}
+		resolved[call] = r
+		return r
 	}
 
-	// Process each function to resolve its method calls and add implementation calls
+	// Process each function to resolve its method calls and add implementation calls.
+	// Implementation functions are collected separately so scanned definitions come first when merging.
 	var enhancedFunctions []FunctionInfo
-	for _, fn := range functions {
+	var implementationFunctions []FunctionInfo
+	for i, fn := range functions {
+		if i%1000 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		enhancedCalls := make([]string, 0, len(fn.Calls))
 
 		for _, call := range fn.Calls {
 			// Try to resolve the call using comprehensive type information
-			resolvedCall := ResolveMethodCall(call, fileInfoMap, typeInfo, implementations)
-			enhancedCalls = append(enhancedCalls, resolvedCall)
+			r := resolve(call)
+			enhancedCalls = append(enhancedCalls, r.Resolved)
 
 			// If this is an interface method call, add the implementation calls
-			implementationFunctions := GetImplementationCalls(call, implementations)
-			for _, implFunc := range implementationFunctions {
+			for _, implFunc := range r.Implementations {
 				// Add the implementation function to our function list
-				enhancedFunctions = append(enhancedFunctions, implFunc)
+				implementationFunctions = append(implementationFunctions, implFunc)
 
 				// Also add a call relationship from the current function to the implementation
 				enhancedCalls = append(enhancedCalls, implFunc.Name)
@@ -305,5 +346,25 @@ func EnhanceProjectFunctionsWithTypeInfo(functions []FunctionInfo, projectPath s
 		enhancedFunctions = append(enhancedFunctions, fn)
 	}
 
-	return enhancedFunctions
+	project.Cache.recordResolvedCalls(digest, resolved)
+
+	// The same implementation is emitted once per interface call site; merge them into one entry per function
+	merged, conflicts := MergeFunctions(append(enhancedFunctions, implementationFunctions...))
+	reportMergeConflicts(conflicts)
+	return merged, nil
+}
+
+// typeFactsDigest identifies the type facts calls are resolved with; "" when they cannot be encoded
+func typeFactsDigest(typeInfo map[string]TypeInfo, fileInfoMap map[string]FileTypeInfo, implementations map[string][]InterfaceImplementation) string {
+	// encoding/json writes map keys in sorted order, so equal facts encode equally
+	data, err := json.Marshal(struct {
+		Types           map[string]TypeInfo
+		Files           map[string]FileTypeInfo
+		Implementations map[string][]InterfaceImplementation
+	}{typeInfo, fileInfoMap, implementations})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,321 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Git ref kinds reported in GitRef.Kind
+const (
+	RefBranch = "branch"
+	RefTag    = "tag"
+)
+
+// GitRef is a branch or tag of a local git repository
+type GitRef struct {
+	Name   string `json:"name"`   // short name, e.g. "main" or "v1.2.0"
+	Kind   string `json:"kind"`   // RefBranch or RefTag
+	Commit string `json:"commit"` // commit the ref points to (tags are peeled)
+}
+
+// git runs a git command in dir and returns its standard output; failures carry git's own message
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// ResolveGitRef returns the full hash of the commit ref (a commit, branch or tag) names in the git
+// repository containing repoPath
+func ResolveGitRef(ctx context.Context, repoPath, ref string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid git ref %q", ref)
+	}
+	out, err := git(ctx, repoPath, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("unknown git ref %q in %s", ref, repoPath)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GitRefs lists the branches and tags of the git repository containing repoPath, branches first, each
+// sorted by name
+func GitRefs(ctx context.Context, repoPath string) ([]GitRef, error) {
+	out, err := git(ctx, repoPath, "for-each-ref", "--format=%(refname)%00%(objectname)%00%(*objectname)", "refs/heads", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	var refs []GitRef
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 3 {
+			continue
+		}
+		ref := GitRef{Commit: fields[1]}
+		if fields[2] != "" {
+			// Annotated tag: report the commit it points to
+			ref.Commit = fields[2]
+		}
+		switch {
+		case strings.HasPrefix(fields[0], "refs/heads/"):
+			ref.Name, ref.Kind = strings.TrimPrefix(fields[0], "refs/heads/"), RefBranch
+		case strings.HasPrefix(fields[0], "refs/tags/"):
+			ref.Name, ref.Kind = strings.TrimPrefix(fields[0], "refs/tags/"), RefTag
+		default:
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].Kind != refs[j].Kind {
+			return refs[i].Kind == RefBranch
+		}
+		return refs[i].Name < refs[j].Name
+	})
+	return refs, nil
+}
+
+// gitLocation returns the top of the git work tree containing repoPath and repoPath relative to it, in
+// slash form ("" at the top)
+func gitLocation(ctx context.Context, repoPath string) (top, prefix string, err error) {
+	out, err := git(ctx, repoPath, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return "", "", err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("%s is not inside a git work tree", repoPath)
+	}
+	return lines[0], strings.TrimSuffix(lines[1], "/"), nil
+}
+
+// CheckoutGitRef writes the files repoPath had at commit into dir by streaming the blobs out of the
+// object database (git archive), so the work tree, index and HEAD are left alone. When repoPath is a
+// subdirectory of its work tree only that subdirectory is written, with dir as its root.
+func CheckoutGitRef(ctx context.Context, repoPath, commit, dir string) error {
+	top, prefix, err := gitLocation(ctx, repoPath)
+	if err != nil {
+		return err
+	}
+	// Run from the top: below it, git archive would only include the current directory of the tree
+	cmd := exec.CommandContext(ctx, "git", "-C", top, "archive", "--format=tar", commit+":"+prefix)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	extractErr := ExtractTar(stdout, dir, 0)
+	if extractErr != nil {
+		// Stop git before waiting so it does not block on a full pipe
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case waitErr != nil && extractErr == nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git archive: %s", msg)
+		}
+		return fmt.Errorf("git archive: %w", waitErr)
+	}
+	return extractErr
+}
+
+// GitDiff returns the unified diff of the repository at repoPath from base to head (both commits,
+// branches or tags), without context lines and with paths relative to repoPath. An empty head diffs
+// base against the work tree.
+func GitDiff(ctx context.Context, repoPath, base, head string) ([]byte, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff", "-U0", "--relative", "--end-of-options", base}
+	if head != "" {
+		args = append(args, head)
+	}
+	return git(ctx, repoPath, args...)
+}
+
+// GitFileReader reads files of the repository at repoPath as they are at commit, by path relative to
+// repoPath, straight from the object database
+func GitFileReader(ctx context.Context, repoPath, commit string) SourceReader {
+	return func(relPath string) ([]byte, error) {
+		return git(ctx, repoPath, "show", commit+":./"+filepath.ToSlash(relPath))
+	}
+}
+
+// GitBatchReader reads relPaths (relative to repoPath) as they are at commit with a single git cat-file
+// --batch, where GitFileReader runs git once per file. The returned reader serves those files from
+// memory; files missing at commit, and files not listed, fail with an error wrapping os.ErrNotExist.
+func GitBatchReader(ctx context.Context, repoPath, commit string, relPaths []string) (SourceReader, error) {
+	top, prefix, err := gitLocation(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	var request bytes.Buffer
+	var names []string
+	for _, rel := range relPaths {
+		rel = filepath.ToSlash(rel)
+		if strings.ContainsAny(rel, "\n\r") {
+			continue // cannot be named on a batch input line
+		}
+		names = append(names, rel)
+		request.WriteString(commit + ":" + path.Join(prefix, rel) + "\n")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", top, "cat-file", "--batch")
+	cmd.Stdin = &request
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	files, readErr := readCatFileBatch(bufio.NewReader(stdout), names)
+	if readErr != nil {
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case readErr != nil:
+		return nil, readErr
+	case waitErr != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git cat-file: %s", msg)
+		}
+		return nil, fmt.Errorf("git cat-file: %w", waitErr)
+	}
+
+	return func(relPath string) ([]byte, error) {
+		if content, ok := files[filepath.ToSlash(relPath)]; ok {
+			return content, nil
+		}
+		return nil, fmt.Errorf("%s at %s: %w", relPath, commit, os.ErrNotExist)
+	}, nil
+}
+
+// readCatFileBatch reads one git cat-file --batch answer per name: "<oid> <type> <size>" followed by the
+// content and a newline, or "<object> missing" (or "ambiguous") alone. Only blobs are kept.
+func readCatFileBatch(r *bufio.Reader, names []string) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(names))
+	for _, name := range names {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("git cat-file: reading header for %s: %w", name, err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue // missing or ambiguous
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("git cat-file: bad header %q", strings.TrimSpace(line))
+		}
+		content := make([]byte, size+1) // and the trailing newline
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, fmt.Errorf("git cat-file: reading %s: %w", name, err)
+		}
+		if fields[1] == "blob" {
+			files[name] = content[:size]
+		}
+	}
+	return files, nil
+}
+
+// GitRefVCS describes a snapshot analyzed from commit rather than from a work tree
+func GitRefVCS(commit string) *VCSInfo {
+	return &VCSInfo{System: "git", Commit: commit}
+}
+
+// RefCache keeps the snapshot of every analyzed commit, so a revision is only analyzed once per set of
+// analysis flags. Snapshots are stored as binary snapshots under refs/ in the cache directory; a
+// commit's tree never changes, so entries need no freshness check beyond the analyzer version.
+type RefCache struct {
+	dir    string
+	prefix string // repository root relative to its git work tree
+	flags  SnapshotFlags
+}
+
+// OpenRefCache returns the commit cache of the repository at repoPath for flags, stored below dir
+// (DefaultFileCacheDir when empty)
+func OpenRefCache(ctx context.Context, dir, repoPath string, flags SnapshotFlags) (*RefCache, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultFileCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	_, prefix, err := gitLocation(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "refs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &RefCache{dir: dir, prefix: prefix, flags: flags}, nil
+}
+
+// path names the cache entry of commit; the analyzer version, the analyzed subdirectory and the flags
+// are part of the name
+func (c *RefCache) path(commit string) string {
+	skip := append([]string(nil), c.flags.SkipPatterns...)
+	sort.Strings(skip)
+	key := fmt.Sprintf("%s\n%s\n%s\n%t\n%s", Version, commit, c.prefix, c.flags.IncludeExternal, strings.Join(skip, ","))
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, commit+"-"+hex.EncodeToString(sum[:8])+".bin")
+}
+
+// Load returns the cached snapshot of commit; the error wraps os.ErrNotExist when there is none
+func (c *RefCache) Load(commit string) (Snapshot, error) {
+	return LoadBinarySnapshot(c.path(commit))
+}
+
+// Save stores the snapshot of commit
+func (c *RefCache) Save(commit string, snapshot Snapshot) error {
+	path := c.path(commit)
+	tmp := path + ".tmp"
+	if err := SaveBinarySnapshot(tmp, snapshot); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// IsNotCached reports whether err from Load means the commit has not been analyzed yet
+func IsNotCached(err error) bool {
+	return errors.Is(err, os.ErrNotExist)
+}
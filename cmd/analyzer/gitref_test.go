@@ -0,0 +1,93 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitCommit creates a git repository in dir holding files and returns its commit
+func gitCommit(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	writeFiles(t, dir, files)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "files"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, out)
+		}
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGitBatchReader(t *testing.T) {
+	top := t.TempDir()
+	commit := gitCommit(t, top, map[string]string{
+		"go.mod":          "module example.com/top\n",
+		"svc/main.go":     "package main\n",
+		"svc/api/list.go": "package api\n\nfunc List() {}\n",
+		"svc/empty.go":    "",
+	})
+	// Later edits to the work tree must not show
+	if err := os.WriteFile(filepath.Join(top, "svc/main.go"), []byte("package changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := GitBatchReader(context.Background(), filepath.Join(top, "svc"), commit,
+		[]string{"main.go", "api/list.go", "empty.go", "missing.go", "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rel     string
+		want    string
+		missing bool
+	}{
+		{rel: "main.go", want: "package main\n"},
+		{rel: "api/list.go", want: "package api\n\nfunc List() {}\n"},
+		{rel: "empty.go", want: ""},
+		{rel: "missing.go", missing: true},
+		{rel: "api", missing: true},         // a tree, not a file
+		{rel: "unlisted.go", missing: true}, // not requested
+		{rel: "../go.mod", missing: true},   // outside the repository
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			got, err := read(tt.rel)
+			if tt.missing {
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("read(%q) = %q, %v; want os.ErrNotExist", tt.rel, got, err)
+				}
+				return
+			}
+			if err != nil || string(got) != tt.want {
+				t.Errorf("read(%q) = %q, %v; want %q", tt.rel, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitBatchReaderUnknownCommit(t *testing.T) {
+	dir := t.TempDir()
+	gitCommit(t, dir, map[string]string{"main.go": "package main\n"})
+	read, err := GitBatchReader(context.Background(), dir, strings.Repeat("0", 40), []string{"main.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read("main.go"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("read = %v, want os.ErrNotExist", err)
+	}
+}
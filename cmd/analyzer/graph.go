@@ -0,0 +1,437 @@
+package analyzer
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// NodeID identifies a relation in a Graph; it is the relation's index in Graph.Relations
+type NodeID = int32
+
+// Graph is an immutable, query-oriented view of a relation set. Names and file paths are interned
+// once, nodes are integer IDs, and calls are stored as forward and reverse adjacency arrays (CSR
+// layout), so traversals touch no maps or string keys. Relations must be in canonical order
+// (SortRelations); node IDs follow that order, which makes any ascending ID list already sorted.
+type Graph struct {
+	Relations []OutRelation // node id i is Relations[i]; strings share the interned symbol table
+
+	symbols   []string // interned names and file paths
+	symbolIDs map[string]int32
+	lower     []string // lower-cased symbols, for case-insensitive search
+	names     []int32  // symbol id of each node's name
+	files     []int32  // symbol id of each node's file path
+	lookup    map[symbolPair]NodeID
+
+	fwdOffsets []int32 // callees of node i are fwd[fwdOffsets[i]:fwdOffsets[i+1]]
+	fwd        []NodeID
+	revOffsets []int32 // callers of node i are rev[revOffsets[i]:revOffsets[i+1]]
+	rev        []NodeID
+	leaves     map[symbolPair][]NodeID // callers of called functions that have no relation (no calls of their own)
+
+	internal   []uint64 // bitset of nodes isInternal reports
+	isInternal func(name string) bool
+}
+
+type symbolPair struct{ name, file int32 }
+
+// NewGraph builds a graph over relations, which must already be sorted with SortRelations. The
+// relations' strings are replaced in place by their interned copies. Calls resolve by name and file
+// path to the first relation with that pair; calls to functions without a relation are kept in
+// Relations but have no edge. isInternal, when not nil, names the helpers closures and roots hide (see
+// EntryPoints.Internal).
+func NewGraph(relations []OutRelation, isInternal func(name string) bool) *Graph {
+	n := len(relations)
+	g := &Graph{
+		Relations: relations,
+		names:     make([]int32, n),
+		files:     make([]int32, n),
+		symbolIDs: make(map[string]int32, n),
+		lookup:    make(map[symbolPair]NodeID, n),
+		leaves:    make(map[symbolPair][]NodeID),
+		internal:  make([]uint64, (n+63)/64),
+	}
+	if isInternal == nil {
+		isInternal = func(string) bool { return false }
+	}
+	g.isInternal = isInternal
+	intern := func(s string) (string, int32) {
+		if id, ok := g.symbolIDs[s]; ok {
+			return g.symbols[id], id
+		}
+		id := int32(len(g.symbols))
+		g.symbolIDs[s] = id
+		g.symbols = append(g.symbols, s)
+		return s, id
+	}
+
+	for i := range relations {
+		r := &relations[i]
+		r.Name, g.names[i] = intern(r.Name)
+		r.FilePath, g.files[i] = intern(r.FilePath)
+		key := symbolPair{g.names[i], g.files[i]}
+		if _, exists := g.lookup[key]; !exists {
+			g.lookup[key] = NodeID(i)
+		}
+		if isInternal(r.Name) {
+			g.internal[i/64] |= 1 << (i % 64)
+		}
+	}
+
+	// Forward adjacency, deduplicated per caller, and in-degree counts for the reverse side
+	g.fwdOffsets = make([]int32, n+1)
+	inDegree := make([]int32, n+1)
+	seen := make(map[NodeID]bool)
+	for i := range relations {
+		clear(seen)
+		called := relations[i].Called
+		for j := range called {
+			c := &called[j]
+			var nameID, fileID int32
+			c.Name, nameID = intern(c.Name)
+			c.FilePath, fileID = intern(c.FilePath)
+			target, ok := g.lookup[symbolPair{nameID, fileID}]
+			if !ok {
+				leaf := symbolPair{nameID, fileID}
+				if callers := g.leaves[leaf]; len(callers) == 0 || callers[len(callers)-1] != NodeID(i) {
+					g.leaves[leaf] = append(callers, NodeID(i))
+				}
+				continue
+			}
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+			g.fwd = append(g.fwd, target)
+			inDegree[target+1]++
+		}
+		g.fwdOffsets[i+1] = int32(len(g.fwd))
+	}
+
+	// Reverse adjacency: prefix sums of in-degrees, then fill in caller order (ascending)
+	g.revOffsets = make([]int32, n+1)
+	for i := 1; i <= n; i++ {
+		g.revOffsets[i] = g.revOffsets[i-1] + inDegree[i]
+	}
+	g.rev = make([]NodeID, len(g.fwd))
+	next := make([]int32, n)
+	copy(next, g.revOffsets[:n])
+	for i := 0; i < n; i++ {
+		for _, target := range g.Callees(NodeID(i)) {
+			g.rev[next[target]] = NodeID(i)
+			next[target]++
+		}
+	}
+
+	g.lower = make([]string, len(g.symbols))
+	for i, s := range g.symbols {
+		g.lower[i] = strings.ToLower(s)
+	}
+	return g
+}
+
+// Len returns the number of nodes
+func (g *Graph) Len() int {
+	return len(g.Relations)
+}
+
+// Lookup returns the node for a function name and file path
+func (g *Graph) Lookup(name, filePath string) (NodeID, bool) {
+	nameID, ok := g.symbolIDs[name]
+	if !ok {
+		return -1, false
+	}
+	fileID, ok := g.symbolIDs[filePath]
+	if !ok {
+		return -1, false
+	}
+	id, ok := g.lookup[symbolPair{nameID, fileID}]
+	return id, ok
+}
+
+// LookupName returns the first node with a function name, in any file
+func (g *Graph) LookupName(name string) (NodeID, bool) {
+	nameID, ok := g.symbolIDs[name]
+	if !ok {
+		return -1, false
+	}
+	for i, id := range g.names {
+		if id == nameID {
+			return NodeID(i), true
+		}
+	}
+	return -1, false
+}
+
+// UniqueName reports whether exactly one file declares a function named name, among nodes and the
+// called functions without a relation
+func (g *Graph) UniqueName(name string) bool {
+	nameID, ok := g.symbolIDs[name]
+	if !ok {
+		return false
+	}
+	file := int32(-1)
+	same := func(f int32) bool {
+		if file == -1 {
+			file = f
+		}
+		return file == f
+	}
+	for i, id := range g.names {
+		if id == nameID && !same(g.files[i]) {
+			return false
+		}
+	}
+	for leaf := range g.leaves {
+		if leaf.name == nameID && !same(leaf.file) {
+			return false
+		}
+	}
+	return file != -1
+}
+
+// Function finds a function by reference: its name, or name@filePath when the name is declared in more
+// than one file. Functions without calls of their own have no node; they are found among the callees and
+// returned with id -1 and the location of the call entry.
+func (g *Graph) Function(ref string) (OutRelation, NodeID, bool) {
+	name, file, byFile := strings.Cut(ref, "@")
+	if byFile {
+		if id, ok := g.Lookup(name, file); ok {
+			return g.Relations[id], id, true
+		}
+	} else if id, ok := g.LookupName(name); ok {
+		return g.Relations[id], id, true
+	}
+	nameID, ok := g.symbolIDs[name]
+	if !ok {
+		return OutRelation{}, -1, false
+	}
+	// Among leaves with the name, the first caller's call entry with the smallest file path wins
+	var found *OutCalled
+	for leaf, callers := range g.leaves {
+		if leaf.name != nameID || (byFile && g.symbols[leaf.file] != file) {
+			continue
+		}
+		for i := range g.Relations[callers[0]].Called {
+			c := &g.Relations[callers[0]].Called[i]
+			if c.Name == name && c.FilePath == g.symbols[leaf.file] && (found == nil || c.FilePath < found.FilePath) {
+				found = c
+				break
+			}
+		}
+	}
+	if found == nil {
+		return OutRelation{}, -1, false
+	}
+	return OutRelation{Name: found.Name, Line: found.Line, FilePath: found.FilePath}, -1, true
+}
+
+// CallersOf returns the distinct nodes calling the function fn (a node or a leaf returned by Function),
+// in ascending order. The slice must not be modified.
+func (g *Graph) CallersOf(fn OutRelation) []NodeID {
+	if id, ok := g.Lookup(fn.Name, fn.FilePath); ok {
+		return g.Callers(id)
+	}
+	return g.leaves[symbolPair{g.symbolIDs[fn.Name], g.symbolIDs[fn.FilePath]}]
+}
+
+// Callees returns the distinct nodes id calls, in call order. The slice must not be modified.
+func (g *Graph) Callees(id NodeID) []NodeID {
+	return g.fwd[g.fwdOffsets[id]:g.fwdOffsets[id+1]]
+}
+
+// Callers returns the distinct nodes calling id, in ascending order. The slice must not be modified.
+func (g *Graph) Callers(id NodeID) []NodeID {
+	return g.rev[g.revOffsets[id]:g.revOffsets[id+1]]
+}
+
+// IsInternal reports whether the node is an internal helper, as named by NewGraph's isInternal
+func (g *Graph) IsInternal(id NodeID) bool {
+	return g.internal[id/64]&(1<<(id%64)) != 0
+}
+
+// Roots returns the nodes no node calls, excluding internal helpers, in ascending order
+func (g *Graph) Roots() []NodeID {
+	var roots []NodeID
+	for i := 0; i < g.Len(); i++ {
+		id := NodeID(i)
+		if len(g.Callers(id)) == 0 && !g.IsInternal(id) {
+			roots = append(roots, id)
+		}
+	}
+	return roots
+}
+
+// CallerClosure returns the nodes calling seeds up to depth levels away (0 for no limit, up to the roots),
+// with seeds themselves at level 1, in ascending order. Internal nodes are traversed but left out of the
+// result unless includeInternals is set.
+func (g *Graph) CallerClosure(seeds []NodeID, depth int, includeInternals bool) []NodeID {
+	visited := make([]uint64, len(g.internal))
+	var level []NodeID
+	push := func(id NodeID) {
+		if visited[id/64]&(1<<(id%64)) == 0 {
+			visited[id/64] |= 1 << (id % 64)
+			level = append(level, id)
+		}
+	}
+	for _, id := range seeds {
+		push(id)
+	}
+	for d := 1; len(level) > 0 && (depth <= 0 || d < depth); d++ {
+		current := level
+		level = nil
+		for _, id := range current {
+			for _, caller := range g.Callers(id) {
+				push(caller)
+			}
+		}
+	}
+
+	if !includeInternals {
+		for i := range visited {
+			visited[i] &^= g.internal[i]
+		}
+	}
+	return bitsetMembers(visited)
+}
+
+// Closure returns every node reachable from seeds (seeds included), in ascending order. Internal
+// nodes are traversed but left out of the result unless includeInternals is set.
+func (g *Graph) Closure(seeds []NodeID, includeInternals bool) []NodeID {
+	nodes, _ := g.BoundedClosure(seeds, includeInternals, ClosureLimits{})
+	return nodes
+}
+
+// ClosureLimits bounds a dependency closure; zero fields mean no limit
+type ClosureLimits struct {
+	MaxDepth int // levels of callees below the seeds
+	MaxNodes int // nodes in the closure, seeds and internal nodes included; seeds are always kept
+}
+
+// BoundedClosure is Closure within limits: nodes are reached breadth first, level by level, until
+// MaxDepth levels or MaxNodes nodes. Truncated lists the nodes of the result with callees left out, the
+// frontier a client expands later; internal nodes left out of the result pass their mark on to the
+// nodes calling them. Both lists are in ascending order.
+func (g *Graph) BoundedClosure(seeds []NodeID, includeInternals bool, limits ClosureLimits) (nodes, truncated []NodeID) {
+	visited := make([]uint64, len(g.internal))
+	isVisited := func(id NodeID) bool { return visited[id/64]&(1<<(id%64)) != 0 }
+	count := 0
+	var level []NodeID
+	push := func(id NodeID) {
+		if !isVisited(id) {
+			visited[id/64] |= 1 << (id % 64)
+			level = append(level, id)
+			count++
+		}
+	}
+	for _, id := range seeds {
+		push(id)
+	}
+	for depth := 0; len(level) > 0 && (limits.MaxDepth <= 0 || depth < limits.MaxDepth); depth++ {
+		current := level
+		level = nil
+		for _, id := range current {
+			for _, callee := range g.Callees(id) {
+				if limits.MaxNodes > 0 && count >= limits.MaxNodes {
+					break
+				}
+				push(callee)
+			}
+		}
+	}
+
+	cut := make([]uint64, len(visited))
+	members := bitsetMembers(visited)
+	for _, id := range members {
+		for _, callee := range g.Callees(id) {
+			if !isVisited(callee) {
+				cut[id/64] |= 1 << (id % 64)
+				break
+			}
+		}
+	}
+	if !includeInternals {
+		// A hidden internal node's missing callees are missing below the nodes calling it
+		var pending []NodeID
+		for _, id := range members {
+			if g.IsInternal(id) && cut[id/64]&(1<<(id%64)) != 0 {
+				pending = append(pending, id)
+			}
+		}
+		for len(pending) > 0 {
+			id := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			for _, caller := range g.Callers(id) {
+				if !isVisited(caller) || cut[caller/64]&(1<<(caller%64)) != 0 {
+					continue
+				}
+				cut[caller/64] |= 1 << (caller % 64)
+				if g.IsInternal(caller) {
+					pending = append(pending, caller)
+				}
+			}
+		}
+		for i := range visited {
+			visited[i] &^= g.internal[i]
+			cut[i] &^= g.internal[i]
+		}
+	}
+	return bitsetMembers(visited), bitsetMembers(cut)
+}
+
+// FunctionRef names a relation the way Function finds it: name@filePath
+func FunctionRef(r OutRelation) string {
+	return r.Name + "@" + r.FilePath
+}
+
+// FunctionRefs returns the FunctionRef of each node
+func (g *Graph) FunctionRefs(ids []NodeID) []string {
+	refs := make([]string, len(ids))
+	for i, id := range ids {
+		refs[i] = FunctionRef(g.Relations[id])
+	}
+	return refs
+}
+
+// Search returns the nodes whose lower-cased name contains lowerQuery, in ascending order. When no
+// name matches, file paths are searched as well.
+func (g *Graph) Search(lowerQuery string) []NodeID {
+	var matches []NodeID
+	for i, nameID := range g.names {
+		if strings.Contains(g.lower[nameID], lowerQuery) {
+			matches = append(matches, NodeID(i))
+		}
+	}
+	if len(matches) > 0 {
+		return matches
+	}
+	for i, fileID := range g.files {
+		if strings.Contains(g.lower[fileID], lowerQuery) {
+			matches = append(matches, NodeID(i))
+		}
+	}
+	return matches
+}
+
+// RelationsOf returns the relations for ids in the given order
+func (g *Graph) RelationsOf(ids []NodeID) []OutRelation {
+	out := make([]OutRelation, len(ids))
+	for i, id := range ids {
+		out[i] = g.Relations[id]
+	}
+	return out
+}
+
+func bitsetMembers(set []uint64) []NodeID {
+	count := 0
+	for _, word := range set {
+		count += bits.OnesCount64(word)
+	}
+	members := make([]NodeID, 0, count)
+	for i, word := range set {
+		for word != 0 {
+			members = append(members, NodeID(i*64+bits.TrailingZeros64(word)))
+			word &= word - 1
+		}
+	}
+	return members
+}
@@ -0,0 +1,110 @@
+package analyzer
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// closureGraph: a.A calls b.B and c.C, b.B calls d.D, d.D calls e.E, and c.C calls the internal h.helper,
+// which calls f.F. Node ids follow the names: a.A 0, b.B 1, c.C 2, d.D 3, e.E 4, f.F 5, h.helper 6.
+func closureGraph() *Graph {
+	call := func(name string) OutCalled { return OutCalled{Name: name, FilePath: name + ".go", Line: 1} }
+	rel := func(name string, calls ...string) OutRelation {
+		r := OutRelation{Name: name, FilePath: name + ".go", Line: 1}
+		for _, c := range calls {
+			r.Called = append(r.Called, call(c))
+		}
+		return r
+	}
+	return NewGraph([]OutRelation{
+		rel("a.A", "b.B", "c.C"),
+		rel("b.B", "d.D"),
+		rel("c.C", "h.helper"),
+		rel("d.D", "e.E"),
+		rel("e.E"),
+		rel("f.F"),
+		rel("h.helper", "f.F"),
+	}, func(name string) bool { return strings.HasPrefix(name, "h.") })
+}
+
+func TestBoundedClosure(t *testing.T) {
+	tests := []struct {
+		name             string
+		seeds            []NodeID
+		includeInternals bool
+		limits           ClosureLimits
+		nodes, truncated []NodeID
+	}{
+		{
+			name:             "unbounded with internals",
+			seeds:            []NodeID{0},
+			includeInternals: true,
+			nodes:            []NodeID{0, 1, 2, 3, 4, 5, 6},
+		},
+		{
+			name:  "unbounded hides internals but walks through them",
+			seeds: []NodeID{0},
+			nodes: []NodeID{0, 1, 2, 3, 4, 5},
+		},
+		{
+			name:             "depth marks the last level",
+			seeds:            []NodeID{0},
+			includeInternals: true,
+			limits:           ClosureLimits{MaxDepth: 1},
+			nodes:            []NodeID{0, 1, 2},
+			truncated:        []NodeID{1, 2},
+		},
+		{
+			name:      "a hidden internal node passes its mark to its caller",
+			seeds:     []NodeID{0},
+			limits:    ClosureLimits{MaxDepth: 2},
+			nodes:     []NodeID{0, 1, 2, 3},
+			truncated: []NodeID{2, 3},
+		},
+		{
+			name:             "node limit",
+			seeds:            []NodeID{0},
+			includeInternals: true,
+			limits:           ClosureLimits{MaxNodes: 3},
+			nodes:            []NodeID{0, 1, 2},
+			truncated:        []NodeID{1, 2},
+		},
+		{
+			name:      "seeds are kept beyond the node limit",
+			seeds:     []NodeID{0, 3},
+			limits:    ClosureLimits{MaxNodes: 1},
+			nodes:     []NodeID{0, 3},
+			truncated: []NodeID{0, 3},
+		},
+		{
+			name:  "below the seed only",
+			seeds: []NodeID{3},
+			nodes: []NodeID{3, 4},
+		},
+		{
+			name:      "several seeds",
+			seeds:     []NodeID{1, 2},
+			limits:    ClosureLimits{MaxDepth: 1},
+			nodes:     []NodeID{1, 2, 3},
+			truncated: []NodeID{2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := closureGraph()
+			nodes, truncated := g.BoundedClosure(tt.seeds, tt.includeInternals, tt.limits)
+			if !slices.Equal(nodes, tt.nodes) {
+				t.Errorf("nodes = %v, want %v", nodes, tt.nodes)
+			}
+			if !slices.Equal(truncated, tt.truncated) {
+				t.Errorf("truncated = %v, want %v", truncated, tt.truncated)
+			}
+			if tt.limits == (ClosureLimits{}) {
+				if all := g.Closure(tt.seeds, tt.includeInternals); !slices.Equal(all, nodes) {
+					t.Errorf("Closure = %v, want %v", all, nodes)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FunctionRange is the extent of a function declaration in a file, named the way the analyzer names
+// functions (package.Function, methods without their receiver type)
+type FunctionRange struct {
+	Name     string `json:"name"`
+	FilePath string `json:"filePath"`
+	Start    int    `json:"start"` // line of the declaration, the relation's line
+	End      int    `json:"end"`   // line of the closing brace
+}
+
+// FunctionRanges returns the functions declared in a Go source file, in source order. Declarations
+// are found the way the analyzer finds them, so names and lines match the relations.
+func FunctionRanges(relPath string, src []byte) []FunctionRange {
+	lines := strings.Split(string(src), "\n")
+	packageName := packageNameOf(lines)
+	var ranges []FunctionRange
+	for i, line := range lines {
+		name := declaredFunction(line)
+		if name == "" {
+			continue
+		}
+		r := FunctionRange{Name: packageName + "." + name, FilePath: filepath.FromSlash(relPath), Start: i + 1, End: i + 1}
+		if _, end := FindFunctionBody(lines, i); end != -1 {
+			r.End = end + 1
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// Function change kinds reported in ChangedFunction.Change
+const (
+	ChangeModified = "modified" // lines inside the function changed
+	ChangeAdded    = "added"    // the function only exists after the change
+	ChangeRemoved  = "removed"  // the function only existed before the change
+)
+
+// ChangedFunction is a function whose lines a diff touches
+type ChangedFunction struct {
+	Name     string `json:"name"`
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+	Change   string `json:"change"`  // ChangeModified, ChangeAdded or ChangeRemoved
+	InGraph  bool   `json:"inGraph"` // found in the call graph, so its callers could be walked
+}
+
+// SourceReader returns the content of a file, by path relative to the repository root
+type SourceReader func(relPath string) ([]byte, error)
+
+// DirReader reads files below root
+func DirReader(root string) SourceReader {
+	return func(relPath string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(root, filepath.FromSlash(relPath)))
+	}
+}
+
+// ChangedFunctions maps the changed lines of a diff to the functions containing them. readNew reads
+// the files after the change and readOld before it; readOld may be nil when the old side is unknown
+// (a patch without its base), in which case only the new side is mapped. Changed lines outside any
+// function (imports, types, comments between declarations) are ignored.
+func ChangedFunctions(changes []FileChange, readNew, readOld SourceReader) ([]ChangedFunction, error) {
+	type key struct {
+		name, file string
+	}
+	found := make(map[key]*ChangedFunction)
+	var order []key
+	record := func(r FunctionRange, change string) {
+		k := key{r.Name, r.FilePath}
+		if existing := found[k]; existing != nil {
+			return
+		}
+		found[k] = &ChangedFunction{Name: r.Name, FilePath: r.FilePath, Line: r.Start, Change: change}
+		order = append(order, k)
+	}
+
+	for _, c := range GoFiles(changes) {
+		var newRanges, oldRanges []FunctionRange
+		if isGoSource(c.NewPath) {
+			src, err := readNew(c.NewPath)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", c.NewPath, err)
+			}
+			newRanges = FunctionRanges(c.NewPath, src)
+		}
+		if readOld != nil && isGoSource(c.OldPath) {
+			src, err := readOld(c.OldPath)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", c.OldPath, err)
+			}
+			oldRanges = FunctionRanges(c.OldPath, src)
+		}
+
+		oldNames := make(map[string]bool, len(oldRanges))
+		for _, r := range oldRanges {
+			oldNames[r.Name] = true
+		}
+		newNames := make(map[string]bool, len(newRanges))
+		for _, r := range newRanges {
+			newNames[r.Name] = true
+		}
+		for _, r := range newRanges {
+			if overlaps(r, c.NewLines) {
+				change := ChangeModified
+				if readOld != nil && !oldNames[r.Name] {
+					change = ChangeAdded
+				}
+				record(r, change)
+			}
+		}
+		for _, r := range oldRanges {
+			if !overlaps(r, c.OldLines) {
+				continue
+			}
+			if !newNames[r.Name] {
+				record(r, ChangeRemoved)
+				continue
+			}
+			// Changed on the old side only (e.g. lines deleted): report it at its new location
+			for _, n := range newRanges {
+				if n.Name == r.Name {
+					record(n, ChangeModified)
+					break
+				}
+			}
+		}
+	}
+
+	changed := make([]ChangedFunction, len(order))
+	for i, k := range order {
+		changed[i] = *found[k]
+	}
+	sort.SliceStable(changed, func(i, j int) bool {
+		if changed[i].FilePath != changed[j].FilePath {
+			return changed[i].FilePath < changed[j].FilePath
+		}
+		return changed[i].Line < changed[j].Line
+	})
+	return changed, nil
+}
+
+func overlaps(r FunctionRange, lines []LineRange) bool {
+	for _, l := range lines {
+		if l.Start <= r.End && l.End >= r.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// ImpactReport is the output of the impact command and the body of /api/impact: the functions a change
+// touches, every function that reaches them through calls, and the entry points among those
+type ImpactReport struct {
+	Base    string            `json:"base,omitempty"` // git revision the diff starts from
+	Head    string            `json:"head,omitempty"` // git revision the diff ends at; "" for the working tree
+	Files   []string          `json:"files"`          // changed non-test Go files
+	Changed []ChangedFunction `json:"changed"`
+	Callers []ImpactedCaller  `json:"callers"` // transitive callers of changed functions, nearest first
+	Roots   []ImpactedCaller  `json:"roots"`   // entry points reaching a changed function, as classified by RootClassifier
+}
+
+// ImpactedCaller is a function that calls a changed function directly or through other calls
+type ImpactedCaller struct {
+	Name     string   `json:"name"`
+	FilePath string   `json:"filePath"`
+	Line     int      `json:"line"`
+	Distance int      `json:"distance"`       // calls between it and the nearest changed function (0 for a changed root)
+	Path     []string `json:"path"`           // a shortest call path from this function to a changed function
+	Kind     string   `json:"kind,omitempty"` // root kind (see RootKinds); only set on roots
+}
+
+// Impact walks the reverse call graph from the changed functions found in g (changed[i].InGraph is set
+// for those). Functions are found by name and file, and by name alone when the file changed and no other
+// file declares the name, so a function is never confused with a namesake in another directory; functions
+// without calls of their own have no node, so the walk starts from the relations calling them. Callers
+// are reached breadth first, so each one's path is a shortest path to a changed function. roots are the
+// classified entry points of g (see RootClassifier.Roots); the reached ones are reported.
+func Impact(g *Graph, changed []ChangedFunction, roots []Root) ImpactReport {
+	report := ImpactReport{Changed: changed, Callers: []ImpactedCaller{}, Roots: []ImpactedCaller{}}
+
+	// Multi-source BFS over callers; next[id] is the callee one step closer to a changed function, or -1
+	// at a changed function (callee holds the name of a changed leaf the node calls)
+	const unvisited = -2
+	next := make([]NodeID, g.Len())
+	distance := make([]int, g.Len())
+	for i := range next {
+		next[i] = unvisited
+	}
+	callee := make(map[NodeID]string)
+	var queue, leafCallers []NodeID
+	for i := range report.Changed {
+		c := &report.Changed[i]
+		fn, id, ok := g.Function(c.Name + "@" + c.FilePath)
+		if !ok && g.UniqueName(c.Name) {
+			fn, id, ok = g.Function(c.Name)
+		}
+		if !ok {
+			continue
+		}
+		c.InGraph = true
+		if id >= 0 {
+			if next[id] == unvisited {
+				next[id] = -1
+				queue = append(queue, id)
+			}
+			continue
+		}
+		for _, caller := range g.CallersOf(fn) {
+			if _, seen := callee[caller]; !seen {
+				callee[caller] = c.Name
+				leafCallers = append(leafCallers, caller)
+			}
+		}
+	}
+	for _, id := range leafCallers {
+		if next[id] == unvisited {
+			next[id], distance[id] = -1, 1
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, caller := range g.Callers(id) {
+			if next[caller] != unvisited {
+				continue
+			}
+			next[caller] = id
+			distance[caller] = distance[id] + 1
+			queue = append(queue, caller)
+		}
+	}
+
+	pathOf := func(id NodeID) []string {
+		var path []string
+		last := id
+		for ; id >= 0; id = next[id] {
+			path = append(path, g.Relations[id].Name)
+			last = id
+		}
+		if distance[last] == 1 {
+			path = append(path, callee[last])
+		}
+		return path
+	}
+	var reached []NodeID
+	for i := range next {
+		if next[i] != unvisited {
+			reached = append(reached, NodeID(i))
+		}
+	}
+	sort.SliceStable(reached, func(i, j int) bool {
+		return distance[reached[i]] < distance[reached[j]]
+	})
+	kinds := make(map[NodeID]string, len(roots))
+	for _, root := range roots {
+		kinds[root.ID] = root.Kind
+	}
+	for _, id := range reached {
+		r := g.Relations[id]
+		entry := ImpactedCaller{Name: r.Name, FilePath: r.FilePath, Line: r.Line, Distance: distance[id], Path: pathOf(id)}
+		if distance[id] > 0 {
+			report.Callers = append(report.Callers, entry)
+		}
+		if kind, ok := kinds[id]; ok {
+			entry.Kind = kind
+			report.Roots = append(report.Roots, entry)
+		}
+	}
+	files := make(map[string]bool)
+	for _, c := range changed {
+		files[c.FilePath] = true
+	}
+	report.Files = make([]string, 0, len(files))
+	for f := range files {
+		report.Files = append(report.Files, f)
+	}
+	sort.Strings(report.Files)
+	return report
+}
+
+// WriteSummary writes the report in human-readable form
+func (r ImpactReport) WriteSummary(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	if r.Base != "" {
+		head := r.Head
+		if head == "" {
+			head = "working tree"
+		}
+		fmt.Fprintf(out, "Impact of %s -> %s\n", r.Base, head)
+	}
+	fmt.Fprintf(out, "%d changed function(s) in %d file(s), %d affected caller(s), %d affected root(s)\n", len(r.Changed), len(r.Files), len(r.Callers), len(r.Roots))
+	if len(r.Changed) > 0 {
+		fmt.Fprintln(out, "\nChanged functions:")
+		for _, c := range r.Changed {
+			note := ""
+			if !c.InGraph && c.Change != ChangeRemoved {
+				note = " (not in the call graph)"
+			}
+			fmt.Fprintf(out, "  %-8s %s  %s:%d%s\n", c.Change, c.Name, c.FilePath, c.Line, note)
+		}
+	}
+	if len(r.Callers) > 0 {
+		fmt.Fprintln(out, "\nAffected callers:")
+		for _, c := range r.Callers {
+			fmt.Fprintf(out, "  %d  %s  %s:%d\n", c.Distance, c.Name, c.FilePath, c.Line)
+		}
+	}
+	if len(r.Roots) > 0 {
+		fmt.Fprintln(out, "\nAffected roots:")
+		for _, c := range r.Roots {
+			fmt.Fprintf(out, "  %-8s %s\n", c.Kind, strings.Join(c.Path, " -> "))
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImpact(t *testing.T) {
+	call := func(name, file string, line int) OutCalled { return OutCalled{Name: name, FilePath: file, Line: line} }
+	// Canonical order; handlers.List is declared in two directories
+	relations := func() []OutRelation {
+		return []OutRelation{
+			{Name: "admin.Serve", FilePath: "admin/s.go", Line: 3, Called: []OutCalled{call("handlers.List", "admin/handlers/l.go", 4)}},
+			{Name: "api.Handle", FilePath: "api/h.go", Line: 3, Called: []OutCalled{call("svc.Do", "svc/do.go", 5)}},
+			{Name: "handlers.List", FilePath: "admin/handlers/l.go", Line: 4, Called: []OutCalled{call("x.Y", "x/y.go", 1)}},
+			{Name: "handlers.List", FilePath: "web/handlers/l.go", Line: 4, Called: []OutCalled{call("x.Y", "x/y.go", 1)}},
+			{Name: "svc.Do", FilePath: "svc/do.go", Line: 5, Called: []OutCalled{call("store.Save", "store/a.go", 2)}},
+			{Name: "web.Serve", FilePath: "web/s.go", Line: 3, Called: []OutCalled{call("handlers.List", "web/handlers/l.go", 4)}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		changed ChangedFunction
+		kinds   []string // root kinds to classify (default: all)
+		inGraph bool
+		callers []string
+		roots   []string
+	}{
+		{
+			name:    "node by name and file",
+			changed: ChangedFunction{Name: "svc.Do", FilePath: "svc/do.go"},
+			inGraph: true,
+			callers: []string{"api.Handle"},
+			roots:   []string{"api.Handle"},
+		},
+		{
+			name:    "unique name in a changed file",
+			changed: ChangedFunction{Name: "svc.Do", FilePath: "svc/moved.go"},
+			inGraph: true,
+			callers: []string{"api.Handle"},
+			roots:   []string{"api.Handle"},
+		},
+		{
+			name:    "leaf without calls of its own",
+			changed: ChangedFunction{Name: "store.Save", FilePath: "store/b.go"},
+			inGraph: true,
+			callers: []string{"svc.Do", "api.Handle"},
+			roots:   []string{"api.Handle"},
+		},
+		{
+			name:    "ambiguous name by file",
+			changed: ChangedFunction{Name: "handlers.List", FilePath: "web/handlers/l.go"},
+			inGraph: true,
+			callers: []string{"web.Serve"},
+			roots:   []string{"web.Serve"},
+		},
+		{
+			name:    "roots are those the classifier lists",
+			changed: ChangedFunction{Name: "svc.Do", FilePath: "svc/do.go"},
+			kinds:   []string{RootMain},
+			inGraph: true,
+			callers: []string{"api.Handle"},
+		},
+		{
+			name:    "ambiguous name in a changed file",
+			changed: ChangedFunction{Name: "handlers.List", FilePath: "web/handlers/new.go"},
+		},
+		{
+			name:    "unknown function",
+			changed: ChangedFunction{Name: "svc.New", FilePath: "svc/new.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewRootClassifier(EntryPoints{Kinds: tt.kinds})
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := NewGraph(relations(), c.IsInternal)
+			report := Impact(g, []ChangedFunction{tt.changed}, c.Roots(g, nil))
+			if got := report.Changed[0].InGraph; got != tt.inGraph {
+				t.Errorf("InGraph = %t, want %t", got, tt.inGraph)
+			}
+			names := func(callers []ImpactedCaller) []string {
+				var out []string
+				for _, c := range callers {
+					out = append(out, c.Name)
+				}
+				return out
+			}
+			if got := names(report.Callers); !reflect.DeepEqual(got, tt.callers) {
+				t.Errorf("callers = %v, want %v", got, tt.callers)
+			}
+			if got := names(report.Roots); !reflect.DeepEqual(got, tt.roots) {
+				t.Errorf("roots = %v, want %v", got, tt.roots)
+			}
+			for _, root := range report.Roots {
+				if root.Kind != RootAPI {
+					t.Errorf("root %s kind = %q, want %q", root.Name, root.Kind, RootAPI)
+				}
+			}
+		})
+	}
+}
+
+func TestGraphUniqueName(t *testing.T) {
+	g := NewGraph([]OutRelation{
+		{Name: "a.F", FilePath: "a/f.go", Line: 1, Called: []OutCalled{{Name: "b.Leaf", FilePath: "b/l.go", Line: 1}, {Name: "h.G", FilePath: "x/h/g.go", Line: 1}}},
+		{Name: "a.F", FilePath: "a/f.go", Line: 9},
+		{Name: "h.G", FilePath: "y/h/g.go", Line: 1},
+	}, nil)
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"a.F", true},    // declared twice in the same file
+		{"b.Leaf", true}, // only called
+		{"h.G", false},   // a node and a leaf in different directories
+		{"c.Missing", false},
+	}
+	for _, tt := range tests {
+		if got := g.UniqueName(tt.name); got != tt.want {
+			t.Errorf("UniqueName(%q) = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ImportTable is the per-file view of import declarations used to rewrite
+// qualified calls (alias.Func) to the identity of the imported package.
+type ImportTable struct {
+	Aliases map[string]ImportInfo // local name -> import
+	Dot     []ImportInfo          // dot imports in source order
+	Blank   []ImportInfo          // blank imports (side effects only, never referenced by calls)
+
+	// LocalQualifier, when set, replaces the package name of module-local imports.
+	// External modules name every function after the module path, so their tables set this.
+	LocalQualifier string
+
+	ownDecls map[string]bool // top-level funcs and types declared by the file's own package
+}
+
+// reUnqualifiedCall matches exported identifiers called without a package qualifier (e.g. Open(...))
+var reUnqualifiedCall = regexp.MustCompile(`(?:^|[^\w.])([A-Z]\w*)\(`)
+
+// ParseImportTable parses the import block of a Go file. Module-local imports are resolved to the
+// package name declared in their directory so calls match the names FindFunctions produces.
+func ParseImportTable(filePath, absPath, module string) (ImportTable, error) {
+	return newImportResolver().parse(filePath, absPath, module)
+}
+
+// importResolver builds import tables, reading each imported package directory once. One resolver
+// serves one run, so directories changed between runs are read again. Safe for concurrent use.
+type importResolver struct {
+	mu    sync.Mutex
+	names map[string]string          // directory -> package name
+	decls map[string]map[string]bool // directory -> top-level declarations
+}
+
+func newImportResolver() *importResolver {
+	return &importResolver{names: make(map[string]string), decls: make(map[string]map[string]bool)}
+}
+
+// parse is ParseImportTable through the resolver's directory cache
+func (r *importResolver) parse(filePath, absPath, module string) (ImportTable, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filePath, nil, parser.ImportsOnly)
+	if err != nil {
+		return ImportTable{}, err
+	}
+	return r.table(node, filePath, absPath, module), nil
+}
+
+// packageName is packageNameInDir, memoized
+func (r *importResolver) packageName(dir string) string {
+	r.mu.Lock()
+	name, ok := r.names[dir]
+	r.mu.Unlock()
+	if ok {
+		return name
+	}
+	name = packageNameInDir(dir)
+	r.mu.Lock()
+	r.names[dir] = name
+	r.mu.Unlock()
+	return name
+}
+
+// packageDecls is packageDecls, memoized. The returned map is shared and must not be modified.
+func (r *importResolver) packageDecls(dir string) map[string]bool {
+	r.mu.Lock()
+	decls, ok := r.decls[dir]
+	r.mu.Unlock()
+	if ok {
+		return decls
+	}
+	decls = packageDecls(dir)
+	r.mu.Lock()
+	r.decls[dir] = decls
+	r.mu.Unlock()
+	return decls
+}
+
+// table builds the import table of an already parsed file
+func (r *importResolver) table(node *ast.File, filePath, absPath, module string) ImportTable {
+	table := ImportTable{Aliases: make(map[string]ImportInfo)}
+	for _, imp := range node.Imports {
+		importPath := strings.Trim(imp.Path.Value, `"`)
+		info := ImportInfo{
+			Path:        importPath,
+			PackageName: guessPackageName(importPath),
+		}
+		// Module paths need no dot (module myapp), so locality is decided first
+		if module != "" && (importPath == module || strings.HasPrefix(importPath, module+"/")) {
+			info.IsLocal = true
+			dir := filepath.Join(absPath, filepath.FromSlash(strings.TrimPrefix(importPath, module)))
+			if name := r.packageName(dir); name != "" {
+				info.PackageName = name
+			}
+		} else {
+			info.IsStdlib = isStdlibImport(importPath)
+		}
+
+		if imp.Name != nil {
+			info.Alias = imp.Name.Name
+		}
+		switch info.Alias {
+		case "_":
+			table.Blank = append(table.Blank, info)
+		case ".":
+			if info.IsLocal {
+				info.decls = r.packageDecls(filepath.Join(absPath, filepath.FromSlash(strings.TrimPrefix(importPath, module))))
+			}
+			table.Dot = append(table.Dot, info)
+		case "":
+			table.Aliases[info.PackageName] = info
+		default:
+			table.Aliases[info.Alias] = info
+		}
+	}
+
+	if len(table.Dot) > 0 {
+		table.ownDecls = r.packageDecls(filepath.Dir(filePath))
+	}
+	return table
+}
+
+// ResolveCall rewrites a qualified call through the import table.
+// It returns false when the call targets the standard library and should be dropped.
+// Calls whose first segment is not an import (method calls on variables) are returned unchanged.
+func (t ImportTable) ResolveCall(call string) (string, bool) {
+	if info, ok := t.importOf(call); ok && info.IsStdlib {
+		return "", false
+	}
+	return t.RewriteCall(call), true
+}
+
+// RewriteCall is ResolveCall without the filtering: standard library calls are kept, qualified by
+// their import path
+func (t ImportTable) RewriteCall(call string) string {
+	info, ok := t.importOf(call)
+	if !ok {
+		return call
+	}
+	return t.qualifier(info) + call[strings.Index(call, "."):]
+}
+
+// importOf returns the import the first segment of a qualified call names
+func (t ImportTable) importOf(call string) (ImportInfo, bool) {
+	dot := strings.Index(call, ".")
+	if dot == -1 {
+		return ImportInfo{}, false
+	}
+	info, ok := t.Aliases[call[:dot]]
+	return info, ok
+}
+
+// ResolveDotImportCalls finds exported, unqualified calls in a function body that belong to a dot-imported
+// package and returns them qualified with that package. Names declared by the file's own package are skipped.
+func (t ImportTable) ResolveDotImportCalls(bodyLines []string) []string {
+	if len(t.Dot) == 0 {
+		return nil
+	}
+
+	var calls []string
+	for _, line := range bodyLines {
+		for _, match := range reUnqualifiedCall.FindAllStringSubmatch(line, -1) {
+			name := match[1]
+			if t.ownDecls[name] {
+				continue
+			}
+			info, ok := t.dotImportFor(name)
+			if !ok || info.IsStdlib {
+				continue
+			}
+			call := t.qualifier(info) + "." + name
+			if !contains(calls, call) {
+				calls = append(calls, call)
+			}
+		}
+	}
+	return calls
+}
+
+// dotImportFor picks the dot import that declares name. Module-local packages are checked against
+// their declarations; otherwise a single external dot import is assumed to be the owner.
+func (t ImportTable) dotImportFor(name string) (ImportInfo, bool) {
+	var external []ImportInfo
+	for _, info := range t.Dot {
+		if info.IsLocal {
+			if info.decls == nil {
+				continue
+			}
+			if info.decls[name] {
+				return info, true
+			}
+			continue
+		}
+		external = append(external, info)
+	}
+	if len(external) == 1 {
+		return external[0], true
+	}
+	return ImportInfo{}, false
+}
+
+func (t ImportTable) qualifier(info ImportInfo) string {
+	if info.IsLocal {
+		if t.LocalQualifier != "" {
+			return t.LocalQualifier
+		}
+		return info.PackageName
+	}
+	return info.Path
+}
+
+// isStdlibImport reports whether an import path belongs to the standard library (no dot in the first element)
+func isStdlibImport(importPath string) bool {
+	first := importPath
+	if slash := strings.Index(importPath, "/"); slash != -1 {
+		first = importPath[:slash]
+	}
+	return !strings.Contains(first, ".")
+}
+
+// guessPackageName derives the conventional package name from an import path,
+// e.g. "gopkg.in/yaml.v3" -> "yaml", "github.com/go-redis/redis/v9" -> "redis", "github.com/x/go-foo" -> "foo".
+func guessPackageName(importPath string) string {
+	parts := strings.Split(importPath, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && isMajorVersion(name) {
+		name = parts[len(parts)-2]
+	}
+	if dot := strings.Index(name, ".v"); dot != -1 && isMajorVersion(name[dot+1:]) {
+		name = name[:dot]
+	}
+	name = strings.TrimSuffix(name, ".go")
+	name = strings.TrimPrefix(name, "go-")
+	return strings.ReplaceAll(name, "-", "")
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, r := range s[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// packageNameInDir reads the package clause of the first non-test Go file in dir
+func packageNameInDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		node, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err == nil {
+			return node.Name.Name
+		}
+	}
+	return ""
+}
+
+// packageDecls collects the top-level function and type names declared by the non-test files in dir
+func packageDecls(dir string) map[string]bool {
+	decls := make(map[string]bool)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return decls
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		node, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, decl := range node.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					decls[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						decls[ts.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
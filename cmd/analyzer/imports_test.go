@@ -0,0 +1,202 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates files (slash-separated paths relative to dir) with the given contents
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		module  string
+		imports string
+		call    string
+		want    string
+		keep    bool
+	}{
+		{"dotless module", "myapp", `import "myapp/store"`, "store.Open", "store.Open", true},
+		{"dotless module, package name differs from directory", "myapp", `import "myapp/db"`, "database.Open", "database.Open", true},
+		{"dotless module root", "myapp", `import "myapp"`, "myapp.Run", "myapp.Run", true},
+		{"aliased local import", "example.com/m", `import st "example.com/m/store"`, "st.Open", "store.Open", true},
+		{"aliased external import", "example.com/m", `import yaml "gopkg.in/yaml.v3"`, "yaml.Marshal", "gopkg.in/yaml.v3.Marshal", true},
+		{"guessed external package name", "example.com/m", `import "github.com/go-redis/redis/v9"`, "redis.NewClient", "github.com/go-redis/redis/v9.NewClient", true},
+		{"stdlib import", "example.com/m", `import "strings"`, "strings.Split", "", false},
+		{"aliased stdlib import", "example.com/m", `import str "strings"`, "str.Split", "", false},
+		{"stdlib import in a dotless module", "myapp", `import "net/http"`, "http.Get", "", false},
+		{"method call on a variable", "example.com/m", `import "strings"`, "svc.Run", "svc.Run", true},
+		{"unqualified call", "example.com/m", `import "strings"`, "helper", "helper", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				"store/store.go": "package store\n",
+				"db/db.go":       "package database\n",
+				"main.go":        "package main\n\n" + tt.imports + "\n",
+			})
+			table, err := ParseImportTable(filepath.Join(root, "main.go"), root, tt.module)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, keep := table.ResolveCall(tt.call)
+			if got != tt.want || keep != tt.keep {
+				t.Errorf("ResolveCall(%q) = %q, %t, want %q, %t", tt.call, got, keep, tt.want, tt.keep)
+			}
+		})
+	}
+}
+
+func TestRewriteCallKeepsStdlib(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go": "package main\n\nimport (\n\t\"strings\"\n\tst \"myapp/store\"\n)\n",
+	})
+	table, err := ParseImportTable(filepath.Join(root, "main.go"), root, "myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for call, want := range map[string]string{"strings.Split": "strings.Split", "st.Open": "store.Open", "x.Run": "x.Run"} {
+		if got := table.RewriteCall(call); got != want {
+			t.Errorf("RewriteCall(%q) = %q, want %q", call, got, want)
+		}
+	}
+}
+
+func TestImportResolverReadsDirectoriesOnce(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"db/db.go": "package database\n\nfunc Open() {}\n",
+		"a.go":     "package main\n\nimport . \"myapp/db\"\n",
+		"b.go":     "package main\n\nimport \"myapp/db\"\n",
+	})
+	resolver := newImportResolver()
+	if _, err := resolver.parse(filepath.Join(root, "a.go"), root, "myapp"); err != nil {
+		t.Fatal(err)
+	}
+	// Later files of the same run see the directory as it was first read
+	writeFiles(t, root, map[string]string{"db/db.go": "package store\n"})
+	table, err := resolver.parse(filepath.Join(root, "b.go"), root, "myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.Aliases["database"]; !ok {
+		t.Errorf("cached run aliases = %v, want database", table.Aliases)
+	}
+	if len(resolver.names) != 1 || len(resolver.decls) != 2 {
+		t.Errorf("resolver read %d package names and %d declaration sets, want 1 and 2", len(resolver.names), len(resolver.decls))
+	}
+	// A new run reads it again
+	table, err = ParseImportTable(filepath.Join(root, "b.go"), root, "myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.Aliases["store"]; !ok {
+		t.Errorf("new run aliases = %v, want store", table.Aliases)
+	}
+}
+
+func TestResolveDotImportCalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		module  string
+		imports string
+		body    []string
+		want    []string
+	}{
+		{
+			name:    "local dot import in a dotless module",
+			module:  "myapp",
+			imports: `import . "myapp/store"`,
+			body:    []string{"\tdb := Open()", "\tClose(db)"},
+			want:    []string{"store.Open"},
+		},
+		{
+			name:    "own declarations win over the dot import",
+			module:  "myapp",
+			imports: `import . "myapp/store"`,
+			body:    []string{"\tLocal()", "\tOpen()"},
+			want:    []string{"store.Open"},
+		},
+		{
+			name:    "single external dot import",
+			module:  "example.com/m",
+			imports: `import . "github.com/onsi/gomega"`,
+			body:    []string{"\tExpect(x).To(Equal(1))"},
+			want:    []string{"github.com/onsi/gomega.Expect", "github.com/onsi/gomega.Equal"},
+		},
+		{
+			name:    "stdlib dot import",
+			module:  "example.com/m",
+			imports: `import . "strings"`,
+			body:    []string{"\tSplit(s, \",\")"},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				"store/store.go": "package store\n\nfunc Open() {}\n",
+				"main.go":        "package main\n\n" + tt.imports + "\n\nfunc Local() {}\n\nfunc Close(x int) {}\n",
+			})
+			table, err := ParseImportTable(filepath.Join(root, "main.go"), root, tt.module)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := table.ResolveDotImportCalls(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveDotImportCalls() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindFunctionsDotlessModule(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":         "module myapp\n",
+		"store/store.go": "package store\n\nfunc Open() {\n}\n",
+		"main.go": `package main
+
+import (
+	"myapp/store"
+	"net/http"
+)
+
+func main() {
+	store.Open()
+	http.ListenAndServe(":8080", nil)
+}
+`,
+	})
+	main := filepath.Join(root, "main.go")
+	funcs, err := FindFunctions(main, root, "myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(funcs) != 1 || !reflect.DeepEqual(funcs[0].Calls, []string{"store.Open"}) {
+		t.Errorf("FindFunctions() = %+v, want main.main calling store.Open", funcs)
+	}
+	all, err := FindFunctionsWithAllCalls(main, root, "myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || !reflect.DeepEqual(all[0].Calls, []string{"store.Open", "net/http.ListenAndServe"}) {
+		t.Errorf("FindFunctionsWithAllCalls() = %+v, want main.main calling store.Open and net/http.ListenAndServe", all)
+	}
+}
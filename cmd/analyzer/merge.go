@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// FunctionLocation identifies where a function is defined
+type FunctionLocation struct {
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+}
+
+// MergeConflict reports a function name that is defined at more than one location after merging.
+// BuildRelations resolves calls by name, so only Kept is reachable through call edges.
+type MergeConflict struct {
+	Name    string             `json:"name"`
+	Kept    FunctionLocation   `json:"kept"`
+	Dropped []FunctionLocation `json:"dropped"`
+}
+
+// MergeFunctions unifies FunctionInfo entries that describe the same function (same name, file and line),
+// combining their call sets in first-seen order. Entry order is preserved by first occurrence, so
+// callers should pass scanned functions before synthesized ones (e.g. interface implementations).
+// Names that remain defined at several locations are returned as conflicts.
+func MergeFunctions(functions []FunctionInfo) ([]FunctionInfo, []MergeConflict) {
+	identity := func(f FunctionInfo) string {
+		return f.Name + "|" + f.FilePath + "|" + strconv.Itoa(f.Line)
+	}
+
+	merged := make([]FunctionInfo, 0, len(functions))
+	position := make(map[string]int, len(functions))
+	for _, f := range functions {
+		key := identity(f)
+		if i, ok := position[key]; ok {
+			for _, call := range f.Calls {
+				merged[i].Calls = appendUnique(merged[i].Calls, call)
+			}
+			continue
+		}
+		var calls []string
+		for _, call := range f.Calls {
+			calls = appendUnique(calls, call)
+		}
+		f.Calls = calls
+		position[key] = len(merged)
+		merged = append(merged, f)
+	}
+
+	// Any name still present more than once is ambiguous for call resolution
+	var conflicts []MergeConflict
+	conflictIndex := make(map[string]int)
+	firstByName := make(map[string]FunctionInfo, len(merged))
+	for _, f := range merged {
+		first, seen := firstByName[f.Name]
+		if !seen {
+			firstByName[f.Name] = f
+			continue
+		}
+		i, ok := conflictIndex[f.Name]
+		if !ok {
+			i = len(conflicts)
+			conflictIndex[f.Name] = i
+			conflicts = append(conflicts, MergeConflict{
+				Name: f.Name,
+				Kept: FunctionLocation{FilePath: first.FilePath, Line: first.Line},
+			})
+		}
+		conflicts[i].Dropped = append(conflicts[i].Dropped, FunctionLocation{FilePath: f.FilePath, Line: f.Line})
+	}
+
+	return merged, conflicts
+}
+
+// reportMergeConflicts prints merge conflicts in the same warning style as the rest of the analyzer
+func reportMergeConflicts(conflicts []MergeConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %d function names are defined at multiple locations; calls resolve to the first definition\n", len(conflicts))
+	for _, c := range conflicts {
+		fmt.Fprintf(os.Stderr, "  %s: kept %s:%d, also at", c.Name, c.Kept.FilePath, c.Kept.Line)
+		for _, d := range c.Dropped {
+			fmt.Fprintf(os.Stderr, " %s:%d", d.FilePath, d.Line)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+}
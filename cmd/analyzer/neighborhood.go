@@ -0,0 +1,138 @@
+package analyzer
+
+import "sort"
+
+// MaxNeighborhoodHops caps the up and down hops of a neighborhood
+const MaxNeighborhoodHops = 10
+
+// Edge directions in a neighborhood, after the walk that found the edge
+const (
+	DirectionCaller = "caller" // walking up from the function toward its callers
+	DirectionCallee = "callee" // walking down from the function toward its callees
+)
+
+// NeighborhoodNode is a function in a neighborhood with its distance from the center in each direction
+type NeighborhoodNode struct {
+	ID       string `json:"id"` // name@filePath, usable as the id of the other function endpoints
+	Name     string `json:"name"`
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+	Center   bool   `json:"center,omitempty"`
+	Up       int    `json:"up,omitempty"`   // caller hops from the center, when it calls the center
+	Down     int    `json:"down,omitempty"` // callee hops from the center, when the center calls it
+}
+
+// NeighborhoodEdge is a call from one function of a neighborhood to another
+type NeighborhoodEdge struct {
+	From      string `json:"from"` // caller id
+	To        string `json:"to"`   // callee id
+	Direction string `json:"direction"`
+}
+
+// Neighborhood returns the functions within up caller hops and down callee hops of fn (as returned by
+// Function), with the calls the two walks followed. The center comes first, then callers by distance,
+// then callees by distance, each in the order found. Internal functions other than the center are neither
+// shown nor walked through unless includeInternals is set.
+func (g *Graph) Neighborhood(fn OutRelation, up, down int, includeInternals bool) ([]NeighborhoodNode, []NeighborhoodEdge) {
+	center := FunctionRef(fn)
+	nodes := map[string]*NeighborhoodNode{center: {ID: center, Name: fn.Name, FilePath: fn.FilePath, Line: fn.Line, Center: true}}
+	order := []string{center}
+	add := func(r OutRelation) *NeighborhoodNode {
+		id := FunctionRef(r)
+		n := nodes[id]
+		if n == nil {
+			n = &NeighborhoodNode{ID: id, Name: r.Name, FilePath: r.FilePath, Line: r.Line}
+			nodes[id] = n
+			order = append(order, id)
+		}
+		return n
+	}
+	var edges []NeighborhoodEdge
+	seenEdges := make(map[[2]string]bool)
+	addEdge := func(from, to, direction string) {
+		if key := [2]string{from, to}; !seenEdges[key] {
+			seenEdges[key] = true
+			edges = append(edges, NeighborhoodEdge{From: from, To: to, Direction: direction})
+		}
+	}
+	hidden := func(name string) bool {
+		return !includeInternals && g.isInternal(name)
+	}
+
+	// Up: breadth first over callers; the center may be a leaf without a node
+	visited := make(map[NodeID]bool)
+	level := []OutRelation{fn}
+	for hop := 1; hop <= up && len(level) > 0; hop++ {
+		var next []OutRelation
+		for _, callee := range level {
+			for _, caller := range g.CallersOf(callee) {
+				r := g.Relations[caller]
+				if hidden(r.Name) {
+					continue
+				}
+				addEdge(FunctionRef(r), FunctionRef(callee), DirectionCaller)
+				if visited[caller] || FunctionRef(r) == center {
+					continue
+				}
+				visited[caller] = true
+				add(r).Up = hop
+				next = append(next, r)
+			}
+		}
+		level = next
+	}
+
+	// Down: breadth first over call entries, so callees without calls of their own are included
+	clear(visited)
+	seenLeaves := make(map[string]bool)
+	level = []OutRelation{fn}
+	for hop := 1; hop <= down && len(level) > 0; hop++ {
+		var next []OutRelation
+		for _, caller := range level {
+			for _, called := range caller.Called {
+				if hidden(called.Name) {
+					continue
+				}
+				r := OutRelation{Name: called.Name, Line: called.Line, FilePath: called.FilePath}
+				id, isNode := g.Lookup(called.Name, called.FilePath)
+				if isNode {
+					r = g.Relations[id]
+				}
+				ref := FunctionRef(r)
+				addEdge(FunctionRef(caller), ref, DirectionCallee)
+				if ref == center || (isNode && visited[id]) || (!isNode && seenLeaves[ref]) {
+					continue
+				}
+				if isNode {
+					visited[id] = true
+					next = append(next, r)
+				} else {
+					seenLeaves[ref] = true
+				}
+				if n := add(r); n.Down == 0 {
+					n.Down = hop
+				}
+			}
+		}
+		level = next
+	}
+
+	result := make([]NeighborhoodNode, len(order))
+	for i, id := range order {
+		result[i] = *nodes[id]
+	}
+	sort.SliceStable(result[1:], func(i, j int) bool {
+		a, b := result[1+i], result[1+j]
+		if (a.Up > 0) != (b.Up > 0) {
+			return a.Up > 0
+		}
+		if a.Up != b.Up {
+			return a.Up < b.Up
+		}
+		return a.Down < b.Down
+	})
+	if edges == nil {
+		edges = []NeighborhoodEdge{}
+	}
+	return result, edges
+}
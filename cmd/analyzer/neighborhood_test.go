@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNeighborhood(t *testing.T) {
+	tests := []struct {
+		name             string
+		graph            func() *Graph
+		center           string
+		up, down         int
+		includeInternals bool
+		nodes            []string // name up down
+		edges            []string // caller>callee direction
+	}{
+		{
+			name:   "callers and callees",
+			graph:  closureGraph,
+			center: "b.B", up: 1, down: 2,
+			nodes: []string{"b.B 0 0", "a.A 1 0", "d.D 0 1", "e.E 0 2"},
+			edges: []string{"a.A>b.B caller", "b.B>d.D callee", "d.D>e.E callee"},
+		},
+		{
+			name:   "hops limit the walks",
+			graph:  closureGraph,
+			center: "b.B", up: 0, down: 1,
+			nodes: []string{"b.B 0 0", "d.D 0 1"},
+			edges: []string{"b.B>d.D callee"},
+		},
+		{
+			name:   "internal functions are neither shown nor walked through",
+			graph:  closureGraph,
+			center: "f.F", up: 2, down: 0,
+			nodes: []string{"f.F 0 0"},
+			edges: []string{},
+		},
+		{
+			name:   "internal functions with includeInternals",
+			graph:  closureGraph,
+			center: "f.F", up: 2, down: 0, includeInternals: true,
+			nodes: []string{"f.F 0 0", "h.helper 1 0", "c.C 2 0"},
+			edges: []string{"h.helper>f.F caller", "c.C>h.helper caller"},
+		},
+		{
+			name:   "leaf center",
+			graph:  pathsGraph,
+			center: "e.E", up: 2, down: 1,
+			nodes: []string{"e.E 0 0", "d.D 1 0", "b.B 2 0", "c.C 2 0"},
+			edges: []string{"d.D>e.E caller", "b.B>d.D caller", "c.C>d.D caller"},
+		},
+		{
+			name:   "cycle through the center",
+			graph:  pathsGraph,
+			center: "a.A", up: 1, down: 3,
+			nodes: []string{"a.A 0 0", "d.D 1 2", "b.B 0 1", "c.C 0 1", "e.E 0 3"},
+			edges: []string{
+				"d.D>a.A caller",
+				"a.A>b.B callee", "a.A>c.C callee", "b.B>c.C callee", "b.B>d.D callee", "c.C>d.D callee", "d.D>e.E callee",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := tt.graph()
+			fn, _, ok := g.Function(tt.center)
+			if !ok {
+				t.Fatalf("no function %s", tt.center)
+			}
+			nodes, edges := g.Neighborhood(fn, tt.up, tt.down, tt.includeInternals)
+			var gotNodes []string
+			for _, n := range nodes {
+				gotNodes = append(gotNodes, fmt.Sprintf("%s %d %d", n.Name, n.Up, n.Down))
+			}
+			if !reflect.DeepEqual(gotNodes, tt.nodes) {
+				t.Errorf("nodes = %q, want %q", gotNodes, tt.nodes)
+			}
+			if !nodes[0].Center {
+				t.Errorf("first node %s is not the center", nodes[0].Name)
+			}
+			gotEdges := []string{}
+			for _, e := range edges {
+				from, _, _ := strings.Cut(e.From, "@")
+				to, _, _ := strings.Cut(e.To, "@")
+				gotEdges = append(gotEdges, from+">"+to+" "+e.Direction)
+			}
+			if !reflect.DeepEqual(gotEdges, tt.edges) {
+				t.Errorf("edges = %q, want %q", gotEdges, tt.edges)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ParseOptions configures ParseProject
+type ParseOptions struct {
+	Module   string     // module path from go.mod, used to resolve module-local imports
+	Workers  int        // parallel parsers; <= 0 uses GOMAXPROCS
+	AllCalls bool       // also extract unfiltered calls, needed by ScanExternalModules
+	Cache    *FileCache // per-file results of earlier runs; may be nil
+
+	// Progress, when set, is called with PhaseWalking as files are found and with PhaseParsing as they
+	// are parsed or restored from Cache. It may be called from several goroutines at once.
+	Progress func(phase string, done, total int)
+}
+
+// Analysis phases, in order, as reported by ParseOptions.Progress and the server's reload jobs
+const (
+	PhaseCheckout          = "checkout" // materializing a git ref (server reload jobs only)
+	PhaseWalking           = "walking"
+	PhaseParsing           = "parsing"
+	PhaseExternalScan      = "external-scan"
+	PhaseTypeResolution    = "type-resolution"
+	PhaseBuildingRelations = "building-relations"
+)
+
+// walkProgressEvery is how many found files pass between PhaseWalking reports
+const walkProgressEvery = 100
+
+// ParsedFile holds everything the analysis stages need from one project file
+type ParsedFile struct {
+	Path      string         // absolute path
+	RelPath   string         // path relative to the project root
+	Functions []FunctionInfo // as returned by FindFunctions
+	AllCalls  []FunctionInfo // as returned by FindFunctionsWithAllCalls (ParseOptions.AllCalls only)
+	Types     *FileTypeInfo  // as returned by ParseGoFileForTypesAndImports; nil when the file does not parse
+	ParseErr  error          // go/parser error, if any
+
+	impl        *fileImplFacts // interfaces and methods for FindInterfaceImplementations
+	hasAllCalls bool           // AllCalls was extracted
+	volatile    bool           // has dot imports, so results also depend on other files
+}
+
+// Project is the output of the shared parsing phase: every non-test Go file under Root, read and parsed
+// once, in filepath.Walk order. Later stages (type resolution, interface detection, external scanning)
+// consume it instead of walking and reparsing the tree themselves.
+type Project struct {
+	Root    string
+	Module  string
+	Files   []*ParsedFile
+	Workers int
+	Cache   *FileCache
+}
+
+// ParseProject reads and parses every non-test Go file under root on a bounded worker pool. Each file is
+// read once and handed to go/parser at most once; unchanged files are served from opts.Cache. It stops
+// early with ctx.Err() when ctx is cancelled.
+func ParseProject(ctx context.Context, root string, opts ParseOptions) (*Project, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	project := &Project{Root: root, Module: opts.Module, Workers: workers, Cache: opts.Cache}
+	progress := opts.Progress
+	if progress == nil {
+		progress = func(string, int, int) {}
+	}
+
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
+			paths = append(paths, path)
+			if len(paths)%walkProgressEvery == 0 {
+				progress(PhaseWalking, len(paths), 0)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	progress(PhaseWalking, len(paths), len(paths))
+
+	project.Files = make([]*ParsedFile, len(paths))
+	for i, path := range paths {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil, err
+		}
+		project.Files[i] = &ParsedFile{Path: path, RelPath: rel}
+	}
+
+	// With a cache, hash contents first: cached entries are only valid when no package clause changed.
+	// Each worker holds one file's content at a time, so changed files are read again to be parsed.
+	cached := make([]bool, len(paths))
+	hashes := make([]string, len(paths))
+	var done atomic.Int64
+	if opts.Cache != nil {
+		clauses := make([]string, len(paths))
+		err := forEachParallel(ctx, workers, len(paths), func(i int) error {
+			content, err := os.ReadFile(paths[i])
+			if err != nil {
+				return err
+			}
+			hashes[i] = contentHash(content)
+			clauses[i] = filepath.ToSlash(project.Files[i].RelPath) + "=" + packageClause(content)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		opts.Cache.prepare(opts.Module, clauses)
+		for i, file := range project.Files {
+			if cached[i] = opts.Cache.restore(file, hashes[i], opts.AllCalls); cached[i] {
+				done.Add(1)
+			}
+		}
+	}
+	progress(PhaseParsing, int(done.Load()), len(paths))
+
+	resolver := newImportResolver()
+	err = forEachParallel(ctx, workers, len(paths), func(i int) error {
+		if cached[i] {
+			return nil
+		}
+		content, err := os.ReadFile(paths[i])
+		if err != nil {
+			return err
+		}
+		if opts.Cache != nil {
+			// The file is recorded under the hash of the content parsed, even when it changed since it was
+			// hashed above (e.g. while a watcher reloads)
+			hashes[i] = contentHash(content)
+		}
+		parseFile(project.Files[i], content, root, opts, resolver)
+		progress(PhaseParsing, int(done.Add(1)), len(paths))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Cache != nil {
+		for i, file := range project.Files {
+			if !cached[i] {
+				opts.Cache.record(file, hashes[i])
+			}
+		}
+	}
+	return project, nil
+}
+
+// contentHash returns the hex SHA-256 of a file's content, the key of its FileCache entry
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseFile fills in file from its content with a single go/parser pass
+func parseFile(file *ParsedFile, content []byte, root string, opts ParseOptions, resolver *importResolver) {
+	lines := strings.Split(string(content), "\n")
+
+	fset := token.NewFileSet()
+	node, parseErr := parser.ParseFile(fset, file.Path, content, parser.ParseComments)
+	imports := ImportTable{}
+	if parseErr == nil {
+		imports = resolver.table(node, file.Path, root, opts.Module)
+		types := fileTypesFromAST(node)
+		impl := implementationFactsFromAST(fset, node, file.RelPath)
+		file.Types = &types
+		file.impl = &impl
+	} else {
+		file.ParseErr = parseErr
+		// The import block may still parse on its own; keep rewriting aliased calls if it does
+		if node, err := parser.ParseFile(token.NewFileSet(), file.Path, content, parser.ImportsOnly); err == nil {
+			imports = resolver.table(node, file.Path, root, opts.Module)
+		}
+	}
+
+	file.Functions = extractFunctions(lines, file.RelPath, imports)
+	if opts.AllCalls {
+		file.AllCalls = extractAllCalls(lines, file.RelPath, imports)
+		file.hasAllCalls = true
+	}
+	file.volatile = len(imports.Dot) > 0
+}
+
+// Functions returns the functions of every file, in file order
+func (p *Project) Functions() []FunctionInfo {
+	var functions []FunctionInfo
+	for _, file := range p.Files {
+		functions = append(functions, cloneFunctions(file.Functions)...)
+	}
+	return functions
+}
+
+// allCallFunctions returns the unfiltered-call functions of every file, in file order. Files parsed
+// without ParseOptions.AllCalls are scanned now.
+func (p *Project) allCallFunctions() ([]FunctionInfo, error) {
+	var functions []FunctionInfo
+	for _, file := range p.Files {
+		if !file.hasAllCalls {
+			funcs, err := FindFunctionsWithAllCalls(file.Path, p.Root, p.Module)
+			if err != nil {
+				return nil, err
+			}
+			file.AllCalls, file.hasAllCalls = funcs, true
+		}
+		functions = append(functions, cloneFunctions(file.AllCalls)...)
+	}
+	return functions, nil
+}
+
+// forEachParallel calls fn for every index in [0, n) on at most workers goroutines. It returns the first
+// error fn reports, or ctx.Err() once ctx is cancelled; remaining indices are then skipped.
+func forEachParallel(parent context.Context, workers, n int, fn func(i int) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if err := fn(i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return parent.Err()
+}
@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseProjectCache(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":      "module example.com/m\n",
+		"a/a.go":      "package a\n\nimport \"example.com/m/b\"\n\nfunc Run() {\n\tb.Other()\n}\n",
+		"b/b.go":      "package b\n\nfunc Other() {\n}\n",
+		"a/a_test.go": "package a\n\nfunc TestRun() {\n}\n",
+	})
+	cacheDir := t.TempDir()
+	parse := func() (*Project, int, int) {
+		t.Helper()
+		cache, err := OpenFileCache(cacheDir, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		project, err := ParseProject(context.Background(), root, ParseOptions{Module: "example.com/m", Cache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Save(); err != nil {
+			t.Fatal(err)
+		}
+		hits, misses := cache.Stats()
+		return project, hits, misses
+	}
+	calls := func(p *Project) map[string][]string {
+		got := make(map[string][]string)
+		for _, fn := range p.Functions() {
+			got[fn.Name] = fn.Calls
+		}
+		return got
+	}
+
+	steps := []struct {
+		name         string
+		edit         map[string]string
+		hits, misses int
+		want         map[string][]string
+	}{
+		{"cold", nil, 0, 2, map[string][]string{"a.Run": {"b.Other"}, "b.Other": nil}},
+		{"unchanged", nil, 2, 0, map[string][]string{"a.Run": {"b.Other"}, "b.Other": nil}},
+		{"one file changed", map[string]string{"b/b.go": "package b\n\nimport \"example.com/m/c\"\n\nfunc Other() {\n\tc.More()\n}\n"}, 1, 1,
+			map[string][]string{"a.Run": {"b.Other"}, "b.Other": {"c.More"}}},
+	}
+	for _, step := range steps {
+		writeFiles(t, root, step.edit)
+		project, hits, misses := parse()
+		if hits != step.hits || misses != step.misses {
+			t.Errorf("%s: cache hits, misses = %d, %d, want %d, %d", step.name, hits, misses, step.hits, step.misses)
+		}
+		if got := calls(project); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: functions = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestParseProjectUnparsableFile(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":     "module example.com/m\n",
+		"a/a.go":     "package a\n\nimport st \"example.com/m/store\"\n\nfunc Run() {\n\tst.Open()\n\tx := \n}\n",
+		"store/s.go": "package store\n\nfunc Open() {\n}\n",
+	})
+	project, err := ParseProject(context.Background(), root, ParseOptions{Module: "example.com/m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range project.Files {
+		if file.RelPath != filepath.Join("a", "a.go") {
+			continue
+		}
+		if file.ParseErr == nil {
+			t.Fatal("ParseErr = nil, want a syntax error")
+		}
+		if len(file.Functions) != 1 || !reflect.DeepEqual(file.Functions[0].Calls, []string{"store.Open"}) {
+			t.Errorf("Functions = %+v, want a.Run calling store.Open through the aliased import", file.Functions)
+		}
+		return
+	}
+	t.Fatal("a/a.go not parsed")
+}
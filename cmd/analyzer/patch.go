@@ -0,0 +1,204 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of 1-based line numbers
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// FileChange lists the lines a unified diff changes in one file. OldPath is "" for an added file and
+// NewPath is "" for a deleted one. NewLines are added lines, plus the line after each deletion so a
+// deletion marks the function it happened in (none for a deleted file); OldLines are the deleted lines.
+type FileChange struct {
+	OldPath  string      `json:"oldPath,omitempty"`
+	NewPath  string      `json:"newPath,omitempty"`
+	OldLines []LineRange `json:"oldLines,omitempty"`
+	NewLines []LineRange `json:"newLines,omitempty"`
+}
+
+var reHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParsePatch reads a unified diff as written by git diff or diff -u. Paths lose their a/ and b/
+// prefixes; binary files and mode-only changes have no lines.
+func ParsePatch(r io.Reader) ([]FileChange, error) {
+	var changes []FileChange
+	var current *FileChange
+	var oldLines, newLines []int
+	oldLine, newLine := 0, 0
+	oldLeft, newLeft := 0, 0 // lines left in the current hunk
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.OldLines = toRanges(oldLines)
+		if current.NewPath != "" {
+			// A deleted file has no line after its deletions
+			current.NewLines = toRanges(newLines)
+		}
+		changes = append(changes, *current)
+		current, oldLines, newLines = nil, nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				newLines = append(newLines, newLine)
+				newLine++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				oldLines = append(oldLines, oldLine)
+				newLines = append(newLines, newLine)
+				oldLine++
+				oldLeft--
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file"
+			default:
+				oldLine++
+				newLine++
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			current = &FileChange{}
+			if a, b, ok := splitGitDiffHeader(strings.TrimPrefix(line, "diff --git ")); ok {
+				current.OldPath, current.NewPath = a, b
+			}
+		case strings.HasPrefix(line, "--- "):
+			if current == nil || len(oldLines)+len(newLines) > 0 {
+				// Plain unified diffs have no "diff --git" line before each file
+				flush()
+				current = &FileChange{}
+			}
+			current.OldPath = patchPath(strings.TrimPrefix(line, "--- "))
+		case strings.HasPrefix(line, "+++ "):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: +++ without ---", lineNo)
+			}
+			current.NewPath = patchPath(strings.TrimPrefix(line, "+++ "))
+		case strings.HasPrefix(line, "new file mode"):
+			if current != nil {
+				current.OldPath = ""
+			}
+		case strings.HasPrefix(line, "deleted file mode"):
+			if current != nil {
+				current.NewPath = ""
+			}
+		case strings.HasPrefix(line, "@@"):
+			m := reHunkHeader.FindStringSubmatch(line)
+			if m == nil || current == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q", lineNo, line)
+			}
+			oldLine, _ = strconv.Atoi(m[1])
+			oldLeft = hunkCount(m[2])
+			newLine, _ = strconv.Atoi(m[3])
+			newLeft = hunkCount(m[4])
+			// An empty side's start is the line before the hunk
+			if oldLeft == 0 {
+				oldLine++
+			}
+			if newLeft == 0 {
+				newLine++
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return changes, nil
+}
+
+// GoFiles keeps the changes to non-test Go files, the inputs of the analysis
+func GoFiles(changes []FileChange) []FileChange {
+	var kept []FileChange
+	for _, c := range changes {
+		if isGoSource(c.OldPath) || isGoSource(c.NewPath) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+func isGoSource(path string) bool {
+	return strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go")
+}
+
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// patchPath strips the a/ or b/ prefix and any trailing timestamp; /dev/null becomes ""
+func patchPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		return s[2:]
+	}
+	return s
+}
+
+// splitGitDiffHeader splits "a/x b/y" of a diff --git line; paths with spaces rely on both halves being equal
+func splitGitDiffHeader(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, "a/") {
+		return "", "", false
+	}
+	if i := strings.Index(s, " b/"); i >= 0 && strings.Count(s, " b/") == 1 {
+		return s[2:i], s[i+3:], true
+	}
+	half := (len(s) - 1) / 2
+	if len(s)%2 == 1 && s[half] == ' ' && s[2:half] == s[half+3:] {
+		return s[2:half], s[half+3:], true
+	}
+	return "", "", false
+}
+
+// toRanges merges line numbers into sorted inclusive ranges
+func toRanges(lines []int) []LineRange {
+	if len(lines) == 0 {
+		return nil
+	}
+	sort.Ints(lines)
+	ranges := []LineRange{{lines[0], lines[0]}}
+	for _, l := range lines[1:] {
+		last := &ranges[len(ranges)-1]
+		if l <= last.End+1 {
+			if l > last.End {
+				last.End = l
+			}
+			continue
+		}
+		ranges = append(ranges, LineRange{l, l})
+	}
+	return ranges
+}
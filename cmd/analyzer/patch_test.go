@@ -0,0 +1,159 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  []FileChange
+	}{
+		{
+			name: "modified file with several hunks",
+			patch: `diff --git a/svc/a.go b/svc/a.go
+index 1111111..2222222 100644
+--- a/svc/a.go
++++ b/svc/a.go
+@@ -3,0 +4,2 @@ func A() {
++	one()
++	two()
+@@ -10 +12 @@ func B() {
+-	old()
++	new()
+@@ -20,2 +21,0 @@ func C() {
+-	gone()
+-	gone()
+`,
+			want: []FileChange{{
+				OldPath:  "svc/a.go",
+				NewPath:  "svc/a.go",
+				OldLines: []LineRange{{10, 10}, {20, 21}},
+				NewLines: []LineRange{{4, 5}, {12, 12}, {22, 22}},
+			}},
+		},
+		{
+			name: "new file",
+			patch: `diff --git a/svc/new.go b/svc/new.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/svc/new.go
+@@ -0,0 +1,3 @@
++package svc
++
++func New() {}
+`,
+			want: []FileChange{{NewPath: "svc/new.go", NewLines: []LineRange{{1, 3}}}},
+		},
+		{
+			name: "deleted file",
+			patch: `diff --git a/svc/old.go b/svc/old.go
+deleted file mode 100644
+index 3333333..0000000
+--- a/svc/old.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package svc
+-
+-func Old() {}
+`,
+			want: []FileChange{{OldPath: "svc/old.go", OldLines: []LineRange{{1, 3}}}},
+		},
+		{
+			name: "pure rename",
+			patch: `diff --git a/svc/a.go b/svc/b.go
+similarity index 100%
+rename from svc/a.go
+rename to svc/b.go
+`,
+			want: []FileChange{{OldPath: "svc/a.go", NewPath: "svc/b.go"}},
+		},
+		{
+			name: "rename with changes",
+			patch: `diff --git a/svc/a.go b/svc/b.go
+similarity index 90%
+rename from svc/a.go
+rename to svc/b.go
+index 1111111..2222222 100644
+--- a/svc/a.go
++++ b/svc/b.go
+@@ -5 +5 @@ func A() {
+-	old()
++	new()
+`,
+			want: []FileChange{{OldPath: "svc/a.go", NewPath: "svc/b.go", OldLines: []LineRange{{5, 5}}, NewLines: []LineRange{{5, 5}}}},
+		},
+		{
+			name: "no newline at end of file",
+			patch: `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -7 +7,2 @@
+-}
+\ No newline at end of file
++}
++
+`,
+			want: []FileChange{{OldPath: "a.go", NewPath: "a.go", OldLines: []LineRange{{7, 7}}, NewLines: []LineRange{{7, 8}}}},
+		},
+		{
+			name: "removed line that looks like a header",
+			patch: `--- a/a.go
++++ b/a.go
+@@ -2,2 +2,1 @@
+--- a/x
+ keep
+--- b/b.go
++++ b/b.go
+@@ -1 +1 @@
+-x
++y
+`,
+			want: []FileChange{
+				{OldPath: "a.go", NewPath: "a.go", OldLines: []LineRange{{2, 2}}, NewLines: []LineRange{{2, 2}}},
+				{OldPath: "b.go", NewPath: "b.go", OldLines: []LineRange{{1, 1}}, NewLines: []LineRange{{1, 1}}},
+			},
+		},
+		{
+			name: "binary file",
+			patch: `diff --git a/logo.png b/logo.png
+index 1111111..2222222 100644
+Binary files a/logo.png and b/logo.png differ
+`,
+			want: []FileChange{{OldPath: "logo.png", NewPath: "logo.png"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePatch(strings.NewReader(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePatch =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"hunk without file", "@@ -1 +1 @@\n-a\n+b\n"},
+		{"malformed hunk header", "--- a/a.go\n+++ b/a.go\n@@ one @@\n"},
+		{"new path without old", "+++ b/a.go\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePatch(strings.NewReader(tt.patch)); err == nil {
+				t.Error("ParsePatch succeeded")
+			}
+		})
+	}
+}
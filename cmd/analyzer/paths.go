@@ -0,0 +1,215 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MaxPaths caps the number of paths a single query may ask for
+const MaxPaths = 20
+
+// PathStep is one function on a call path
+type PathStep struct {
+	Name     string `json:"name"`
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+	CallLine int    `json:"callLine,omitempty"` // line of the call to the next step, when the source is available
+}
+
+// CallPath is a chain of calls from one function to another; Length counts the calls
+type CallPath struct {
+	Length int        `json:"length"`
+	Steps  []PathStep `json:"steps"`
+}
+
+// PathsResponse is the body of GET /api/paths and the output of the paths command with -json
+type PathsResponse struct {
+	From  OutRelation `json:"from"`
+	To    OutRelation `json:"to"`
+	K     int         `json:"k"`     // paths asked for
+	Paths []CallPath  `json:"paths"` // shortest first; fewer than k when no more simple paths exist
+}
+
+// ShortestPaths returns up to k shortest simple call paths from the node from to the function to (a node
+// or a leaf returned by Function), shortest first and, at equal length, in node order. It runs Yen's
+// algorithm over breadth-first searches, so each path is loop-free.
+func (g *Graph) ShortestPaths(from NodeID, to OutRelation, k int) []CallPath {
+	// A leaf target has no node: it becomes a virtual node n reached from its callers
+	target := NodeID(g.Len())
+	if id, ok := g.Lookup(to.Name, to.FilePath); ok {
+		target = id
+	}
+	intoLeaf := make(map[NodeID]bool)
+	if target == NodeID(g.Len()) {
+		for _, caller := range g.CallersOf(to) {
+			intoLeaf[caller] = true
+		}
+	}
+	successors := func(id NodeID, visit func(NodeID)) {
+		if id == NodeID(g.Len()) {
+			return
+		}
+		for _, callee := range g.Callees(id) {
+			visit(callee)
+		}
+		if intoLeaf[id] {
+			visit(target)
+		}
+	}
+
+	type edge struct{ from, to NodeID }
+	blockedNodes := make(map[NodeID]bool)
+	blockedEdges := make(map[edge]bool)
+	bfs := func(start NodeID) []NodeID {
+		parent := map[NodeID]NodeID{start: -1}
+		queue := []NodeID{start}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if id == target {
+				var path []NodeID
+				for ; id != -1; id = parent[id] {
+					path = append(path, id)
+				}
+				slices.Reverse(path)
+				return path
+			}
+			successors(id, func(next NodeID) {
+				if _, seen := parent[next]; seen || blockedNodes[next] || blockedEdges[edge{id, next}] {
+					return
+				}
+				parent[next] = id
+				queue = append(queue, next)
+			})
+		}
+		return nil
+	}
+
+	first := bfs(from)
+	if first == nil || k <= 0 {
+		return []CallPath{}
+	}
+	found := [][]NodeID{first}
+	var candidates [][]NodeID
+	for len(found) < k {
+		previous := found[len(found)-1]
+		for i := 0; i < len(previous)-1; i++ {
+			spur, root := previous[i], previous[:i+1]
+			clear(blockedNodes)
+			clear(blockedEdges)
+			for _, p := range found {
+				if len(p) > i+1 && slices.Equal(p[:i+1], root) {
+					blockedEdges[edge{p[i], p[i+1]}] = true
+				}
+			}
+			for _, id := range root[:i] {
+				blockedNodes[id] = true
+			}
+			spurPath := bfs(spur)
+			if spurPath == nil {
+				continue
+			}
+			candidate := append(slices.Clone(root[:i]), spurPath...)
+			if !slices.ContainsFunc(candidates, func(p []NodeID) bool { return slices.Equal(p, candidate) }) {
+				candidates = append(candidates, candidate)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		slices.SortStableFunc(candidates, func(a, b []NodeID) int {
+			if len(a) != len(b) {
+				return len(a) - len(b)
+			}
+			return slices.Compare(a, b)
+		})
+		found = append(found, candidates[0])
+		candidates = candidates[1:]
+	}
+
+	paths := make([]CallPath, len(found))
+	for i, p := range found {
+		steps := make([]PathStep, len(p))
+		for j, id := range p {
+			r := to
+			if id != NodeID(g.Len()) {
+				r = g.Relations[id]
+			}
+			steps[j] = PathStep{Name: r.Name, FilePath: r.FilePath, Line: r.Line}
+		}
+		paths[i] = CallPath{Length: len(p) - 1, Steps: steps}
+	}
+	return paths
+}
+
+// AddCallLines fills in the line of each call on the paths by finding the callee in the caller's body.
+// Relations record where functions are declared, not where they are called, so this needs the source;
+// steps whose file cannot be read or whose call is not found keep CallLine 0.
+func AddCallLines(paths []CallPath, read SourceReader) {
+	files := make(map[string][]string)
+	for _, p := range paths {
+		for i := 0; i < len(p.Steps)-1; i++ {
+			step := &p.Steps[i]
+			lines, ok := files[step.FilePath]
+			if !ok {
+				if src, err := read(step.FilePath); err == nil {
+					lines = strings.Split(string(src), "\n")
+				}
+				files[step.FilePath] = lines
+			}
+			step.CallLine = callLine(lines, step.Line, p.Steps[i+1].Name)
+		}
+	}
+}
+
+// callLine returns the first line of the function declared at line declLine calling callee (or, for
+// handler references, naming it), 0 when there is none
+func callLine(lines []string, declLine int, callee string) int {
+	if declLine < 1 || declLine > len(lines) {
+		return 0
+	}
+	start, end := FindFunctionBody(lines, declLine-1)
+	if start == -1 || end == -1 {
+		return 0
+	}
+	short := regexp.QuoteMeta(callee[strings.LastIndex(callee, ".")+1:])
+	for _, pattern := range []string{`\b` + short + `\s*\(`, `\b` + short + `\b`} {
+		re := regexp.MustCompile(pattern)
+		for i := start; i <= end; i++ {
+			if i == declLine-1 {
+				// Skip the declaration itself when the body opens on its line
+				continue
+			}
+			if re.MatchString(lines[i]) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// WriteSummary writes the paths in human-readable form, one step per line
+func (r PathsResponse) WriteSummary(w io.Writer) error {
+	if len(r.Paths) == 0 {
+		_, err := fmt.Fprintf(w, "No call path from %s to %s\n", r.From.Name, r.To.Name)
+		return err
+	}
+	for i, p := range r.Paths {
+		if _, err := fmt.Fprintf(w, "Path %d (%d call(s)):\n", i+1, p.Length); err != nil {
+			return err
+		}
+		for _, step := range p.Steps {
+			location := fmt.Sprintf("%s:%d", step.FilePath, step.Line)
+			if step.CallLine > 0 {
+				location += fmt.Sprintf(", calls at line %d", step.CallLine)
+			}
+			if _, err := fmt.Fprintf(w, "  %s  (%s)\n", step.Name, location); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
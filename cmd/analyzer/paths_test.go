@@ -0,0 +1,112 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pathsGraph: a.A calls b.B and c.C, b.B calls c.C and d.D, c.C calls d.D, and d.D calls back into a.A
+// and the leaf e.E; x.X is unconnected
+func pathsGraph() *Graph {
+	call := func(name, file string) OutCalled { return OutCalled{Name: name, FilePath: file, Line: 1} }
+	return NewGraph([]OutRelation{
+		{Name: "a.A", FilePath: "a.go", Line: 1, Called: []OutCalled{call("b.B", "b.go"), call("c.C", "c.go")}},
+		{Name: "b.B", FilePath: "b.go", Line: 1, Called: []OutCalled{call("c.C", "c.go"), call("d.D", "d.go")}},
+		{Name: "c.C", FilePath: "c.go", Line: 1, Called: []OutCalled{call("d.D", "d.go")}},
+		{Name: "d.D", FilePath: "d.go", Line: 1, Called: []OutCalled{call("a.A", "a.go"), call("e.E", "e.go")}},
+		{Name: "x.X", FilePath: "x.go", Line: 1},
+	}, nil)
+}
+
+func TestShortestPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		k        int
+		want     []string
+	}{
+		{
+			name: "all simple paths, shortest first then in node order",
+			from: "a.A", to: "d.D", k: 5,
+			want: []string{"a.A b.B d.D", "a.A c.C d.D", "a.A b.B c.C d.D"},
+		},
+		{
+			name: "k limits the paths",
+			from: "a.A", to: "d.D", k: 2,
+			want: []string{"a.A b.B d.D", "a.A c.C d.D"},
+		},
+		{
+			name: "leaf target",
+			from: "a.A", to: "e.E", k: 5,
+			want: []string{"a.A b.B d.D e.E", "a.A c.C d.D e.E", "a.A b.B c.C d.D e.E"},
+		},
+		{
+			name: "through a cycle without looping",
+			from: "d.D", to: "c.C", k: 5,
+			want: []string{"d.D a.A c.C", "d.D a.A b.B c.C"},
+		},
+		{
+			name: "to itself",
+			from: "a.A", to: "a.A", k: 3,
+			want: []string{"a.A"},
+		},
+		{
+			name: "unreachable",
+			from: "x.X", to: "a.A", k: 3,
+			want: []string{},
+		},
+		{
+			name: "no paths asked for",
+			from: "a.A", to: "d.D", k: 0,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := pathsGraph()
+			_, from, ok := g.Function(tt.from)
+			if !ok || from < 0 {
+				t.Fatalf("no node %s", tt.from)
+			}
+			to, _, ok := g.Function(tt.to)
+			if !ok {
+				t.Fatalf("no function %s", tt.to)
+			}
+			got := []string{}
+			for _, p := range g.ShortestPaths(from, to, tt.k) {
+				names := make([]string, len(p.Steps))
+				for i, s := range p.Steps {
+					names[i] = s.Name
+				}
+				if p.Length != len(p.Steps)-1 {
+					t.Errorf("path %v has length %d", names, p.Length)
+				}
+				got = append(got, strings.Join(names, " "))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddCallLines(t *testing.T) {
+	source := map[string]string{
+		"a.go": "package a\n\nfunc A() {\n\tx := 1\n\tb.B(x)\n}\n",
+	}
+	read := func(rel string) ([]byte, error) { return []byte(source[rel]), nil }
+	paths := []CallPath{{Length: 2, Steps: []PathStep{
+		{Name: "a.A", FilePath: "a.go", Line: 3},
+		{Name: "b.B", FilePath: "b.go", Line: 1},
+		{Name: "c.C", FilePath: "c.go", Line: 1},
+	}}}
+	AddCallLines(paths, read)
+	var got []int
+	for _, s := range paths[0].Steps {
+		got = append(got, s.CallLine)
+	}
+	if want := []int{5, 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("call lines = %v, want %v", got, want)
+	}
+}
@@ -1,6 +1,12 @@
 package analyzer
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -23,13 +29,36 @@ type OutRelation struct {
 // If includeExternal is false, the provided slice must already have Calls filtered to user-defined packages (CreateJsonFile performs this filtering).
 // If includeExternal is true, all calls are included in the relations, including external module functions.
 // We still defensively exclude relations that have zero called entries to preserve prior semantics unless includeExternal is true.
+// When a name is defined more than once (see MergeFunctions), calls resolve to its first definition.
 func BuildRelations(functions []FunctionInfo, includeExternal bool) []OutRelation {
+	index := newRelationIndex(functions, includeExternal)
+	out := make([]OutRelation, 0, len(functions))
+	for _, f := range functions {
+		if rel, ok := index.relation(f); ok {
+			out = append(out, rel)
+		}
+	}
+	return out
+}
+
+// relationIndex resolves call names to the functions BuildRelations links them to
+type relationIndex struct {
+	includeExternal bool
+	funcMap         map[string]FunctionInfo // first definition of each name
+	suffixMap       map[string]FunctionInfo // external functions by package.Function suffix
+	sortedNames     []string                // names in lexicographic order, for the fuzzy fallbacks (includeExternal only)
+}
+
+func newRelationIndex(functions []FunctionInfo, includeExternal bool) *relationIndex {
 	// index by name for quick lookup
 	funcMap := make(map[string]FunctionInfo, len(functions))
 	// Also create an index by suffix for external function matching
 	suffixMap := make(map[string]FunctionInfo)
 
 	for _, f := range functions {
+		if _, exists := funcMap[f.Name]; exists {
+			continue
+		}
 		funcMap[f.Name] = f
 		// If this is an external function, also index by its suffix for partial matching
 		if strings.HasPrefix(f.FilePath, "external:") {
@@ -40,65 +69,208 @@ func BuildRelations(functions []FunctionInfo, includeExternal bool) []OutRelatio
 				dotParts := strings.Split(lastPart, ".")
 				if len(dotParts) >= 2 {
 					suffix := strings.Join(dotParts[len(dotParts)-2:], ".")
-					suffixMap[suffix] = f
+					if _, exists := suffixMap[suffix]; !exists {
+						suffixMap[suffix] = f
+					}
 				}
 			}
 		}
 	}
 
-	out := make([]OutRelation, 0, len(functions))
-	for _, f := range functions {
-		if len(f.Calls) == 0 && !includeExternal {
-			continue // skip functions with no user-defined calls (previous behaviour)
-		}
-		rel := OutRelation{Name: f.Name, Line: f.Line, FilePath: f.FilePath}
-		for _, cname := range f.Calls {
-			if cf, ok := funcMap[cname]; ok {
-				// Function exists in our codebase (including external modules when scanned)
+	// Names in lexicographic order for the fuzzy fallbacks below, so ties always resolve to the same function
+	var sortedNames []string
+	if includeExternal {
+		sortedNames = sortedKeys(funcMap)
+	}
+	return &relationIndex{includeExternal: includeExternal, funcMap: funcMap, suffixMap: suffixMap, sortedNames: sortedNames}
+}
+
+// relation builds the relation of f; false when BuildRelations leaves f out
+func (x *relationIndex) relation(f FunctionInfo) (OutRelation, bool) {
+	includeExternal, funcMap, suffixMap, sortedNames := x.includeExternal, x.funcMap, x.suffixMap, x.sortedNames
+	if len(f.Calls) == 0 && !includeExternal {
+		return OutRelation{}, false // skip functions with no user-defined calls (previous behaviour)
+	}
+	rel := OutRelation{Name: f.Name, Line: f.Line, FilePath: f.FilePath}
+	for _, cname := range f.Calls {
+		if cf, ok := funcMap[cname]; ok {
+			// Function exists in our codebase (including external modules when scanned)
+			rel.Called = append(rel.Called, OutCalled{Name: cf.Name, Line: cf.Line, FilePath: cf.FilePath})
+		} else if includeExternal {
+			// Try to match with external functions by suffix
+			if cf, ok := suffixMap[cname]; ok {
 				rel.Called = append(rel.Called, OutCalled{Name: cf.Name, Line: cf.Line, FilePath: cf.FilePath})
-			} else if includeExternal {
-				// Try to match with external functions by suffix
-				if cf, ok := suffixMap[cname]; ok {
-					rel.Called = append(rel.Called, OutCalled{Name: cf.Name, Line: cf.Line, FilePath: cf.FilePath})
-				} else {
-					// Try more flexible matching for method calls
-					matched := false
-					var matchedFunction FunctionInfo
-
-					// First try: exact suffix match with dot notation
-					for fullName, cf := range funcMap {
-						if strings.HasSuffix(fullName, "."+cname) {
-							rel.Called = append(rel.Called, OutCalled{Name: cf.Name, Line: cf.Line, FilePath: cf.FilePath})
+			} else {
+				// Try more flexible matching for method calls
+				matched := false
+				var matchedFunction FunctionInfo
+
+				// First try: exact suffix match with dot notation
+				for _, fullName := range sortedNames {
+					cf := funcMap[fullName]
+					if strings.HasSuffix(fullName, "."+cname) {
+						rel.Called = append(rel.Called, OutCalled{Name: cf.Name, Line: cf.Line, FilePath: cf.FilePath})
+						matched = true
+						break
+					}
+				}
+
+				// Second try: if not matched, try partial matching
+				if !matched {
+					for _, fullName := range sortedNames {
+						cf := funcMap[fullName]
+						if strings.Contains(fullName, cname) {
+							matchedFunction = cf
 							matched = true
 							break
 						}
 					}
-
-					// Second try: if not matched, try partial matching
-					if !matched {
-						for fullName, cf := range funcMap {
-							if strings.Contains(fullName, cname) {
-								matchedFunction = cf
-								matched = true
-								break
-							}
-						}
-						if matched {
-							rel.Called = append(rel.Called, OutCalled{Name: matchedFunction.Name, Line: matchedFunction.Line, FilePath: matchedFunction.FilePath})
-						}
+					if matched {
+						rel.Called = append(rel.Called, OutCalled{Name: matchedFunction.Name, Line: matchedFunction.Line, FilePath: matchedFunction.FilePath})
 					}
+				}
 
-					if !matched {
-						// External function call not found in scanned modules - include with placeholder info
-						rel.Called = append(rel.Called, OutCalled{Name: cname, Line: 0, FilePath: "external"})
-					}
+				if !matched {
+					// External function call not found in scanned modules - include with placeholder info
+					rel.Called = append(rel.Called, OutCalled{Name: cname, Line: 0, FilePath: "external"})
 				}
 			}
 		}
-		if len(rel.Called) > 0 || includeExternal {
-			// Include the relation if it has calls OR if we're including all functions
-			out = append(out, rel)
+	}
+	// Include the relation if it has calls OR if we're including all functions
+	return rel, len(rel.Called) > 0 || includeExternal
+}
+
+// relationEntry is one function given to BuildRelations and the relation it produced
+type relationEntry struct {
+	Function FunctionInfo
+	Relation *OutRelation // nil when BuildRelations leaves the function out
+}
+
+// relationBuild records a BuildRelations run so the next one can reuse what did not change
+type relationBuild struct {
+	IncludeExternal bool
+	Entries         []relationEntry // in function order
+}
+
+// rebuildRelations returns what BuildRelations(functions, includeExternal) returns, building only the
+// relations prev cannot provide: a relation is reused when prev has a function with the same name, file,
+// line and calls, and none of those calls names a function that was added, removed or moved since. So a
+// changed file costs the relations of its own functions and of their callers. With includeExternal, calls
+// that are only matched by suffix depend on every name, and are rebuilt whenever any name changed. Relations
+// are built on at most workers goroutines. prev may be nil; the returned record describes this run.
+func rebuildRelations(ctx context.Context, workers int, prev *relationBuild, functions []FunctionInfo, includeExternal bool) ([]OutRelation, *relationBuild, int, error) {
+	index := newRelationIndex(functions, includeExternal)
+	build := &relationBuild{IncludeExternal: includeExternal, Entries: make([]relationEntry, len(functions))}
+	reused := make([]bool, len(functions))
+	if prev != nil && prev.IncludeExternal == includeExternal {
+		// A relation only depends on its function and on the definitions its calls resolve to
+		key := func(f FunctionInfo) string {
+			return f.Name + "|" + f.FilePath + "|" + strconv.Itoa(f.Line) + "|" + strings.Join(f.Calls, "\x00")
+		}
+		previous := make(map[string]*OutRelation, len(prev.Entries))
+		defined := make(map[string]FunctionInfo, len(prev.Entries))
+		for _, e := range prev.Entries {
+			previous[key(e.Function)] = e.Relation
+			if _, ok := defined[e.Function.Name]; !ok {
+				defined[e.Function.Name] = e.Function
+			}
+		}
+		moved := func(name string) bool {
+			before, was := defined[name]
+			now, is := index.funcMap[name]
+			return was != is || before.FilePath != now.FilePath || before.Line != now.Line
+		}
+		anyMoved := len(defined) != len(index.funcMap)
+		for name := range index.funcMap {
+			if anyMoved {
+				break
+			}
+			anyMoved = moved(name)
+		}
+
+		for i, f := range functions {
+			rel, ok := previous[key(f)]
+			if !ok {
+				continue
+			}
+			stale := false
+			for _, call := range f.Calls {
+				_, resolved := index.funcMap[call]
+				if moved(call) || (includeExternal && !resolved && anyMoved) {
+					stale = true
+					break
+				}
+			}
+			if !stale {
+				build.Entries[i] = relationEntry{Function: f, Relation: rel}
+				reused[i] = true
+			}
 		}
 	}
-	return out
+
+	err := forEachParallel(ctx, workers, len(functions), func(i int) error {
+		if reused[i] {
+			return nil
+		}
+		entry := relationEntry{Function: functions[i]}
+		if rel, ok := index.relation(functions[i]); ok {
+			entry.Relation = &rel
+		}
+		build.Entries[i] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	rebuilt := 0
+	out := make([]OutRelation, 0, len(functions))
+	for i, e := range build.Entries {
+		if !reused[i] {
+			rebuilt++
+		}
+		if e.Relation != nil {
+			out = append(out, *e.Relation)
+		}
+	}
+	return out, build, rebuilt, nil
+}
+
+// SortRelations orders relations by name, then file path, then line. This is the canonical
+// output order; together with deterministic call resolution it makes output byte-identical across runs.
+func SortRelations(relations []OutRelation) {
+	sort.SliceStable(relations, func(i, j int) bool {
+		if relations[i].Name != relations[j].Name {
+			return relations[i].Name < relations[j].Name
+		}
+		if relations[i].FilePath != relations[j].FilePath {
+			return relations[i].FilePath < relations[j].FilePath
+		}
+		return relations[i].Line < relations[j].Line
+	})
+}
+
+// RelationsHash returns the hex SHA-256 of the compact JSON encoding of relations.
+// Relations should be in canonical order (see SortRelations) so equal graphs hash equally.
+// The encoding is hashed one relation at a time, so the full document is never materialized.
+func RelationsHash(relations []OutRelation) (string, error) {
+	h := sha256.New()
+	if relations == nil {
+		h.Write([]byte("null"))
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	h.Write([]byte("["))
+	for i, r := range relations {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			h.Write([]byte(","))
+		}
+		h.Write(data)
+	}
+	h.Write([]byte("]"))
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRebuildRelations(t *testing.T) {
+	fn := func(name, file string, line int, calls ...string) FunctionInfo {
+		return FunctionInfo{Name: name, FilePath: file, Line: line, Calls: calls}
+	}
+	base := []FunctionInfo{
+		fn("a.Run", "a/a.go", 3, "b.Other", "c.Helper"),
+		fn("b.Other", "b/b.go", 3, "c.Helper"),
+		fn("c.Helper", "c/c.go", 3),
+		fn("d.Solo", "d/d.go", 3, "b.Other", "x.New"),
+	}
+	with := func(edit func(fs []FunctionInfo) []FunctionInfo) []FunctionInfo {
+		fs := make([]FunctionInfo, len(base))
+		copy(fs, base)
+		return edit(fs)
+	}
+
+	tests := []struct {
+		name            string
+		functions       []FunctionInfo
+		prevExternal    bool // includeExternal of the previous build
+		includeExternal bool
+		rebuilt         int
+	}{
+		{"unchanged", base, false, false, 0},
+		{"callee moved", with(func(fs []FunctionInfo) []FunctionInfo {
+			fs[2] = fn("c.Helper", "c/c.go", 5)
+			return fs
+		}), false, false, 3},
+		{"calls changed", with(func(fs []FunctionInfo) []FunctionInfo {
+			fs[1] = fn("b.Other", "b/b.go", 3, "c.Helper", "x.New")
+			return fs
+		}), false, false, 1},
+		{"callee added", with(func(fs []FunctionInfo) []FunctionInfo {
+			return append(fs, fn("x.New", "x/x.go", 3))
+		}), false, false, 2},
+		{"callee removed", with(func(fs []FunctionInfo) []FunctionInfo {
+			return append(fs[:2], fs[3])
+		}), false, false, 2},
+		{"other mode", base, false, true, 4},
+		{"external calls matched by suffix follow any change", with(func(fs []FunctionInfo) []FunctionInfo {
+			fs[2] = fn("c.Helper", "c/c.go", 5)
+			return fs
+		}), true, true, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, prev, _, err := rebuildRelations(context.Background(), 2, nil, base, tt.prevExternal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, rebuilt, err := rebuildRelations(context.Background(), 2, prev, tt.functions, tt.includeExternal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := BuildRelations(tt.functions, tt.includeExternal); !reflect.DeepEqual(got, want) {
+				t.Errorf("relations =\n%+v\nwant\n%+v", got, want)
+			}
+			if rebuilt != tt.rebuilt {
+				t.Errorf("rebuilt %d relations, want %d", rebuilt, tt.rebuilt)
+			}
+		})
+	}
+}
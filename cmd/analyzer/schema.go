@@ -0,0 +1,328 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaBaseURL is the $id prefix of the published schemas (see the schemas/ directory)
+const SchemaBaseURL = "https://github.com/chinmay-sawant/gomindmapper/schemas/"
+
+// SchemaArtifact describes one output file or API response with a published JSON Schema
+type SchemaArtifact struct {
+	Name        string // schema file is <Name>.schema.json
+	Title       string
+	Description string
+	value       any // zero value of the Go type the schema is generated from
+}
+
+// SchemaArtifacts lists every artifact with a published schema, in publication order
+var SchemaArtifacts = []SchemaArtifact{
+	{Name: "functionmap", Title: "functionmap.json", Description: "Versioned relation snapshot written by the CLI and served by /api/download", value: Snapshot{}},
+	{Name: "functionmap-v1", Title: "functionmap.json (legacy)", Description: "Schema v1 bare relation array, migrated on load", value: []OutRelation{}},
+	{Name: "functions", Title: "functions.json", Description: "Functions with their filtered calls, written by CreateJsonFile", value: []FunctionInfo{}},
+	{Name: "removed-calls", Title: "removed_calls.json", Description: "Calls dropped by CreateJsonFile filtering", value: RemovedCallsReport{}},
+	{Name: "api-relations", Title: "GET /api/relations", Description: "Paginated roots with their dependency closure", value: RelationsResponse{}},
+	{Name: "api-search", Title: "GET /api/search", Description: "Search matches with their dependency closure", value: SearchResponse{}},
+	{Name: "api-callers", Title: "GET /api/callers", Description: "Caller tree of one function, paginated over its direct callers", value: CallersResponse{}},
+	{Name: "api-expand", Title: "GET /api/expand", Description: "One more level of callees below a truncated node", value: ExpandResponse{}},
+	{Name: "api-neighborhood", Title: "GET /api/neighborhood", Description: "Functions within a number of caller and callee hops of one function", value: NeighborhoodResponse{}},
+	{Name: "paths", Title: "paths -json and GET /api/paths", Description: "Shortest call paths between two functions", value: PathsResponse{}},
+	{Name: "api-reload", Title: "POST /api/reload", Description: "Reload job, also returned by GET /api/jobs/{id}", value: ReloadJob{}},
+	{Name: "api-jobs", Title: "GET /api/jobs", Description: "Recent reload jobs", value: JobsResponse{}},
+	{Name: "api-events", Title: "GET /api/events", Description: "Data of the server-sent change event", value: ChangeEvent{}},
+	{Name: "api-repos", Title: "GET /api/repos", Description: "Repositories hosted by the server", value: ReposResponse{}},
+	{Name: "api-repo", Title: "GET /api/repos/{id}", Description: "One hosted repository, also returned by POST and DELETE", value: RepoInfo{}},
+	{Name: "api-refs", Title: "GET /api/refs", Description: "Branches and tags of a repository with the selected ref", value: RefsResponse{}},
+	{Name: "diff", Title: "diff -json and GET /api/diff", Description: "Structural difference between two relation sets", value: GraphDiff{}},
+	{Name: "impact", Title: "impact -json and GET /api/impact", Description: "Functions a change touches with their transitive callers and affected roots", value: ImpactReport{}},
+	{Name: "tests", Title: "tests -json", Description: "Tests that statically reach a change, grouped by package with their go test -run pattern", value: TestSelection{}},
+	{Name: "repos-config", Title: "Server -config file", Description: "Repositories to host; each entry is also a valid POST /api/repos body", value: ReposConfig{}},
+	{Name: "api-error", Title: "API error", Description: "Body of every API error response", value: ErrorResponse{}},
+}
+
+// FindSchemaArtifact looks up an artifact by name
+func FindSchemaArtifact(name string) (SchemaArtifact, bool) {
+	for _, a := range SchemaArtifacts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return SchemaArtifact{}, false
+}
+
+// Schema generates the JSON Schema (draft 2020-12) for the artifact from its Go type
+func (a SchemaArtifact) Schema() map[string]any {
+	schema := schemaForType(reflect.TypeOf(a.value))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = SchemaBaseURL + a.Name + ".schema.json"
+	schema["title"] = a.Title
+	schema["description"] = a.Description
+	return schema
+}
+
+// MarshalSchema returns the artifact's schema as indented JSON with a trailing newline
+func (a SchemaArtifact) MarshalSchema() ([]byte, error) {
+	data, err := json.MarshalIndent(a.Schema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaForType maps Go types to schemas the way encoding/json encodes them.
+// Structs are closed (additionalProperties: false) so added or renamed fields fail validation;
+// nil slices and maps encode as null, so non-omitempty ones also accept null.
+func schemaForType(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaForType(t.Elem())
+		schema["type"] = []any{schema["type"], "null"}
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaForType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitEmpty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			prop := schemaForType(field.Type)
+			if !omitEmpty {
+				required = append(required, name)
+				if k := field.Type.Kind(); k == reflect.Slice || k == reflect.Map {
+					prop["type"] = []any{prop["type"], "null"}
+				}
+			}
+			properties[name] = prop
+		}
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	omitEmpty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
+
+// ValidateArtifact validates JSON data against the named artifact's schema and
+// returns one message per violation, each prefixed with a JSON pointer
+func ValidateArtifact(name string, data []byte) ([]string, error) {
+	artifact, ok := FindSchemaArtifact(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown artifact %q", name)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	var violations []string
+	validateValue(artifact.Schema(), doc, "", &violations)
+	return violations, nil
+}
+
+// LoadArtifact reads path, validates it against the named schema and decodes it into v
+func LoadArtifact(path, name string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	violations, err := ValidateArtifact(name, data)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%s does not match the %s schema: %s", path, name, strings.Join(violations, "; "))
+	}
+	return json.Unmarshal(data, v)
+}
+
+// validateValue checks the subset of JSON Schema produced by schemaForType
+func validateValue(schema map[string]any, value any, pointer string, violations *[]string) {
+	if !matchesType(schema["type"], value) {
+		*violations = append(*violations, fmt.Sprintf("%s: expected %v, got %s", pointerOrRoot(pointer), schema["type"], jsonTypeName(value)))
+		return
+	}
+	switch v := value.(type) {
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s/%d", pointer, i), violations)
+			}
+		}
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]string); ok {
+			for _, name := range required {
+				if _, present := v[name]; !present {
+					*violations = append(*violations, fmt.Sprintf("%s: missing required property %q", pointerOrRoot(pointer), name))
+				}
+			}
+		}
+		for _, key := range sortedKeys(v) {
+			child := pointer + "/" + escapePointer(key)
+			if prop, ok := properties[key].(map[string]any); ok {
+				validateValue(prop, v[key], child, violations)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					*violations = append(*violations, fmt.Sprintf("%s: unexpected property", child))
+				}
+			case map[string]any:
+				validateValue(extra, v[key], child, violations)
+			}
+		}
+	case string:
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				*violations = append(*violations, fmt.Sprintf("%s: invalid date-time %q", pointerOrRoot(pointer), v))
+			}
+		}
+	}
+}
+
+func matchesType(want any, value any) bool {
+	switch w := want.(type) {
+	case nil:
+		return true
+	case string:
+		return jsonTypeMatches(w, value)
+	case []any:
+		for _, option := range w {
+			if name, ok := option.(string); ok && jsonTypeMatches(name, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonTypeMatches(name string, value any) bool {
+	switch name {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	}
+	return jsonTypeName(value) == name
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func pointerOrRoot(pointer string) string {
+	if pointer == "" {
+		return "/"
+	}
+	return pointer
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// DetectArtifact guesses the artifact name from a file name and content
+func DetectArtifact(path string, data []byte) (string, bool) {
+	base := strings.ToLower(path)
+	if i := strings.LastIndexAny(base, `/\`); i != -1 {
+		base = base[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(base, "removed_calls"):
+		return "removed-calls", true
+	case base == "functions.json":
+		return "functions", true
+	case strings.Contains(base, "functionmap") || strings.Contains(base, "relations"):
+		if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+			return "functionmap-v1", true
+		}
+		return "functionmap", true
+	}
+	return "", false
+}
+
+// SchemaArtifactNames returns the artifact names in sorted order, for usage messages
+func SchemaArtifactNames() []string {
+	names := make([]string, 0, len(SchemaArtifacts))
+	for _, a := range SchemaArtifacts {
+		names = append(names, a.Name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,304 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the functionmap.json document version written by this analyzer.
+// Version 1 is the legacy bare []OutRelation array, which LoadSnapshot migrates on read.
+const SchemaVersion = 2
+
+// Version identifies the analyzer build that produced a snapshot; override with
+// -ldflags "-X github.com/chinmay-sawant/gomindmapper/cmd/analyzer.Version=..."
+var Version = "0.1.0"
+
+// ErrIncompatibleSnapshot is returned when a snapshot cannot be used for the current repository or flags
+var ErrIncompatibleSnapshot = errors.New("incompatible snapshot")
+
+// ErrStaleSnapshot is returned when a snapshot may no longer describe the current source tree
+var ErrStaleSnapshot = errors.New("stale snapshot")
+
+// VCSInfo records the source revision a snapshot was generated from
+type VCSInfo struct {
+	System string `json:"system"`
+	Commit string `json:"commit"`
+}
+
+// SnapshotRun describes one analysis run. Unlike the rest of the header it differs between runs of the
+// same source, so NewSnapshot leaves it out and the canonical output stays byte-identical.
+type SnapshotRun struct {
+	Toolchain   string    `json:"toolchain"`   // Go toolchain that ran the analyzer
+	Dirty       bool      `json:"dirty"`       // the git work tree had uncommitted changes
+	GeneratedAt time.Time `json:"generatedAt"` // when the relations were analyzed
+}
+
+// SnapshotFlags records the analyzer options that affect the generated relations
+type SnapshotFlags struct {
+	IncludeExternal bool     `json:"includeExternal"`
+	SkipPatterns    []string `json:"skipPatterns,omitempty"`
+}
+
+// SnapshotStats summarizes the relation set
+type SnapshotStats struct {
+	Functions   int    `json:"functions"`
+	Relations   int    `json:"relations"`
+	Edges       int    `json:"edges"`
+	Roots       int    `json:"roots"`
+	ContentHash string `json:"contentHash"`
+}
+
+// SnapshotHeader is the metadata block of a functionmap.json document
+type SnapshotHeader struct {
+	SchemaVersion     int           `json:"schemaVersion"`
+	Module            string        `json:"module"`
+	GoVersion         string        `json:"goVersion,omitempty"` // go directive from go.mod
+	AnalyzerVersion   string        `json:"analyzerVersion"`
+	VCS               *VCSInfo      `json:"vcs,omitempty"`
+	SourceFingerprint string        `json:"sourceFingerprint,omitempty"` // SourceFingerprint of the analyzed tree
+	Flags             SnapshotFlags `json:"flags"`
+	Stats             SnapshotStats `json:"stats"`
+	Run               *SnapshotRun  `json:"run,omitempty"`      // set by NewSnapshotRun; outside the canonical output
+	Migrated          bool          `json:"migrated,omitempty"` // loaded from a legacy bare-array file
+}
+
+// Snapshot is a versioned functionmap.json document: header plus relations in canonical order
+type Snapshot struct {
+	Header    SnapshotHeader `json:"header"`
+	Relations []OutRelation  `json:"relations"`
+	Roots     *SnapshotRoots `json:"-"` // prebuilt roots; only binary snapshots store them
+}
+
+// SnapshotRoots are the classified roots of a snapshot's relations, whose node IDs are relation indices
+type SnapshotRoots struct {
+	Classifier string // Digest of the RootClassifier that found them
+	Roots      []Root
+}
+
+// NewSnapshot builds a snapshot for relations analyzed from repoPath. Relations are sorted in place.
+// functionCount is the number of functions fed to BuildRelations. The header records only what the
+// source and flags determine, so the same input always encodes to the same bytes; attach a
+// NewSnapshotRun to record the run itself.
+func NewSnapshot(repoPath string, relations []OutRelation, functionCount int, flags SnapshotFlags) (Snapshot, error) {
+	SortRelations(relations)
+	hash, err := RelationsHash(relations)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	module, _ := GetModule(repoPath)
+	goVersion, _ := GetGoVersion(repoPath)
+	fingerprint, err := SourceFingerprint(repoPath)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	header := SnapshotHeader{
+		SchemaVersion:     SchemaVersion,
+		Module:            module,
+		GoVersion:         goVersion,
+		AnalyzerVersion:   Version,
+		VCS:               DetectVCS(repoPath),
+		SourceFingerprint: fingerprint,
+		Flags:             flags,
+		Stats:             ComputeStats(relations, functionCount),
+	}
+	header.Stats.ContentHash = hash
+	return Snapshot{Header: header, Relations: relations}, nil
+}
+
+// ComputeStats counts relations, call edges and roots (relations no other relation calls)
+func ComputeStats(relations []OutRelation, functionCount int) SnapshotStats {
+	stats := SnapshotStats{Functions: functionCount, Relations: len(relations)}
+	called := make(map[string]bool)
+	for _, r := range relations {
+		stats.Edges += len(r.Called)
+		for _, c := range r.Called {
+			called[c.Name+"|"+c.FilePath] = true
+		}
+	}
+	for _, r := range relations {
+		if !called[r.Name+"|"+r.FilePath] {
+			stats.Roots++
+		}
+	}
+	return stats
+}
+
+// WriteSnapshot streams the snapshot to path as indented JSON
+func WriteSnapshot(path string, snapshot Snapshot) error {
+	return writeSnapshotFile(path, snapshot)
+}
+
+// LoadSnapshot reads a functionmap.json document without holding the raw file in memory. Legacy bare
+// arrays are migrated to the current schema with Header.Migrated set; documents from a newer schema
+// return ErrIncompatibleSnapshot.
+func LoadSnapshot(path string) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+	return ReadSnapshot(f)
+}
+
+// DecodeSnapshot parses snapshot bytes; see LoadSnapshot
+func DecodeSnapshot(data []byte) (Snapshot, error) {
+	return ReadSnapshot(bytes.NewReader(data))
+}
+
+// migrateLegacySnapshot wraps a schema v1 bare array in a header. Module, flags and VCS are unknown.
+func migrateLegacySnapshot(relations []OutRelation) (Snapshot, error) {
+	SortRelations(relations)
+	hash, err := RelationsHash(relations)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	header := SnapshotHeader{
+		SchemaVersion: SchemaVersion,
+		Stats:         ComputeStats(relations, 0),
+		Migrated:      true,
+	}
+	header.Stats.ContentHash = hash
+	return Snapshot{Header: header, Relations: relations}, nil
+}
+
+// CheckCompatible reports whether a snapshot can stand in for a fresh analysis of module with the given flags.
+// Migrated legacy snapshots carry no module or flags, so nothing shows they match and they are rejected.
+func (h SnapshotHeader) CheckCompatible(module string, includeExternal bool) error {
+	if h.Migrated {
+		return fmt.Errorf("%w: legacy snapshot records no module or flags", ErrIncompatibleSnapshot)
+	}
+	if h.Module != module {
+		return fmt.Errorf("%w: generated for module %q, repository is %q", ErrIncompatibleSnapshot, h.Module, module)
+	}
+	if h.Flags.IncludeExternal != includeExternal {
+		return fmt.Errorf("%w: generated with include-external=%t, server runs with include-external=%t", ErrIncompatibleSnapshot, h.Flags.IncludeExternal, includeExternal)
+	}
+	return nil
+}
+
+// CheckFresh reports whether a snapshot still describes the tree whose SourceFingerprint is fingerprint,
+// analyzed for module with flags. Beyond CheckCompatible it compares the skip patterns (which only
+// matter with include-external), the analyzer version and the fingerprint. Mismatched module or flags,
+// and legacy snapshots, return ErrIncompatibleSnapshot; anything that only means the data may be outdated
+// returns ErrStaleSnapshot.
+func (h SnapshotHeader) CheckFresh(module string, flags SnapshotFlags, fingerprint string) error {
+	if err := h.CheckCompatible(module, flags.IncludeExternal); err != nil {
+		return err
+	}
+	if flags.IncludeExternal && !sameStringSet(h.Flags.SkipPatterns, flags.SkipPatterns) {
+		return fmt.Errorf("%w: generated with skip-folders %v, server runs with %v", ErrIncompatibleSnapshot, h.Flags.SkipPatterns, flags.SkipPatterns)
+	}
+	if h.AnalyzerVersion != Version {
+		return fmt.Errorf("%w: generated by analyzer %s, this is %s", ErrStaleSnapshot, h.AnalyzerVersion, Version)
+	}
+	if h.SourceFingerprint == "" {
+		return fmt.Errorf("%w: snapshot records no source fingerprint", ErrStaleSnapshot)
+	}
+	if h.SourceFingerprint != fingerprint {
+		return fmt.Errorf("%w: source tree changed since the snapshot was generated", ErrStaleSnapshot)
+	}
+	return nil
+}
+
+// DetectVCS returns the git commit of repoPath, or nil when it is not a git work tree
+func DetectVCS(repoPath string) *VCSInfo {
+	commit, err := exec.Command("git", "-C", repoPath, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return nil
+	}
+	return &VCSInfo{System: "git", Commit: strings.TrimSpace(string(commit))}
+}
+
+// NewSnapshotRun describes an analysis of repoPath run now. GeneratedAt honours SOURCE_DATE_EPOCH.
+func NewSnapshotRun(repoPath string) *SnapshotRun {
+	run := &SnapshotRun{Toolchain: runtime.Version(), GeneratedAt: time.Now().UTC().Truncate(time.Second)}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if secs, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			run.GeneratedAt = time.Unix(secs, 0).UTC()
+		}
+	}
+	if status, err := exec.Command("git", "-C", repoPath, "status", "--porcelain").Output(); err == nil {
+		run.Dirty = len(bytes.TrimSpace(status)) > 0
+	}
+	return run
+}
+
+// GeneratedAt returns when the relations were analyzed, or nil when the header records no run
+func (h SnapshotHeader) GeneratedAt() *time.Time {
+	if h.Run == nil {
+		return nil
+	}
+	generatedAt := h.Run.GeneratedAt
+	return &generatedAt
+}
+
+// MergeSnapshots combines snapshots generated separately (e.g. one per service) into one. Relations
+// describing the same function (same name, file and line) are unified with the union of their calls.
+// A single snapshot is returned unchanged. The merged header keeps the module only when all inputs
+// agree, includes external calls when any input does, and keeps the run of the newest input.
+func MergeSnapshots(snapshots []Snapshot) (Snapshot, error) {
+	if len(snapshots) == 1 {
+		return snapshots[0], nil
+	}
+
+	header := SnapshotHeader{SchemaVersion: SchemaVersion, AnalyzerVersion: Version}
+	var relations []OutRelation
+	position := make(map[string]int)
+	functions := 0
+	for i, snapshot := range snapshots {
+		h := snapshot.Header
+		if i == 0 {
+			header.Module = h.Module
+		} else if header.Module != h.Module {
+			header.Module = ""
+		}
+		header.Flags.IncludeExternal = header.Flags.IncludeExternal || h.Flags.IncludeExternal
+		if h.Run != nil && (header.Run == nil || h.Run.GeneratedAt.After(header.Run.GeneratedAt)) {
+			header.Run = h.Run
+		}
+		functions += h.Stats.Functions
+
+		for _, r := range snapshot.Relations {
+			key := r.Name + "|" + r.FilePath + "|" + strconv.Itoa(r.Line)
+			j, ok := position[key]
+			if !ok {
+				position[key] = len(relations)
+				r.Called = append([]OutCalled(nil), r.Called...)
+				relations = append(relations, r)
+				continue
+			}
+			for _, c := range r.Called {
+				if !containsCalled(relations[j].Called, c) {
+					relations[j].Called = append(relations[j].Called, c)
+				}
+			}
+		}
+	}
+
+	SortRelations(relations)
+	hash, err := RelationsHash(relations)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	header.Stats = ComputeStats(relations, functions)
+	header.Stats.ContentHash = hash
+	return Snapshot{Header: header, Relations: relations}, nil
+}
+
+func containsCalled(called []OutCalled, c OutCalled) bool {
+	for _, existing := range called {
+		if existing == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotHeaderChecks(t *testing.T) {
+	flags := SnapshotFlags{IncludeExternal: true, SkipPatterns: []string{"golang.org"}}
+	current := SnapshotHeader{
+		SchemaVersion:     SchemaVersion,
+		AnalyzerVersion:   Version,
+		Module:            "example.com/app",
+		Flags:             flags,
+		SourceFingerprint: "fp",
+	}
+	with := func(edit func(*SnapshotHeader)) SnapshotHeader {
+		h := current
+		edit(&h)
+		return h
+	}
+	tests := []struct {
+		name       string
+		header     SnapshotHeader
+		compatible error // CheckCompatible
+		fresh      error // CheckFresh
+	}{
+		{name: "current", header: current},
+		{
+			name:       "legacy",
+			header:     SnapshotHeader{SchemaVersion: SchemaVersion, Migrated: true},
+			compatible: ErrIncompatibleSnapshot,
+			fresh:      ErrIncompatibleSnapshot,
+		},
+		{
+			name:       "other module",
+			header:     with(func(h *SnapshotHeader) { h.Module = "example.com/other" }),
+			compatible: ErrIncompatibleSnapshot,
+			fresh:      ErrIncompatibleSnapshot,
+		},
+		{
+			name:       "other include-external",
+			header:     with(func(h *SnapshotHeader) { h.Flags.IncludeExternal = false }),
+			compatible: ErrIncompatibleSnapshot,
+			fresh:      ErrIncompatibleSnapshot,
+		},
+		{
+			name:   "other skip patterns",
+			header: with(func(h *SnapshotHeader) { h.Flags.SkipPatterns = nil }),
+			fresh:  ErrIncompatibleSnapshot,
+		},
+		{
+			name:   "other analyzer version",
+			header: with(func(h *SnapshotHeader) { h.AnalyzerVersion = "0.0.1" }),
+			fresh:  ErrStaleSnapshot,
+		},
+		{
+			name:   "no fingerprint",
+			header: with(func(h *SnapshotHeader) { h.SourceFingerprint = "" }),
+			fresh:  ErrStaleSnapshot,
+		},
+		{
+			name:   "changed tree",
+			header: with(func(h *SnapshotHeader) { h.SourceFingerprint = "old" }),
+			fresh:  ErrStaleSnapshot,
+		},
+	}
+	check := func(t *testing.T, what string, err, want error) {
+		t.Helper()
+		if want == nil && err != nil || want != nil && !errors.Is(err, want) {
+			t.Errorf("%s = %v, want %v", what, err, want)
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check(t, "CheckCompatible", tt.header.CheckCompatible("example.com/app", true), tt.compatible)
+			check(t, "CheckFresh", tt.header.CheckFresh("example.com/app", flags, "fp"), tt.fresh)
+		})
+	}
+}
+
+func TestDecodeLegacySnapshot(t *testing.T) {
+	snap, err := DecodeSnapshot([]byte(`[{"name":"b.B","line":1,"filePath":"b.go","called":null},{"name":"a.A","line":2,"filePath":"a.go","called":[{"name":"b.B","line":1,"filePath":"b.go"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snap.Header.Migrated || snap.Header.SchemaVersion != SchemaVersion {
+		t.Errorf("header = %+v, want a migrated current-schema header", snap.Header)
+	}
+	if len(snap.Relations) != 2 || snap.Relations[0].Name != "a.A" {
+		t.Errorf("relations = %+v, want both, sorted", snap.Relations)
+	}
+	if err := snap.Header.CheckCompatible("", false); !errors.Is(err, ErrIncompatibleSnapshot) {
+		t.Errorf("CheckCompatible = %v, want ErrIncompatibleSnapshot", err)
+	}
+}
+
+func TestNewSnapshotIsDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	gitCommit(t, dir, map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.23\n",
+		"main.go": "package main\n\nfunc main() { run() }\n\nfunc run() {}\n",
+	})
+	// A dirty work tree must not leak into the canonical output either
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n\nfunc main() { run() }\n\nfunc run() {}\n\n"})
+
+	write := func(relations []OutRelation) []byte {
+		t.Helper()
+		snapshot, err := NewSnapshot(dir, relations, 2, SnapshotFlags{SkipPatterns: []string{"golang.org"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snapshot.Header.Run != nil {
+			t.Errorf("header.run = %+v, want none", snapshot.Header.Run)
+		}
+		path := filepath.Join(t.TempDir(), "functionmap.json")
+		if err := WriteSnapshot(path, snapshot); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	first := write([]OutRelation{
+		{Name: "main.main", Line: 3, FilePath: "main.go", Called: []OutCalled{{Name: "main.run", Line: 5, FilePath: "main.go"}}},
+		{Name: "main.run", Line: 5, FilePath: "main.go"},
+	})
+	second := write([]OutRelation{
+		{Name: "main.run", Line: 5, FilePath: "main.go"},
+		{Name: "main.main", Line: 3, FilePath: "main.go", Called: []OutCalled{{Name: "main.run", Line: 5, FilePath: "main.go"}}},
+	})
+	if !bytes.Equal(first, second) {
+		t.Errorf("NewSnapshot output differs between runs:\n%s\n%s", first, second)
+	}
+}
@@ -0,0 +1,210 @@
+package analyzer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SnapshotWriter encodes a snapshot document incrementally: the header is written up front and each
+// relation is encoded as it is added, so the full document is never held in memory. The output is
+// byte-identical to json.MarshalIndent(snapshot, "", "  ") for a non-nil relation slice.
+type SnapshotWriter struct {
+	w       *bufio.Writer
+	count   int
+	flusher interface{ Flush() }
+}
+
+// NewSnapshotWriter writes the document opening and header to w
+func NewSnapshotWriter(w io.Writer, header SnapshotHeader) (*SnapshotWriter, error) {
+	headerData, err := json.MarshalIndent(header, "  ", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sw := &SnapshotWriter{w: bufio.NewWriterSize(w, 64*1024)}
+	if f, ok := w.(interface{ Flush() }); ok {
+		sw.flusher = f
+	}
+	sw.w.WriteString("{\n  \"header\": ")
+	sw.w.Write(headerData)
+	_, err = sw.w.WriteString(",\n  \"relations\": [")
+	return sw, err
+}
+
+// WriteRelation appends one relation to the relations array
+func (sw *SnapshotWriter) WriteRelation(r OutRelation) error {
+	data, err := json.MarshalIndent(r, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	if sw.count > 0 {
+		sw.w.WriteByte(',')
+	}
+	sw.w.WriteString("\n    ")
+	_, err = sw.w.Write(data)
+	sw.count++
+	return err
+}
+
+// Flush pushes buffered output to the underlying writer (and flushes it when it supports Flush,
+// e.g. an http.ResponseWriter sending chunked output)
+func (sw *SnapshotWriter) Flush() error {
+	if err := sw.w.Flush(); err != nil {
+		return err
+	}
+	if sw.flusher != nil {
+		sw.flusher.Flush()
+	}
+	return nil
+}
+
+// Close terminates the document and flushes it
+func (sw *SnapshotWriter) Close() error {
+	if sw.count > 0 {
+		sw.w.WriteString("\n  ")
+	}
+	if _, err := sw.w.WriteString("]\n}"); err != nil {
+		return err
+	}
+	return sw.Flush()
+}
+
+// WriteSnapshotStream encodes a whole snapshot through a SnapshotWriter, flushing every flushEvery
+// relations (0 disables intermediate flushes)
+func WriteSnapshotStream(w io.Writer, snapshot Snapshot, flushEvery int) error {
+	sw, err := NewSnapshotWriter(w, snapshot.Header)
+	if err != nil {
+		return err
+	}
+	for i, r := range snapshot.Relations {
+		if err := sw.WriteRelation(r); err != nil {
+			return err
+		}
+		if flushEvery > 0 && (i+1)%flushEvery == 0 {
+			if err := sw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return sw.Close()
+}
+
+// DecodeSnapshotStream decodes a functionmap.json document token by token, calling fn for each
+// relation as soon as it is decoded. It accepts both the current document and legacy bare arrays
+// (reported through migrated). The returned header is zero for legacy input.
+func DecodeSnapshotStream(r io.Reader, fn func(OutRelation) error) (header SnapshotHeader, migrated bool, err error) {
+	dec := json.NewDecoder(bufio.NewReaderSize(r, 64*1024))
+	tok, err := dec.Token()
+	if err != nil {
+		return header, false, fmt.Errorf("empty snapshot: %v", err)
+	}
+
+	switch tok {
+	case json.Delim('['):
+		if err := decodeRelationArray(dec, fn); err != nil {
+			return header, true, err
+		}
+		return header, true, nil
+	case json.Delim('{'):
+	default:
+		return header, false, fmt.Errorf("unexpected snapshot token %v", tok)
+	}
+
+	sawHeader := false
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return header, false, err
+		}
+		key, _ := keyTok.(string)
+		switch key {
+		case "header":
+			if err := dec.Decode(&header); err != nil {
+				return header, false, err
+			}
+			if err := checkSchemaVersion(header); err != nil {
+				return header, false, err
+			}
+			sawHeader = true
+		case "relations":
+			tok, err := dec.Token()
+			if err != nil {
+				return header, false, err
+			}
+			if tok == nil {
+				continue // "relations": null
+			}
+			if tok != json.Delim('[') {
+				return header, false, fmt.Errorf("relations must be an array")
+			}
+			if err := decodeRelationArray(dec, fn); err != nil {
+				return header, false, err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return header, false, err
+			}
+		}
+	}
+	if !sawHeader {
+		return header, false, fmt.Errorf("%w: missing header.schemaVersion", ErrIncompatibleSnapshot)
+	}
+	return header, false, nil
+}
+
+// decodeRelationArray decodes the elements of an already-opened array and consumes the closing bracket
+func decodeRelationArray(dec *json.Decoder, fn func(OutRelation) error) error {
+	for dec.More() {
+		var rel OutRelation
+		if err := dec.Decode(&rel); err != nil {
+			return err
+		}
+		if err := fn(rel); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func checkSchemaVersion(header SnapshotHeader) error {
+	if header.SchemaVersion == 0 {
+		return fmt.Errorf("%w: missing header.schemaVersion", ErrIncompatibleSnapshot)
+	}
+	if header.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("%w: schema version %d is newer than supported version %d", ErrIncompatibleSnapshot, header.SchemaVersion, SchemaVersion)
+	}
+	return nil
+}
+
+// ReadSnapshot decodes a snapshot from r without buffering the raw document; legacy arrays are migrated
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	var relations []OutRelation
+	header, migrated, err := DecodeSnapshotStream(r, func(rel OutRelation) error {
+		relations = append(relations, rel)
+		return nil
+	})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if migrated {
+		return migrateLegacySnapshot(relations)
+	}
+	return Snapshot{Header: header, Relations: relations}, nil
+}
+
+// writeSnapshotFile streams a snapshot to path
+func writeSnapshotFile(path string, snapshot Snapshot) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteSnapshotStream(f, snapshot, 0); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
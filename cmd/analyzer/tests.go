@@ -0,0 +1,337 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// reTestDecl matches the declaration of a test go test runs: func TestXxx(t *testing.T)
+var reTestDecl = regexp.MustCompile(`^func\s+(Test(?:[^\p{Ll}\W]\w*)?)\s*\(\s*\w+\s+\*testing\.T\s*\)`)
+
+// reLocalCall matches calls without a qualifier, e.g. newFixture(...) or Parse(...); FindCalls leaves
+// them out, but tests in the package under test call it this way
+var reLocalCall = regexp.MustCompile(`(?:^|[^\w.])([A-Za-z_]\w*)\(`)
+
+// testFunc is a function declared in a _test.go file: a test or a helper
+type testFunc struct {
+	name    string // declared name, e.g. TestParse or newFixture
+	pkg     string // package under test: the package clause without _test
+	dir     string // directory of the file relative to the root
+	file    string // path relative to the root
+	line    int
+	end     int
+	isTest  bool
+	calls   []string // production functions it may call, named like the relations (package.Function)
+	helpers []int    // test-file functions of the same package it calls
+}
+
+// TestIndex holds the tests and test helpers of a repository with the functions they call. The relation
+// analysis skips _test.go files, so tests are scanned on their own, the same way project files are.
+type TestIndex struct {
+	funcs []testFunc
+}
+
+// FindTests scans the _test.go files under root. Qualified calls are resolved like in project files,
+// through the file's imports; unqualified ones go to the test helpers of the package or else to the
+// package under test. Method calls are kept by method name in the package under test, which may select
+// a test too many but never one too few. Directories go test ignores (testdata, vendor, names starting
+// with . or _) are skipped.
+func FindTests(ctx context.Context, root, module string) (*TestIndex, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, "_test.go") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type pending struct {
+		index int
+		pkg   string // package under test: the package clause without _test
+		calls []string
+	}
+	index := &TestIndex{}
+	var unresolved []pending
+	resolver := newImportResolver()
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil, err
+		}
+		imports, err := resolver.parse(path, root, module)
+		if err != nil {
+			imports = ImportTable{}
+		}
+		lines := strings.Split(string(content), "\n")
+		pkg := strings.TrimSuffix(packageNameOf(lines), "_test")
+
+		for i, line := range lines {
+			name := declaredFunction(line)
+			if name == "" {
+				continue
+			}
+			f := testFunc{name: name, pkg: pkg, dir: filepath.Dir(relPath), file: relPath, line: i + 1, end: i + 1, isTest: reTestDecl.MatchString(line)}
+			var calls []string
+			if start, end := FindFunctionBody(lines, i); start != -1 && end != -1 {
+				f.end = end + 1
+				if start+1 < end {
+					body := lines[start+1 : end]
+					for _, call := range FindCalls(body) {
+						resolved, keep := imports.ResolveCall(call)
+						if !keep {
+							continue
+						}
+						if first := strings.Index(call, "."); first != -1 {
+							if _, imported := imports.Aliases[call[:first]]; !imported {
+								// A method call on a variable: the method of a type in the package under test
+								resolved = pkg + call[strings.LastIndex(call, "."):]
+							}
+						}
+						calls = appendUnique(calls, resolved)
+					}
+					for _, line := range body {
+						for _, match := range reLocalCall.FindAllStringSubmatch(line, -1) {
+							calls = appendUnique(calls, match[1])
+						}
+					}
+					calls = append(calls, imports.ResolveDotImportCalls(body)...)
+				}
+			}
+			unresolved = append(unresolved, pending{index: len(index.funcs), pkg: pkg, calls: calls})
+			index.funcs = append(index.funcs, f)
+		}
+	}
+
+	// Helpers are known once every test file of a directory is read
+	helpers := make(map[string]int)
+	for i, f := range index.funcs {
+		helpers[f.dir+"\x00"+f.name] = i
+	}
+	for _, p := range unresolved {
+		f := &index.funcs[p.index]
+		for _, call := range p.calls {
+			if strings.Contains(call, ".") {
+				f.calls = appendUnique(f.calls, call)
+				continue
+			}
+			if h, ok := helpers[f.dir+"\x00"+call]; ok && h != p.index {
+				f.helpers = append(f.helpers, h)
+				continue
+			}
+			f.calls = appendUnique(f.calls, p.pkg+"."+call)
+		}
+	}
+	return index, nil
+}
+
+// Len returns the number of tests in the index
+func (ix *TestIndex) Len() int {
+	count := 0
+	for _, f := range ix.funcs {
+		if f.isTest {
+			count++
+		}
+	}
+	return count
+}
+
+// Relations returns a relation per test, calling the functions of relations it reaches directly or
+// through the test helpers of its package, so tests can be listed as roots (RootTest) next to the
+// relations they exercise. Calls are linked by name to the first function of that name, as a relation
+// or a call entry, in canonical order; calls to functions the relations do not know are left out.
+func (ix *TestIndex) Relations(relations []OutRelation) []OutRelation {
+	known := make(map[string]OutCalled)
+	for _, r := range relations {
+		if _, ok := known[r.Name]; !ok {
+			known[r.Name] = OutCalled{Name: r.Name, Line: r.Line, FilePath: r.FilePath}
+		}
+		for _, c := range r.Called {
+			if _, ok := known[c.Name]; !ok {
+				known[c.Name] = c
+			}
+		}
+	}
+
+	var tests []OutRelation
+	for i, f := range ix.funcs {
+		if !f.isTest {
+			continue
+		}
+		test := OutRelation{Name: f.pkg + "." + f.name, Line: f.line, FilePath: f.file}
+		seen := make(map[string]bool)
+		visited := map[int]bool{i: true}
+		var visit func(fn testFunc)
+		visit = func(fn testFunc) {
+			for _, call := range fn.calls {
+				if c, ok := known[call]; ok && !seen[call] {
+					seen[call] = true
+					test.Called = append(test.Called, c)
+				}
+			}
+			for _, h := range fn.helpers {
+				if !visited[h] {
+					visited[h] = true
+					visit(ix.funcs[h])
+				}
+			}
+		}
+		visit(f)
+		tests = append(tests, test)
+	}
+	SortRelations(tests)
+	return tests
+}
+
+// GoTest is a test selected to run
+type GoTest struct {
+	Name     string `json:"name"`
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+}
+
+// TestPackage is a package with selected tests. Package is its directory relative to the analyzed root
+// in the form go test takes (./cmd/analyzer), and Run the -run pattern matching exactly its tests.
+type TestPackage struct {
+	Package string   `json:"package"`
+	Run     string   `json:"run"`
+	Tests   []GoTest `json:"tests"`
+}
+
+// TestSelection is the output of the tests command: the tests that statically reach a change
+type TestSelection struct {
+	Changed       []ChangedFunction `json:"changed"`
+	Affected      int               `json:"affected"`      // changed functions and their transitive callers
+	ChangedTests  []string          `json:"changedTests"`  // test files the change touches; their changed tests and helpers count as reaching it
+	TotalTests    int               `json:"totalTests"`    // tests found in the repository
+	SelectedTests int               `json:"selectedTests"` // tests selected, over all packages
+	Packages      []TestPackage     `json:"packages"`
+}
+
+// SelectTests picks the tests that call, directly or through test helpers of their package, a function
+// of the impact report (a changed function or one of its callers), and the tests whose own lines, or
+// whose helpers' lines, changes touches in _test.go files.
+func SelectTests(index *TestIndex, report ImpactReport, changes []FileChange) TestSelection {
+	affected := make(map[string]bool)
+	for _, c := range report.Changed {
+		affected[c.Name] = true
+	}
+	for _, c := range report.Callers {
+		affected[c.Name] = true
+	}
+
+	// Test-file functions the change touches directly
+	touched := make([]bool, len(index.funcs))
+	testFiles := make(map[string]bool)
+	for _, c := range changes {
+		if !strings.HasSuffix(c.NewPath, "_test.go") {
+			continue
+		}
+		path := filepath.FromSlash(c.NewPath)
+		testFiles[filepath.ToSlash(path)] = true
+		for i, f := range index.funcs {
+			if f.file == path && overlaps(FunctionRange{Start: f.line, End: f.end}, c.NewLines) {
+				touched[i] = true
+			}
+		}
+	}
+
+	// A function reaches the change through its own calls, or through a helper that does; helpers may
+	// call each other in cycles, so reachability is propagated until nothing changes
+	reaches := make([]bool, len(index.funcs))
+	for i, f := range index.funcs {
+		reaches[i] = touched[i]
+		for _, call := range f.calls {
+			reaches[i] = reaches[i] || affected[call]
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for i, f := range index.funcs {
+			if reaches[i] {
+				continue
+			}
+			for _, h := range f.helpers {
+				if reaches[h] {
+					reaches[i], changed = true, true
+					break
+				}
+			}
+		}
+	}
+
+	selection := TestSelection{Changed: report.Changed, Affected: len(affected), TotalTests: index.Len(), ChangedTests: []string{}, Packages: []TestPackage{}}
+	for f := range testFiles {
+		selection.ChangedTests = append(selection.ChangedTests, f)
+	}
+	sort.Strings(selection.ChangedTests)
+	packages := make(map[string]*TestPackage)
+	for i, f := range index.funcs {
+		if !f.isTest || !reaches[i] {
+			continue
+		}
+		dir := "./" + filepath.ToSlash(f.dir)
+		if f.dir == "." {
+			dir = "."
+		}
+		pkg := packages[dir]
+		if pkg == nil {
+			pkg = &TestPackage{Package: dir}
+			packages[dir] = pkg
+		}
+		pkg.Tests = append(pkg.Tests, GoTest{Name: f.name, FilePath: f.file, Line: f.line})
+		selection.SelectedTests++
+	}
+	for _, pkg := range packages {
+		sort.Slice(pkg.Tests, func(i, j int) bool { return pkg.Tests[i].Name < pkg.Tests[j].Name })
+		names := make([]string, 0, len(pkg.Tests))
+		for _, t := range pkg.Tests {
+			if !contains(names, t.Name) {
+				names = append(names, t.Name)
+			}
+		}
+		pkg.Run = "^(" + strings.Join(names, "|") + ")$"
+		selection.Packages = append(selection.Packages, *pkg)
+	}
+	sort.Slice(selection.Packages, func(i, j int) bool { return selection.Packages[i].Package < selection.Packages[j].Package })
+	return selection
+}
+
+// WriteCommands writes one go test command per package, ready to run from the analyzed root
+func (s TestSelection) WriteCommands(w io.Writer) error {
+	if len(s.Packages) == 0 {
+		_, err := fmt.Fprintf(w, "# no test reaches the change (%d changed function(s), %d test(s) scanned)\n", len(s.Changed), s.TotalTests)
+		return err
+	}
+	for _, pkg := range s.Packages {
+		if _, err := fmt.Fprintf(w, "go test -run '%s' %s\n", pkg.Run, pkg.Package); err != nil {
+			return err
+		}
+	}
+	return nil
+}
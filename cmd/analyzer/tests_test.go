@@ -0,0 +1,192 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+const selectionSvcTest = `package svc
+
+import (
+	"testing"
+
+	"example.com/app/store"
+)
+
+func TestDo(t *testing.T) {
+	Do()
+}
+
+func TestHelper(t *testing.T) {
+	setup()
+}
+
+func setup() {
+	helperTwo()
+}
+
+func helperTwo() {
+	store.Load()
+}
+
+func TestMethod(t *testing.T) {
+	var s Server
+	s.Run()
+}
+
+func TestUnrelated(t *testing.T) {
+	_ = 1
+}
+`
+
+func TestSelectTests(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":         "module example.com/app\n",
+		"store/store.go": "package store\n\nfunc Save() {}\n\nfunc Load() {}\n",
+		"store/store_test.go": "package store_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/app/store\"\n)\n\n" +
+			"func TestSave(t *testing.T) {\n\tstore.Save()\n}\n",
+		"svc/svc.go":               "package svc\n\nimport \"example.com/app/store\"\n\nfunc Do() {\n\tstore.Save()\n}\n",
+		"svc/svc_test.go":          selectionSvcTest,
+		"svc/testdata/old_test.go": "package svc\n\nfunc TestIgnored(t *testing.T) {\n\tDo()\n}\n",
+	})
+	index, err := FindTests(context.Background(), root, "example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Len() != 5 {
+		t.Fatalf("found %d tests, want 5", index.Len())
+	}
+
+	changed := func(names ...string) []ChangedFunction {
+		var out []ChangedFunction
+		for _, n := range names {
+			out = append(out, ChangedFunction{Name: n})
+		}
+		return out
+	}
+	testLines := func(start, end int) []FileChange {
+		return []FileChange{{OldPath: "svc/svc_test.go", NewPath: "svc/svc_test.go", NewLines: []LineRange{{start, end}}}}
+	}
+	tests := []struct {
+		name         string
+		report       ImpactReport
+		changes      []FileChange
+		want         map[string]string // package: run pattern
+		changedTests []string
+	}{
+		{
+			name:   "direct and through callers",
+			report: ImpactReport{Changed: changed("store.Save"), Callers: []ImpactedCaller{{Name: "svc.Do"}}},
+			want:   map[string]string{"./store": "^(TestSave)$", "./svc": "^(TestDo)$"},
+		},
+		{
+			name:   "through a chain of helpers",
+			report: ImpactReport{Changed: changed("store.Load")},
+			want:   map[string]string{"./svc": "^(TestHelper)$"},
+		},
+		{
+			name:   "method call on a variable",
+			report: ImpactReport{Changed: changed("svc.Run")},
+			want:   map[string]string{"./svc": "^(TestMethod)$"},
+		},
+		{
+			name:         "changed test",
+			changes:      testLines(31, 31),
+			want:         map[string]string{"./svc": "^(TestUnrelated)$"},
+			changedTests: []string{"svc/svc_test.go"},
+		},
+		{
+			name:         "changed helper",
+			changes:      testLines(22, 22),
+			want:         map[string]string{"./svc": "^(TestHelper)$"},
+			changedTests: []string{"svc/svc_test.go"},
+		},
+		{
+			name:   "nothing reaches the change",
+			report: ImpactReport{Changed: changed("store.Other")},
+			want:   map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := SelectTests(index, tt.report, tt.changes)
+			got := make(map[string]string)
+			selected := 0
+			for _, pkg := range selection.Packages {
+				got[pkg.Package] = pkg.Run
+				selected += len(pkg.Tests)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("packages = %v, want %v", got, tt.want)
+			}
+			if selection.SelectedTests != selected || selection.TotalTests != 5 {
+				t.Errorf("selected %d of %d, want %d of 5", selection.SelectedTests, selection.TotalTests, selected)
+			}
+			if want := tt.changedTests; want == nil && len(selection.ChangedTests) > 0 || want != nil && !reflect.DeepEqual(selection.ChangedTests, want) {
+				t.Errorf("changedTests = %v, want %v", selection.ChangedTests, want)
+			}
+		})
+	}
+}
+
+func TestTestSelectionWriteCommands(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection TestSelection
+		want      string
+	}{
+		{
+			name:      "none selected",
+			selection: TestSelection{Changed: []ChangedFunction{{Name: "a.A"}}, TotalTests: 3},
+			want:      "# no test reaches the change (1 changed function(s), 3 test(s) scanned)\n",
+		},
+		{
+			name: "one command per package",
+			selection: TestSelection{Packages: []TestPackage{
+				{Package: ".", Run: "^(TestMain)$"},
+				{Package: "./svc", Run: "^(TestA|TestB)$"},
+			}},
+			want: "go test -run '^(TestMain)$' .\ngo test -run '^(TestA|TestB)$' ./svc\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.selection.WriteCommands(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("commands = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTestIndexRelations(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":          "module example.com/app\n",
+		"svc/svc.go":      "package svc\n\nimport \"example.com/app/store\"\n\nfunc Do() {\n\tstore.Save()\n}\n",
+		"svc/svc_test.go": selectionSvcTest,
+	})
+	index, err := FindTests(context.Background(), root, "example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relations := []OutRelation{
+		{Name: "svc.Do", Line: 5, FilePath: "svc/svc.go", Called: []OutCalled{{Name: "store.Save", Line: 3, FilePath: "store/store.go"}}},
+		{Name: "svc.Run", Line: 9, FilePath: "svc/server.go", Called: []OutCalled{{Name: "store.Load", Line: 5, FilePath: "store/store.go"}}},
+	}
+	want := []OutRelation{
+		{Name: "svc.TestDo", Line: 9, FilePath: "svc/svc_test.go", Called: []OutCalled{{Name: "svc.Do", Line: 5, FilePath: "svc/svc.go"}}},
+		{Name: "svc.TestHelper", Line: 13, FilePath: "svc/svc_test.go", Called: []OutCalled{{Name: "store.Load", Line: 5, FilePath: "store/store.go"}}},
+		{Name: "svc.TestMethod", Line: 25, FilePath: "svc/svc_test.go", Called: []OutCalled{{Name: "svc.Run", Line: 9, FilePath: "svc/server.go"}}},
+		{Name: "svc.TestUnrelated", Line: 30, FilePath: "svc/svc_test.go"},
+	}
+	if got := index.Relations(relations); !reflect.DeepEqual(got, want) {
+		t.Errorf("Relations =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package analyzer
 
 import (
+	"context"
 	"go/ast"
 	"go/parser"
 	"go/token"
@@ -14,6 +15,10 @@ type ImportInfo struct {
 	Alias       string // import alias (empty if no alias)
 	Path        string // import path
 	PackageName string // actual package name
+	IsLocal     bool   // import belongs to the analyzed module
+	IsStdlib    bool   // import belongs to the standard library
+
+	decls map[string]bool // declared names, loaded for module-local dot imports only
 }
 
 // TypeInfo represents information about a type declaration
@@ -56,44 +61,49 @@ type MethodImplementation struct {
 
 // ParseTypeInformation extracts type information from Go files with enhanced import analysis
 func ParseTypeInformation(projectPath string, externalModules map[string]ExternalModuleInfo) (map[string]TypeInfo, error) {
-	typeInfo := make(map[string]TypeInfo)
-	fileInfoMap := make(map[string]FileTypeInfo)
+	project, err := ParseProject(context.Background(), projectPath, ParseOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return project.typeInformation(context.Background(), externalModules)
+}
 
-	// Parse project files for type declarations and imports
-	err := filepath.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// typeInformation merges the types declared by the project's files with those of externalModules.
+// Like ParseTypeInformation it fails when a project file does not parse.
+func (p *Project) typeInformation(ctx context.Context, externalModules map[string]ExternalModuleInfo) (map[string]TypeInfo, error) {
+	typeInfo := make(map[string]TypeInfo)
+	for _, file := range p.Files {
+		if file.ParseErr != nil {
+			return nil, file.ParseErr
 		}
-		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-			fileInfo, err := ParseGoFileForTypesAndImports(path, projectPath)
-			if err != nil {
-				return err
-			}
-			fileInfoMap[path] = fileInfo
-			for k, v := range fileInfo.Types {
-				typeInfo[k] = v
-			}
+		for k, v := range file.Types.Types {
+			typeInfo[k] = v
 		}
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
 	}
 
-	// Parse external modules for type declarations
-	for _, moduleInfo := range externalModules {
+	// Parse external modules for type declarations on the worker pool, merging in name order
+	modulePaths := sortedKeys(externalModules)
+	moduleTypes := make([]map[string]TypeInfo, len(modulePaths))
+	err := forEachParallel(ctx, p.Workers, len(modulePaths), func(i int) error {
+		moduleInfo := externalModules[modulePaths[i]]
 		localPath, err := FindModuleInGoPath(moduleInfo)
 		if err != nil {
-			continue // Skip modules that can't be found
+			return nil // Skip modules that can't be found
 		}
-
-		externalTypes, err := parseExternalModuleForTypes(localPath, moduleInfo.ModulePath)
+		types, err := p.Cache.moduleTypes(moduleInfo, func() (map[string]TypeInfo, error) {
+			return parseExternalModuleForTypes(localPath, moduleInfo.ModulePath)
+		})
 		if err != nil {
-			continue // Skip modules that can't be parsed
+			return nil // Skip modules that can't be parsed
 		}
-
-		for k, v := range externalTypes {
+		moduleTypes[i] = types
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, types := range moduleTypes {
+		for k, v := range types {
 			typeInfo[k] = v
 		}
 	}
@@ -108,7 +118,11 @@ func ParseGoFileForTypesAndImports(filePath, projectPath string) (FileTypeInfo,
 	if err != nil {
 		return FileTypeInfo{}, err
 	}
+	return fileTypesFromAST(node), nil
+}
 
+// fileTypesFromAST extracts type and import information from a parsed file
+func fileTypesFromAST(node *ast.File) FileTypeInfo {
 	fileInfo := FileTypeInfo{
 		Imports:    make(map[string]ImportInfo),
 		Types:      make(map[string]TypeInfo),
@@ -122,7 +136,7 @@ func ParseGoFileForTypesAndImports(filePath, projectPath string) (FileTypeInfo,
 	for _, imp := range node.Imports {
 		importPath := strings.Trim(imp.Path.Value, `"`)
 		alias := ""
-		pkgName := filepath.Base(importPath)
+		pkgName := guessPackageName(importPath)
 
 		if imp.Name != nil {
 			alias = imp.Name.Name
@@ -182,7 +196,7 @@ func ParseGoFileForTypesAndImports(filePath, projectPath string) (FileTypeInfo,
 		return true
 	})
 
-	return fileInfo, nil
+	return fileInfo
 }
 
 // parseGoFileForTypes parses a single Go file and extracts type information (legacy function)
@@ -382,9 +396,9 @@ func ResolveMethodCall(call string, fileInfoMap map[string]FileTypeInfo, allType
 
 // resolveDirectMethodCall resolves calls like "FormDatastore.GetFormId"
 func resolveDirectMethodCall(typeName, methodName string, fileInfoMap map[string]FileTypeInfo, allTypeInfo map[string]TypeInfo, implementations map[string][]InterfaceImplementation) string {
-	// First, try to find the interface implementation
-	for interfaceName, impls := range implementations {
-		for _, impl := range impls {
+	// First, try to find the interface implementation (interfaces in name order, implementations sorted by struct)
+	for _, interfaceName := range sortedKeys(implementations) {
+		for _, impl := range implementations[interfaceName] {
 			if strings.Contains(interfaceName, typeName) {
 				if _, exists := impl.Methods[methodName]; exists {
 					// Found the actual implementation! Return the struct method
@@ -395,7 +409,8 @@ func resolveDirectMethodCall(typeName, methodName string, fileInfoMap map[string
 	}
 
 	// Fallback to original logic
-	for _, info := range allTypeInfo {
+	for _, key := range sortedKeys(allTypeInfo) {
+		info := allTypeInfo[key]
 		if info.IsInterface && (strings.HasSuffix(info.Name, typeName) || strings.Contains(info.Name, typeName)) {
 			// Check if this interface has the method
 			for _, method := range info.Methods {
@@ -414,19 +429,21 @@ func resolveDirectMethodCall(typeName, methodName string, fileInfoMap map[string
 
 // resolveStructFieldMethodCall resolves calls like "svc.FormDatastore.GetFormId"
 func resolveStructFieldMethodCall(varName, fieldName, methodName string, fileInfoMap map[string]FileTypeInfo, allTypeInfo map[string]TypeInfo, implementations map[string][]InterfaceImplementation) string {
-	// Look through all struct definitions to find one with the specified field
-	for _, fileInfo := range fileInfoMap {
-		for _, structInfo := range fileInfo.Structs {
+	// Look through all struct definitions to find one with the specified field (files and structs in name order)
+	for _, filePath := range sortedKeys(fileInfoMap) {
+		fileInfo := fileInfoMap[filePath]
+		for _, structKey := range sortedKeys(fileInfo.Structs) {
+			structInfo := fileInfo.Structs[structKey]
 			if fieldType, exists := structInfo.Fields[fieldName]; exists {
 				// Found a struct with this field, now try to find interface implementation
 				resolvedType := resolveFieldType(fieldType, fileInfo.Imports, allTypeInfo)
 
 				// Look for interface implementations that match this field type
-				for interfaceName, impls := range implementations {
+				for _, interfaceName := range sortedKeys(implementations) {
 					// Check if the interface name matches the field type pattern
 					if strings.Contains(fieldType, strings.Split(interfaceName, ".")[1]) ||
 						strings.Contains(resolvedType, interfaceName) {
-						for _, impl := range impls {
+						for _, impl := range implementations[interfaceName] {
 							if _, methodExists := impl.Methods[methodName]; methodExists {
 								// Found the actual implementation!
 								return impl.StructName + "." + methodName
@@ -438,7 +455,8 @@ func resolveStructFieldMethodCall(varName, fieldName, methodName string, fileInf
 				// Fallback to original logic
 				if resolvedType != "" {
 					// Check if the resolved type has the method
-					for typeName, typeInfo := range allTypeInfo {
+					for _, typeName := range sortedKeys(allTypeInfo) {
+						typeInfo := allTypeInfo[typeName]
 						if matchesResolvedType(typeInfo, resolvedType) && typeInfo.IsInterface {
 							for _, method := range typeInfo.Methods {
 								if method == methodName {
@@ -451,7 +469,8 @@ func resolveStructFieldMethodCall(varName, fieldName, methodName string, fileInf
 				}
 
 				// Fallback: try matching the original field type directly
-				for typeName, typeInfo := range allTypeInfo {
+				for _, typeName := range sortedKeys(allTypeInfo) {
+					typeInfo := allTypeInfo[typeName]
 					if typeInfo.IsInterface && strings.Contains(fieldType, typeInfo.Name) {
 						for _, method := range typeInfo.Methods {
 							if method == methodName {
@@ -478,7 +497,8 @@ func resolveFieldType(fieldType string, imports map[string]ImportInfo, allTypeIn
 	}
 
 	// Try to resolve through imports
-	for _, importInfo := range imports {
+	for _, alias := range sortedKeys(imports) {
+		importInfo := imports[alias]
 		qualifiedType := importInfo.Path + "." + fieldType
 		if _, exists := allTypeInfo[qualifiedType]; exists {
 			return qualifiedType
@@ -500,100 +520,125 @@ func matchesResolvedType(typeInfo TypeInfo, resolvedType string) bool {
 
 // FindInterfaceImplementations scans the project to find struct implementations of interfaces
 func FindInterfaceImplementations(projectPath string) (map[string][]InterfaceImplementation, error) {
-	implementations := make(map[string][]InterfaceImplementation)
-	interfaceMap := make(map[string]TypeInfo)
-	structMethods := make(map[string]map[string]MethodImplementation)
+	project, err := ParseProject(context.Background(), projectPath, ParseOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return project.interfaceImplementations(), nil
+}
 
-	// First pass: collect all interfaces and struct methods
-	err := filepath.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-			fset := token.NewFileSet()
-			node, parseErr := parser.ParseFile(fset, path, nil, parser.ParseComments)
-			if parseErr != nil {
-				return nil // Skip files with parse errors
-			}
+// fileImplFacts holds the interfaces and struct methods declared by one file
+type fileImplFacts struct {
+	Interfaces    map[string]TypeInfo                        // pkg.Interface -> methods
+	StructMethods map[string]map[string]MethodImplementation // pkg.Struct -> method name -> implementation
+}
 
-			packageName := node.Name.Name
-
-			// Collect interfaces
-			ast.Inspect(node, func(n ast.Node) bool {
-				if typeSpec, ok := n.(*ast.TypeSpec); ok {
-					if interfaceType, isInterface := typeSpec.Type.(*ast.InterfaceType); isInterface {
-						typeInfo := TypeInfo{
-							Name:        typeSpec.Name.Name,
-							Package:     packageName,
-							IsInterface: true,
-							Methods:     []string{},
-						}
+// implementationFactsFromAST collects interface declarations and methods with receivers from a parsed file
+func implementationFactsFromAST(fset *token.FileSet, node *ast.File, relPath string) fileImplFacts {
+	facts := fileImplFacts{
+		Interfaces:    make(map[string]TypeInfo),
+		StructMethods: make(map[string]map[string]MethodImplementation),
+	}
+	packageName := node.Name.Name
 
-						if interfaceType.Methods != nil {
-							for _, method := range interfaceType.Methods.List {
-								if len(method.Names) > 0 {
-									typeInfo.Methods = append(typeInfo.Methods, method.Names[0].Name)
-								}
-							}
+	// Collect interfaces
+	ast.Inspect(node, func(n ast.Node) bool {
+		if typeSpec, ok := n.(*ast.TypeSpec); ok {
+			if interfaceType, isInterface := typeSpec.Type.(*ast.InterfaceType); isInterface {
+				typeInfo := TypeInfo{
+					Name:        typeSpec.Name.Name,
+					Package:     packageName,
+					IsInterface: true,
+					Methods:     []string{},
+				}
+
+				if interfaceType.Methods != nil {
+					for _, method := range interfaceType.Methods.List {
+						if len(method.Names) > 0 {
+							typeInfo.Methods = append(typeInfo.Methods, method.Names[0].Name)
 						}
-						interfaceMap[packageName+"."+typeSpec.Name.Name] = typeInfo
 					}
 				}
-				return true
-			})
-
-			// Collect struct methods
-			ast.Inspect(node, func(n ast.Node) bool {
-				if funcDecl, ok := n.(*ast.FuncDecl); ok && funcDecl.Recv != nil {
-					if len(funcDecl.Recv.List) > 0 {
-						recvType := ""
-						switch t := funcDecl.Recv.List[0].Type.(type) {
-						case *ast.Ident:
-							recvType = t.Name
-						case *ast.StarExpr:
-							if ident, ok := t.X.(*ast.Ident); ok {
-								recvType = ident.Name
-							}
-						}
+				facts.Interfaces[packageName+"."+typeSpec.Name.Name] = typeInfo
+			}
+		}
+		return true
+	})
 
-						if recvType != "" {
-							structKey := packageName + "." + recvType
-							if structMethods[structKey] == nil {
-								structMethods[structKey] = make(map[string]MethodImplementation)
-							}
+	// Collect struct methods
+	ast.Inspect(node, func(n ast.Node) bool {
+		if funcDecl, ok := n.(*ast.FuncDecl); ok && funcDecl.Recv != nil {
+			if len(funcDecl.Recv.List) > 0 {
+				recvType := ""
+				switch t := funcDecl.Recv.List[0].Type.(type) {
+				case *ast.Ident:
+					recvType = t.Name
+				case *ast.StarExpr:
+					if ident, ok := t.X.(*ast.Ident); ok {
+						recvType = ident.Name
+					}
+				}
 
-							// Get method body calls
-							calls := []string{}
-							if funcDecl.Body != nil {
-								for _, stmt := range funcDecl.Body.List {
-									calls = append(calls, extractCallsFromStatement(stmt)...)
-								}
-							}
+				if recvType != "" {
+					structKey := packageName + "." + recvType
+					if facts.StructMethods[structKey] == nil {
+						facts.StructMethods[structKey] = make(map[string]MethodImplementation)
+					}
 
-							structMethods[structKey][funcDecl.Name.Name] = MethodImplementation{
-								Name:       funcDecl.Name.Name,
-								StructName: recvType,
-								FilePath:   path,
-								StartLine:  fset.Position(funcDecl.Pos()).Line,
-								EndLine:    fset.Position(funcDecl.End()).Line,
-								Calls:      calls,
-							}
+					// Get method body calls
+					calls := []string{}
+					if funcDecl.Body != nil {
+						for _, stmt := range funcDecl.Body.List {
+							calls = append(calls, extractCallsFromStatement(stmt)...)
 						}
 					}
+
+					facts.StructMethods[structKey][funcDecl.Name.Name] = MethodImplementation{
+						Name:       funcDecl.Name.Name,
+						StructName: recvType,
+						FilePath:   relPath,
+						StartLine:  fset.Position(funcDecl.Pos()).Line,
+						EndLine:    fset.Position(funcDecl.End()).Line,
+						Calls:      calls,
+					}
 				}
-				return true
-			})
+			}
 		}
-		return nil
+		return true
 	})
+	return facts
+}
 
-	if err != nil {
-		return nil, err
+// interfaceImplementations matches the project's struct methods against its interfaces
+func (p *Project) interfaceImplementations() map[string][]InterfaceImplementation {
+	implementations := make(map[string][]InterfaceImplementation)
+	interfaceMap := make(map[string]TypeInfo)
+	structMethods := make(map[string]map[string]MethodImplementation)
+
+	// First pass: collect all interfaces and struct methods (files with parse errors have no facts)
+	for _, file := range p.Files {
+		if file.impl == nil {
+			continue
+		}
+		for name, typeInfo := range file.impl.Interfaces {
+			interfaceMap[name] = typeInfo
+		}
+		for structKey, methods := range file.impl.StructMethods {
+			if structMethods[structKey] == nil {
+				structMethods[structKey] = make(map[string]MethodImplementation)
+			}
+			for name, method := range methods {
+				structMethods[structKey][name] = method
+			}
+		}
 	}
 
-	// Second pass: match struct methods to interface methods
-	for interfaceName, interfaceInfo := range interfaceMap {
-		for structName, methods := range structMethods {
+	// Second pass: match struct methods to interface methods.
+	// Structs are visited in name order so each interface's implementation list is stable.
+	for _, interfaceName := range sortedKeys(interfaceMap) {
+		interfaceInfo := interfaceMap[interfaceName]
+		for _, structName := range sortedKeys(structMethods) {
+			methods := structMethods[structName]
 			// Check if this struct implements the interface
 			if implementsInterface(methods, interfaceInfo.Methods) {
 				impl := InterfaceImplementation{
@@ -615,7 +660,7 @@ func FindInterfaceImplementations(projectPath string) (map[string][]InterfaceImp
 		}
 	}
 
-	return implementations, nil
+	return implementations
 }
 
 // implementsInterface checks if a struct's methods satisfy an interface
@@ -675,8 +720,8 @@ func GetImplementationCalls(interfaceCall string, implementations map[string][]I
 	methodName := parts[len(parts)-1] // "Fill"
 
 	// Find matching interface implementations
-	for _, impls := range implementations {
-		for _, impl := range impls {
+	for _, interfaceName := range sortedKeys(implementations) {
+		for _, impl := range implementations[interfaceName] {
 			if methodImpl, exists := impl.Methods[methodName]; exists {
 				// Create a FunctionInfo for the implementation method
 				implFunc := FunctionInfo{
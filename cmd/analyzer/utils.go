@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -23,6 +24,20 @@ func GetModule(absPath string) (string, error) {
 	return "", fmt.Errorf("module not found in go.mod")
 }
 
+// GetGoVersion returns the go directive from go.mod (e.g. "1.23.0")
+func GetGoVersion(absPath string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(absPath, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "go ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "go ")), nil
+		}
+	}
+	return "", fmt.Errorf("go directive not found in go.mod")
+}
+
 func FindFunctionBody(lines []string, funcLine int) (int, int) {
 	braceCount := 0
 	start := -1
@@ -510,3 +525,37 @@ func contains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// appendUnique appends item unless it is already present
+func appendUnique(slice []string, item string) []string {
+	if contains(slice, item) {
+		return slice
+	}
+	return append(slice, item)
+}
+
+// sortedKeys returns the keys of m in lexicographic order so map-driven lookups resolve the same way on every run
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sameStringSet reports whether a and b hold the same strings, ignoring order and duplicates
+func sameStringSet(a, b []string) bool {
+	setA := make(map[string]bool, len(a))
+	for _, s := range a {
+		setA[s] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, s := range b {
+		if !setA[s] {
+			return false
+		}
+		setB[s] = true
+	}
+	return len(setA) == len(setB)
+}
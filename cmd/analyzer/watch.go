@@ -0,0 +1,164 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Defaults for WatchOptions
+const (
+	DefaultWatchDebounce     = 300 * time.Millisecond
+	DefaultWatchPollInterval = 2 * time.Second
+)
+
+// WatchOptions configures WatchTree
+type WatchOptions struct {
+	Debounce     time.Duration // quiet period after the last change before a batch is reported; <= 0 uses DefaultWatchDebounce
+	PollInterval time.Duration // scan interval of the polling fallback; <= 0 uses DefaultWatchPollInterval
+	Poll         bool          // poll even where native notifications are available
+}
+
+// WatchTree watches the analysis inputs under root (non-test .go files, go.mod and go.sum, as in
+// SourceFingerprint) and calls onChange with the sorted paths, relative to root, of each debounced batch
+// of changes. A path of "." means changes may have been missed and the whole tree should be rescanned.
+// It uses inotify on Linux and falls back to polling elsewhere or when inotify is unavailable, and runs
+// until ctx is cancelled. Directories named .git or node_modules are not watched.
+func WatchTree(ctx context.Context, root string, opts WatchOptions, onChange func(changed []string)) error {
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultWatchDebounce
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultWatchPollInterval
+	}
+
+	events := make(chan string, 256)
+	var source func() error
+	if !opts.Poll {
+		if notify, err := newNotifyWatcher(ctx, root, events); err == nil {
+			source = notify
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: native file watching unavailable (%v), polling every %v\n", err, opts.PollInterval)
+		}
+	}
+	if source == nil {
+		state, err := pollTree(root)
+		if err != nil {
+			return err
+		}
+		source = func() error { return pollLoop(ctx, root, opts.PollInterval, state, events) }
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- source() }()
+
+	// Collect changes until nothing happened for opts.Debounce, then report them as one batch
+	pending := make(map[string]bool)
+	timer := time.NewTimer(opts.Debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case err := <-errs:
+			timer.Stop()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		case rel := <-events:
+			pending[rel] = true
+			timer.Reset(opts.Debounce)
+		case <-timer.C:
+			if len(pending) == 0 {
+				continue
+			}
+			changed := sortedKeys(pending)
+			pending = make(map[string]bool)
+			onChange(changed)
+		}
+	}
+}
+
+// skipWatchDir reports directories that never contain analysis inputs worth watching
+func skipWatchDir(name string) bool {
+	return name == ".git" || name == "node_modules"
+}
+
+// fileStamp is what the polling watcher compares between scans
+type fileStamp struct {
+	size    int64
+	modTime int64 // nanoseconds
+}
+
+// pollTree stamps every analysis input under root, keyed by slash-separated relative path
+func pollTree(root string) (map[string]fileStamp, error) {
+	state := make(map[string]fileStamp)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files may disappear between listing and stat; the next scan sees the final state
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if path != root && skipWatchDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isFingerprintInput(info.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		state[filepath.ToSlash(rel)] = fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano()}
+		return nil
+	})
+	return state, err
+}
+
+// pollLoop rescans root every interval and sends the paths whose stamps were added, removed or changed
+func pollLoop(ctx context.Context, root string, interval time.Duration, state map[string]fileStamp, events chan<- string) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		next, err := pollTree(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to scan %s for changes: %v\n", root, err)
+			continue
+		}
+		var changed []string
+		for rel, stamp := range next {
+			if old, ok := state[rel]; !ok || old != stamp {
+				changed = append(changed, rel)
+			}
+		}
+		for rel := range state {
+			if _, ok := next[rel]; !ok {
+				changed = append(changed, rel)
+			}
+		}
+		sort.Strings(changed)
+		for _, rel := range changed {
+			select {
+			case events <- rel:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		state = next
+	}
+}
@@ -0,0 +1,188 @@
+//go:build linux
+
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// inotifyWatcher tracks one watch descriptor per directory under root
+type inotifyWatcher struct {
+	ctx    context.Context
+	root   string
+	file   *os.File
+	fd     int
+	mu     sync.Mutex
+	dirs   map[int32]string // watch descriptor -> directory, relative to root
+	events chan<- string
+}
+
+// newNotifyWatcher adds inotify watches for every directory under root. The returned function reads
+// events until ctx is cancelled.
+func newNotifyWatcher(ctx context.Context, root string, events chan<- string) (func() error, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// A non-blocking descriptor is served by the runtime poller, so Close interrupts a pending Read
+	w := &inotifyWatcher{
+		ctx:    ctx,
+		root:   root,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		fd:     fd,
+		dirs:   make(map[int32]string),
+		events: events,
+	}
+	if err := w.addTree(root, nil); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	return func() error {
+		go func() {
+			<-ctx.Done()
+			w.file.Close()
+		}()
+		err := w.readLoop()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}, nil
+}
+
+// addTree watches dir and its subdirectories; found receives the analysis inputs already inside, which
+// matters for directories created or moved in after watching started
+func (w *inotifyWatcher) addTree(dir string, found func(rel string)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			if found != nil && isFingerprintInput(d.Name()) {
+				found(filepath.ToSlash(rel))
+			}
+			return nil
+		}
+		if path != w.root && skipWatchDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask|syscall.IN_ONLYDIR)
+		if err != nil {
+			if errors.Is(err, syscall.ENOENT) {
+				return filepath.SkipDir
+			}
+			// ENOSPC means fs.inotify.max_user_watches is exhausted
+			return &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+		}
+		w.mu.Lock()
+		w.dirs[int32(wd)] = rel
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) readLoop() error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return err
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			offset = nameEnd
+			if nameEnd > n {
+				break
+			}
+			name := string(buf[nameStart:nameEnd])
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			w.handle(event.Wd, event.Mask, name)
+		}
+	}
+}
+
+func (w *inotifyWatcher) handle(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		// The kernel dropped events; only a full rescan is safe
+		w.send(".")
+		return
+	}
+	w.mu.Lock()
+	dir, ok := w.dirs[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+	}
+	w.mu.Unlock()
+	if !ok || name == "" {
+		return
+	}
+	rel := filepath.ToSlash(filepath.Join(dir, name))
+
+	if mask&syscall.IN_ISDIR != 0 {
+		switch {
+		case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+			if skipWatchDir(name) {
+				return
+			}
+			err := w.addTree(filepath.Join(w.root, dir, name), w.send)
+			if err != nil {
+				// The new directory cannot be watched; report a rescan so its contents are still picked up once
+				w.send(".")
+			}
+		case mask&syscall.IN_DELETE != 0:
+			// Every input below the directory is gone; its watches are released with IN_IGNORED
+			w.send(rel)
+		case mask&syscall.IN_MOVED_FROM != 0:
+			// Watches follow the moved directory; drop them so paths outside root are not reported.
+			// A move within root is watched again by the matching IN_MOVED_TO.
+			w.removeTree(rel)
+			w.send(rel)
+		}
+		return
+	}
+	if isFingerprintInput(name) {
+		w.send(rel)
+	}
+}
+
+// removeTree stops watching the directory rel and everything below it
+func (w *inotifyWatcher) removeTree(rel string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for wd, dir := range w.dirs {
+		if dir == rel || strings.HasPrefix(dir, rel+string(filepath.Separator)) {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// send reports a changed path unless watching has stopped
+func (w *inotifyWatcher) send(rel string) {
+	select {
+	case w.events <- rel:
+	case <-w.ctx.Done():
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package analyzer
+
+import (
+	"context"
+	"errors"
+)
+
+// newNotifyWatcher is only implemented with inotify; other platforms use the polling watcher
+func newNotifyWatcher(ctx context.Context, root string, events chan<- string) (func() error, error) {
+	return nil, errors.New("native file watching is not supported on this platform")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+)
+
+// runDiff compares the call structure of two analyses and prints a summary, or the diff as JSON.
+// Usage: diff [-path dir] [-json] [analysis flags] from to
+// Each side is a relation file (functionmap.json, .bin or a bare relation array), a git ref of -path,
+// or "." for the working tree of -path.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	path := fs.String("path", ".", "repository whose git refs and working tree the sides name")
+	asJSON := fs.Bool("json", false, "print the diff as JSON (schema diff) instead of a summary")
+	var settings analysisSettings
+	settings.register(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fmt.Println("usage: diff [-path dir] [-json] from to  (each side: relation file, git ref or . for the working tree)")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	absPath, err := filepath.Abs(*path)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// Analysis progress is printed to stdout; with -json it goes to stderr so stdout holds only the diff
+	if *asJSON {
+		settings.progress = os.Stderr
+	}
+	var sides [2]analyzer.Snapshot
+	for i, operand := range fs.Args() {
+		if sides[i], err = loadDiffSide(ctx, absPath, operand, settings); err != nil {
+			fmt.Printf("%s: %v\n", operand, err)
+			return 1
+		}
+	}
+
+	diff := analyzer.DiffSnapshots(sides[0], sides[1])
+	for i, side := range []*analyzer.DiffSide{&diff.From, &diff.To} {
+		side.Label = fs.Arg(i)
+		side.ContentHash = sides[i].Header.Stats.ContentHash
+		if vcs := sides[i].Header.VCS; vcs != nil {
+			side.Commit = vcs.Commit
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diff); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	}
+	if err := diff.WriteSummary(os.Stdout); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// loadDiffSide loads one side of a diff: an existing file, the working tree (".") or a git ref
+func loadDiffSide(ctx context.Context, absPath, operand string, settings analysisSettings) (analyzer.Snapshot, error) {
+	if operand != "." {
+		if stat, err := os.Stat(operand); err == nil && !stat.IsDir() {
+			return analyzer.LoadSnapshotFile(operand)
+		}
+	}
+	ref := operand
+	if ref == "." {
+		ref = ""
+	}
+	return analyzeRevision(ctx, absPath, ref, settings)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+)
+
+// runImpact reports the functions a change touches and every caller and root reaching them.
+// Usage: impact [-path dir] -base ref [-head ref] [-json] [analysis flags]
+//
+//	impact [-path dir] -patch file [-base ref] [-head ref] [-json] [analysis flags]
+//
+// The change is the git diff from -base to -head (the working tree by default), or a patch file ("-" for
+// stdin) already applied to -head or the working tree; -base then only names the pre-patch revision, so
+// removed functions can be reported.
+func runImpact(args []string) int {
+	fs := flag.NewFlagSet("impact", flag.ExitOnError)
+	path := fs.String("path", ".", "repository the change applies to")
+	base := fs.String("base", "", "commit, branch or tag the change starts from")
+	head := fs.String("head", "", "commit, branch or tag the change ends at (default: the working tree)")
+	patchFile := fs.String("patch", "", "unified diff to analyze instead of base..head (- reads stdin)")
+	asJSON := fs.Bool("json", false, "print the report as JSON (schema impact) instead of a summary")
+	var settings analysisSettings
+	settings.register(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 0 || (*base == "" && *patchFile == "") {
+		fmt.Println("usage: impact [-path dir] (-base ref [-head ref] | -patch file [-base ref] [-head ref]) [-json]")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	absPath, err := filepath.Abs(*path)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// Analysis progress is printed to stdout; with -json it goes to stderr so stdout holds only the report
+	if *asJSON {
+		settings.progress = os.Stderr
+	}
+	report, err := impactReport(ctx, absPath, *base, *head, *patchFile, settings)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	}
+	if err := report.WriteSummary(os.Stdout); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// impactReport reads the change, maps it to functions and walks the callers in the analysis of head
+func impactReport(ctx context.Context, absPath, base, head, patchFile string, settings analysisSettings) (analyzer.ImpactReport, error) {
+	diff, err := readChange(ctx, absPath, base, head, patchFile)
+	if err != nil {
+		return analyzer.ImpactReport{}, err
+	}
+	changed, err := analyzer.ChangedFunctions(diff.files, diff.readNew, diff.readOld)
+	if err != nil {
+		return analyzer.ImpactReport{}, err
+	}
+	snapshot, err := analyzeRevision(ctx, absPath, head, settings)
+	if err != nil {
+		return analyzer.ImpactReport{}, err
+	}
+	report, err := impactOf(ctx, absPath, diff.headCommit, snapshot, changed)
+	if err != nil {
+		return analyzer.ImpactReport{}, err
+	}
+	report.Base, report.Head = base, head
+	return report, nil
+}
+
+// impactOf walks the callers of changed in snapshot, the analysis of the repository at absPath as of
+// commit ("" for the files on disk). Roots are classified as the server does by default, with handlers
+// found in that source.
+func impactOf(ctx context.Context, absPath, commit string, snapshot analyzer.Snapshot, changed []analyzer.ChangedFunction) (analyzer.ImpactReport, error) {
+	graph, roots, err := defaultRoots(ctx, absPath, commit, snapshot.Relations)
+	if err != nil {
+		return analyzer.ImpactReport{}, err
+	}
+	return analyzer.Impact(graph, changed, roots.Roots), nil
+}
+
+// change is a diff with readers for the files before (nil when unknown) and after it
+type change struct {
+	files            []analyzer.FileChange
+	readNew, readOld analyzer.SourceReader
+	headCommit       string // the commit readNew reads; "" for the working tree
+}
+
+// readChange reads the git diff from base to head (the working tree when head is ""), or patchFile ("-"
+// for stdin) as applied to head; base is optional with a patch
+func readChange(ctx context.Context, absPath, base, head, patchFile string) (change, error) {
+	var baseCommit, headCommit string
+	var err error
+	if base != "" {
+		if baseCommit, err = analyzer.ResolveGitRef(ctx, absPath, base); err != nil {
+			return change{}, err
+		}
+	}
+	c := change{readNew: analyzer.DirReader(absPath)}
+	if head != "" {
+		if headCommit, err = analyzer.ResolveGitRef(ctx, absPath, head); err != nil {
+			return change{}, err
+		}
+		c.readNew = analyzer.GitFileReader(ctx, absPath, headCommit)
+		c.headCommit = headCommit
+	}
+	if baseCommit != "" {
+		c.readOld = analyzer.GitFileReader(ctx, absPath, baseCommit)
+	}
+
+	var patch []byte
+	switch patchFile {
+	case "":
+		patch, err = analyzer.GitDiff(ctx, absPath, baseCommit, headCommit)
+	case "-":
+		patch, err = io.ReadAll(os.Stdin)
+	default:
+		patch, err = os.ReadFile(patchFile)
+	}
+	if err != nil {
+		return change{}, err
+	}
+	if c.files, err = analyzer.ParsePatch(bytes.NewReader(patch)); err != nil {
+		return change{}, err
+	}
+	return c, nil
+}
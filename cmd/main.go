@@ -1,10 +1,12 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -13,96 +15,282 @@ import (
 )
 
 func main() {
+	// Subcommands; without one the CLI analyzes a repository as before
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "schema":
+			os.Exit(runSchema(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "impact":
+			os.Exit(runImpact(os.Args[2:]))
+		case "tests":
+			os.Exit(runTests(os.Args[2:]))
+		case "paths":
+			os.Exit(runPaths(os.Args[2:]))
+		}
+	}
+	runAnalyze()
+}
+
+// analysisSettings are the analysis flags shared by the analyze, diff, impact, tests and paths commands
+type analysisSettings struct {
+	includeExternal bool
+	skipFolders     string
+	cacheDir        string
+	noCache         bool
+	workers         int
+
+	progress io.Writer // where analysis progress and warnings are printed (default: stdout)
+}
+
+func (s *analysisSettings) register(fs *flag.FlagSet) {
+	fs.BoolVar(&s.includeExternal, "include-external", false, "include external library calls in output (skip removed_calls.json generation)")
+	fs.StringVar(&s.skipFolders, "skip-folders", "", "comma-separated list of folder patterns to skip when scanning external dependencies (e.g., 'golang.org,google.golang.org')")
+	fs.StringVar(&s.cacheDir, "cache-dir", "", "directory of the per-file analysis cache (default: the user cache directory)")
+	fs.BoolVar(&s.noCache, "no-cache", false, "reparse every file instead of reusing cached results for unchanged files")
+	fs.IntVar(&s.workers, "workers", 0, "number of files parsed in parallel (default: GOMAXPROCS)")
+}
+
+// flags returns the snapshot flags; skip patterns only matter, and are only recorded, with include-external
+func (s *analysisSettings) flags() analyzer.SnapshotFlags {
+	var skipPatterns []string
+	if s.includeExternal && s.skipFolders != "" {
+		skipPatterns = strings.Split(s.skipFolders, ",")
+		for i, pattern := range skipPatterns {
+			skipPatterns[i] = strings.TrimSpace(pattern)
+		}
+	}
+	return analyzer.SnapshotFlags{IncludeExternal: s.includeExternal, SkipPatterns: skipPatterns}
+}
+
+// runAnalyze scans a repository and writes functionmap.json
+func runAnalyze() {
 	var path string
-	var includeExternal bool
-	var skipFolders string
+	var writeBinary bool
+	var ref string
+	var recordRun bool
+	var settings analysisSettings
 	flag.StringVar(&path, "path", ".", "path to repository")
-	flag.BoolVar(&includeExternal, "include-external", false, "include external library calls in output (skip removed_calls.json generation)")
-	flag.StringVar(&skipFolders, "skip-folders", "", "comma-separated list of folder patterns to skip when scanning external dependencies (e.g., 'golang.org,google.golang.org')")
+	settings.register(flag.CommandLine)
+	flag.BoolVar(&writeBinary, "binary", false, "also write "+analyzer.BinarySnapshotFile+", a compact snapshot the server loads instantly when it matches the source tree")
+	flag.StringVar(&ref, "ref", "", "analyze this commit, branch or tag of the git repository instead of the working tree")
+	flag.BoolVar(&recordRun, "record-run", false, "record the toolchain, work tree state and time of this run in header.run (the output then differs between runs)")
 	flag.Parse()
 
+	// Interrupting the CLI stops the analysis between files
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-
-	module, err := analyzer.GetModule(absPath)
+	snapshot, err := analyzeRevision(ctx, absPath, ref, settings)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-
-	var functions []analyzer.FunctionInfo
-	err = filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
+	if recordRun {
+		snapshot.Header.Run = analyzer.NewSnapshotRun(absPath)
+		snapshot.Header.Run.Dirty = snapshot.Header.Run.Dirty && ref == "" // a ref is read from the object database
+	}
+	if writeBinary {
+		// The server reuses the roots when its classifier matches, instead of reading every handler file
+		commit := ""
+		if ref != "" {
+			commit = snapshot.Header.VCS.Commit
+		}
+		_, roots, err := defaultRoots(ctx, absPath, commit, snapshot.Relations)
 		if err != nil {
-			return err
+			fmt.Println(err)
+			return
+		}
+		snapshot.Roots = &roots
+	}
+	writeOutputs(snapshot, writeBinary)
+}
+
+// analyzeRevision analyzes the repository at absPath: its working tree, or the git commit, branch or tag
+// ref. A ref is analyzed from a private copy of that revision, and each commit only once per set of
+// flags thanks to the commit cache.
+func analyzeRevision(ctx context.Context, absPath, ref string, settings analysisSettings) (analyzer.Snapshot, error) {
+	return analyzeCheckout(ctx, absPath, ref, "", settings)
+}
+
+// analyzeCheckout is analyzeRevision for a caller that already checked ref out to tree; with tree "" a
+// ref is checked out only when the commit cache misses
+func analyzeCheckout(ctx context.Context, absPath, ref, tree string, settings analysisSettings) (analyzer.Snapshot, error) {
+	includeExternal, flags := settings.includeExternal, settings.flags()
+	skipPatterns := flags.SkipPatterns
+	out := settings.progress
+	if out == nil {
+		out = os.Stdout
+	}
+
+	sourcePath := absPath
+	var commit string
+	var refCache *analyzer.RefCache
+	if ref != "" {
+		var err error
+		if commit, err = analyzer.ResolveGitRef(ctx, absPath, ref); err != nil {
+			return analyzer.Snapshot{}, err
+		}
+		fmt.Fprintf(out, "Analyzing %s at commit %s\n", ref, commit)
+		if refCache, err = analyzer.OpenRefCache(ctx, settings.cacheDir, absPath, flags); err != nil {
+			fmt.Fprintf(out, "Warning: commit cache disabled: %v\n", err)
+			refCache = nil
+		}
+		if refCache != nil && !settings.noCache {
+			cached, err := refCache.Load(commit)
+			if err == nil {
+				fmt.Fprintln(out, "Commit already analyzed, using the cached result")
+				return cached, nil
+			}
+			if !analyzer.IsNotCached(err) {
+				fmt.Fprintf(out, "Warning: ignoring unreadable cached analysis: %v\n", err)
+			}
 		}
-		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-			funcs, err := analyzer.FindFunctions(path, absPath, module)
+		sourcePath = tree
+		if tree == "" {
+			tmp, err := os.MkdirTemp("", "gomindmapper-ref-")
 			if err != nil {
-				return err
+				return analyzer.Snapshot{}, err
 			}
-			functions = append(functions, funcs...)
+			defer os.RemoveAll(tmp)
+			if err := analyzer.CheckoutGitRef(ctx, absPath, commit, tmp); err != nil {
+				return analyzer.Snapshot{}, err
+			}
+			sourcePath = tmp
 		}
-		return nil
+	}
+
+	module, err := analyzer.GetModule(sourcePath)
+	if err != nil {
+		return analyzer.Snapshot{}, err
+	}
+
+	// Per-file results of unchanged files are reused from earlier runs, whichever revision they came from
+	var cache *analyzer.FileCache
+	if !settings.noCache {
+		if cache, err = analyzer.OpenFileCache(settings.cacheDir, absPath); err != nil {
+			fmt.Fprintf(out, "Warning: analysis cache disabled: %v\n", err)
+			cache = nil
+		}
+	}
+
+	// Every project file is read and parsed once; later stages reuse the parsed project
+	project, err := analyzer.ParseProject(ctx, sourcePath, analyzer.ParseOptions{
+		Module:   module,
+		Workers:  settings.workers,
+		AllCalls: includeExternal,
+		Cache:    cache,
 	})
 	if err != nil {
-		fmt.Println(err)
-		return
+		return analyzer.Snapshot{}, err
 	}
+	functions := project.Functions()
 
 	// If include-external is true, scan external modules
 	if includeExternal {
-		fmt.Println("Scanning external modules...")
-
-		// Parse skip patterns
-		var skipPatterns []string
-		if skipFolders != "" {
-			skipPatterns = strings.Split(skipFolders, ",")
-			for i, pattern := range skipPatterns {
-				skipPatterns[i] = strings.TrimSpace(pattern)
-			}
-			fmt.Printf("Skipping external dependency folders matching: %v\n", skipPatterns)
+		fmt.Fprintln(out, "Scanning external modules...")
+		if len(skipPatterns) > 0 {
+			fmt.Fprintf(out, "Skipping external dependency folders matching: %v\n", skipPatterns)
 		}
 
-		externalFunctions, err := analyzer.ScanExternalModules(absPath, functions, skipPatterns)
+		externalFunctions, err := analyzer.ScanExternalModules(ctx, project, skipPatterns)
+		if ctx.Err() != nil {
+			return analyzer.Snapshot{}, ctx.Err()
+		}
 		if err != nil {
-			fmt.Printf("Warning: failed to scan external modules: %v\n", err)
+			fmt.Fprintf(out, "Warning: failed to scan external modules: %v\n", err)
 		} else {
 			functions = append(functions, externalFunctions...)
-			fmt.Printf("Successfully scanned external modules and found %d external functions\n", len(externalFunctions))
+			fmt.Fprintf(out, "Successfully scanned external modules and found %d external functions\n", len(externalFunctions))
 		}
 	}
 
 	// Enhance project functions with type resolution before external scanning
 	if !includeExternal {
-		functions = analyzer.EnhanceProjectFunctionsWithTypeInfo(functions, ".")
+		if functions, err = analyzer.EnhanceProjectFunctionsWithTypeInfo(ctx, functions, project); err != nil {
+			return analyzer.Snapshot{}, err
+		}
 	}
 
-	sort.Slice(functions, func(i, j int) bool {
-		return functions[i].Name < functions[j].Name
+	// Full ordering (name, file, line) so BuildRelations sees duplicates in the same order every run
+	sort.SliceStable(functions, func(i, j int) bool {
+		if functions[i].Name != functions[j].Name {
+			return functions[i].Name < functions[j].Name
+		}
+		if functions[i].FilePath != functions[j].FilePath {
+			return functions[i].FilePath < functions[j].FilePath
+		}
+		return functions[i].Line < functions[j].Line
 	})
 
-	// Build relations using the same logic as the server, then sort and write pretty JSON
-	relations := analyzer.BuildRelations(functions, includeExternal)
-	// Sort relations by name then filePath for consistency with server
-	sort.Slice(relations, func(i, j int) bool {
-		if relations[i].Name == relations[j].Name {
-			return relations[i].FilePath < relations[j].FilePath
+	// Build relations using the same logic as the server, then sort and write pretty JSON. Only the
+	// relations of functions changed since the previous run, and of their callers, are rebuilt.
+	relations, err := cache.Relations(ctx, settings.workers, functions, includeExternal)
+	if err != nil {
+		return analyzer.Snapshot{}, err
+	}
+	if cache != nil {
+		hits, misses := cache.Stats()
+		reused, rebuilt := cache.RelationStats()
+		fmt.Fprintf(out, "Analysis cache: %d results reused, %d recomputed; %d relations reused, %d rebuilt\n", hits, misses, reused, rebuilt)
+		if err := cache.Save(); err != nil {
+			fmt.Fprintf(out, "Warning: failed to save analysis cache: %v\n", err)
 		}
-		return relations[i].Name < relations[j].Name
-	})
+	}
+	// Wrap in a versioned document; relations are sorted by name, filePath and line for consistency with server
+	snapshot, err := analyzer.NewSnapshot(sourcePath, relations, len(functions), flags)
+	if err != nil {
+		return analyzer.Snapshot{}, fmt.Errorf("building snapshot: %w", err)
+	}
+	if commit != "" {
+		snapshot.Header.VCS = analyzer.GitRefVCS(commit)
+		if refCache != nil {
+			if err := refCache.Save(commit, snapshot); err != nil {
+				fmt.Fprintf(out, "Warning: failed to cache the analysis of %s: %v\n", commit, err)
+			}
+		}
+	}
+	return snapshot, nil
+}
 
-	data, err := json.MarshalIndent(relations, "", "  ")
+// defaultRoots classifies the roots of relations with the default RootClassifier, reading handler
+// declarations from the working tree at absPath or, when commit is set, from that commit
+func defaultRoots(ctx context.Context, absPath, commit string, relations []analyzer.OutRelation) (*analyzer.Graph, analyzer.SnapshotRoots, error) {
+	entry, err := analyzer.NewRootClassifier(analyzer.EntryPoints{})
 	if err != nil {
-		fmt.Println("Error marshaling relations:", err)
-		return
+		return nil, analyzer.SnapshotRoots{}, err
+	}
+	graph := analyzer.NewGraph(relations, entry.IsInternal)
+	read := analyzer.DirReader(absPath)
+	if commit != "" {
+		if read, err = analyzer.GitBatchReader(ctx, absPath, commit, entry.SourceFiles(graph)); err != nil {
+			return nil, analyzer.SnapshotRoots{}, err
+		}
 	}
-	if err := os.WriteFile("functionmap.json", data, 0644); err != nil {
+	return graph, analyzer.SnapshotRoots{Classifier: entry.Digest(), Roots: entry.Roots(graph, read)}, nil
+}
+
+// writeOutputs writes functionmap.json and, with -binary, functionmap.bin to the working directory
+func writeOutputs(snapshot analyzer.Snapshot, writeBinary bool) {
+	if err := analyzer.WriteSnapshot("functionmap.json", snapshot); err != nil {
 		fmt.Println("Error writing functionmap.json:", err)
 		return
 	}
+	if writeBinary {
+		if err := analyzer.SaveBinarySnapshot(analyzer.BinarySnapshotFile, snapshot); err != nil {
+			fmt.Println("Error writing "+analyzer.BinarySnapshotFile+":", err)
+			return
+		}
+	}
+	fmt.Printf("Wrote %d relations (content hash %s)\n", len(snapshot.Relations), snapshot.Header.Stats.ContentHash)
 }
 
 // legacy buildFunctionMap removed: functionality now in analyzer.BuildRelations
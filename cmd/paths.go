@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+)
+
+// runPaths prints the shortest call path, or the k shortest simple paths, from one function to another.
+// Usage: paths [-path dir] [-ref ref] [-k n] [-json] [analysis flags] from to
+// Functions are named as in the relations (package.Function), or name@filePath to pick one definition.
+func runPaths(args []string) int {
+	fs := flag.NewFlagSet("paths", flag.ExitOnError)
+	path := fs.String("path", ".", "repository to analyze")
+	ref := fs.String("ref", "", "analyze this commit, branch or tag instead of the working tree")
+	k := fs.Int("k", 1, fmt.Sprintf("number of shortest simple paths to list (at most %d)", analyzer.MaxPaths))
+	asJSON := fs.Bool("json", false, "print the paths as JSON (schema paths) instead of a summary")
+	var settings analysisSettings
+	settings.register(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fmt.Println("usage: paths [-path dir] [-ref ref] [-k n] [-json] from to")
+		return 2
+	}
+	if *k < 1 || *k > analyzer.MaxPaths {
+		fmt.Printf("-k must be between 1 and %d\n", analyzer.MaxPaths)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	absPath, err := filepath.Abs(*path)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// Analysis progress goes to stderr so stdout holds only the paths
+	settings.progress = os.Stderr
+	snapshot, err := analyzeRevision(ctx, absPath, *ref, settings)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	graph := analyzer.NewGraph(snapshot.Relations, nil)
+	from, fromID, ok := graph.Function(fs.Arg(0))
+	if !ok {
+		fmt.Printf("unknown function %s\n", fs.Arg(0))
+		return 1
+	}
+	to, _, ok := graph.Function(fs.Arg(1))
+	if !ok {
+		fmt.Printf("unknown function %s\n", fs.Arg(1))
+		return 1
+	}
+	result := analyzer.PathsResponse{From: from, To: to, K: *k, Paths: []analyzer.CallPath{}}
+	if fromID >= 0 {
+		result.Paths = graph.ShortestPaths(fromID, to, *k)
+	}
+	read := analyzer.DirReader(absPath)
+	if *ref != "" {
+		commit, err := analyzer.ResolveGitRef(ctx, absPath, *ref)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		read = analyzer.GitFileReader(ctx, absPath, commit)
+	}
+	analyzer.AddCallLines(result.Paths, read)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	}
+	if err := result.WriteSummary(os.Stdout); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/chinmay-sawant/gomindmapper/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// handleCallers returns the caller tree of a function: its direct callers, paginated, and every function
+// reaching them up to the roots or depth levels up. It answers "who calls this?" through the graph's
+// reverse index.
+// Query params: id (function name, or name@filePath when the name is declared in several files), depth
+// (levels of callers, default 0 = up to the roots), page (1-based), pageSize, includeInternals
+func handleCallers(c *gin.Context) {
+	data := &repoOf(c).data
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	ref := strings.TrimSpace(c.Query("id"))
+	if ref == "" {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "function 'id' is required: a name or name@filePath"})
+		return
+	}
+	fn, _, ok := data.graph.Function(ref)
+	if !ok {
+		c.JSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown function " + ref})
+		return
+	}
+	depth := utils.ParseInt(c.Query("depth"), 0)
+	if depth < 0 {
+		depth = 0
+	}
+	page := utils.ParseInt(c.Query("page"), 1)
+	pageSize := utils.ParseInt(c.Query("pageSize"), 10)
+	includeInternals := strings.EqualFold(c.Query("includeInternals"), "true")
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 10
+	}
+
+	// Internal callers are paginated and walked like any other, so the tree above them is kept
+	callers := data.graph.CallersOf(fn)
+	totalCallers := len(callers)
+	start := (page - 1) * pageSize
+	if start > totalCallers {
+		start = totalCallers
+	}
+	end := start + pageSize
+	if end > totalCallers {
+		end = totalCallers
+	}
+	selected := callers[start:end]
+	tree := data.graph.CallerClosure(selected, depth, includeInternals)
+
+	var shown, roots []analyzer.NodeID
+	for _, id := range selected {
+		if includeInternals || !data.graph.IsInternal(id) {
+			shown = append(shown, id)
+		}
+	}
+	for _, id := range tree {
+		if len(data.graph.Callers(id)) == 0 && !data.graph.IsInternal(id) {
+			roots = append(roots, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, analyzer.CallersResponse{
+		Function:         fn,
+		Depth:            depth,
+		Page:             page,
+		PageSize:         pageSize,
+		TotalCallers:     totalCallers,
+		Callers:          data.graph.RelationsOf(shown),
+		Data:             data.graph.RelationsOf(tree),
+		Roots:            data.graph.RelationsOf(roots),
+		LoadedAt:         data.loadedAt,
+		ContentHash:      data.hash,
+		Source:           data.source,
+		IncludeInternals: includeInternals,
+	})
+}
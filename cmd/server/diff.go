@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/gin-gonic/gin"
+)
+
+// diffCurrent names the relations the repository currently serves in /api/diff
+const diffCurrent = "current"
+
+// diffRepoPrefix names another hosted repository's current relations in /api/diff, e.g. repo:upload-1
+const diffRepoPrefix = "repo:"
+
+// errDiffSide carries the HTTP status for a side of /api/diff that cannot be loaded
+type errDiffSide struct {
+	status int
+	err    error
+}
+
+func (e *errDiffSide) Error() string { return e.err.Error() }
+
+// handleDiff compares two relation sets of the repository and reports added, removed and moved functions,
+// added and removed calls, and new and removed roots.
+// Query params: from (required), to (default "current"); each is "current", repo:<id> for another hosted
+// repository, or a git commit, branch or tag of this repository. A commit missing from the commit cache is
+// analyzed by an "analyze" job and the request answers 202 with the job; retry once it finished, or pass
+// wait=true to hold the response until the diff is ready.
+// format=text answers with the plain-text summary instead of JSON.
+func handleDiff(c *gin.Context) {
+	r := repoOf(c)
+	from := strings.TrimSpace(c.Query("from"))
+	to := strings.TrimSpace(c.DefaultQuery("to", diffCurrent))
+	if from == "" {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "'from' is required: current, repo:<id> or a git ref"})
+		return
+	}
+
+	var graphs [2]*analyzer.Graph
+	var sides [2]analyzer.DiffSide
+	for {
+		var pending []*reloadJob
+		for i, spec := range []string{from, to} {
+			graph, side, job, err := diffSide(c, r, spec)
+			if err != nil {
+				status := http.StatusInternalServerError
+				var sideErr *errDiffSide
+				if errors.As(err, &sideErr) {
+					status = sideErr.status
+				}
+				c.JSON(status, analyzer.ErrorResponse{Error: spec + ": " + err.Error()})
+				return
+			}
+			if job != nil {
+				pending = append(pending, job)
+			}
+			graphs[i], sides[i] = graph, side
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if c.Query("wait") != "true" {
+			respondWithJob(c, pending[0])
+			return
+		}
+		for _, job := range pending {
+			select {
+			case <-job.done:
+			case <-c.Request.Context().Done():
+				return
+			}
+			if state := job.snapshot(); state.Status != analyzer.JobSucceeded {
+				c.JSON(http.StatusUnprocessableEntity, analyzer.ErrorResponse{Error: state.Commit + ": " + state.Error})
+				return
+			}
+		}
+	}
+
+	diff := analyzer.DiffGraphs(graphs[0], graphs[1])
+	for i, side := range []*analyzer.DiffSide{&diff.From, &diff.To} {
+		side.Label, side.Commit, side.ContentHash = sides[i].Label, sides[i].Commit, sides[i].ContentHash
+	}
+	if c.Query("format") == "text" {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Status(http.StatusOK)
+		diff.WriteSummary(c.Writer)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// diffSide loads one side of /api/diff. Graphs are immutable once installed, so the current ones are
+// used without holding the cache lock. A git ref missing from the commit cache returns the job
+// analyzing it instead of a graph; the request never scans itself.
+func diffSide(c *gin.Context, r *repo, spec string) (*analyzer.Graph, analyzer.DiffSide, *reloadJob, error) {
+	side := analyzer.DiffSide{Label: spec}
+	current := func(target *repo) (*analyzer.Graph, analyzer.DiffSide, *reloadJob, error) {
+		target.data.mu.RLock()
+		graph, relations, hash, commit := target.data.graph, target.data.relations, target.data.hash, target.data.source.Commit
+		target.data.mu.RUnlock()
+		if graph == nil {
+			return nil, side, nil, &errDiffSide{http.StatusServiceUnavailable, errors.New("repository " + target.spec.ID + " has no data loaded yet")}
+		}
+		side.ContentHash, side.Commit = hash, commit
+		if graph.Len() > len(relations) {
+			// Only the served graph holds tests; compare the analyzed relations alone. NewGraph interns
+			// strings in place, so it gets a copy of the relations handlers may be reading.
+			relations = slices.Clone(relations)
+			for i := range relations {
+				relations[i].Called = slices.Clone(relations[i].Called)
+			}
+			graph = analyzer.NewGraph(relations, target.entry.IsInternal)
+		}
+		return graph, side, nil, nil
+	}
+
+	switch {
+	case spec == diffCurrent:
+		return current(r)
+	case strings.HasPrefix(spec, diffRepoPrefix):
+		other := repos.get(strings.TrimPrefix(spec, diffRepoPrefix))
+		if other == nil {
+			return nil, side, nil, &errDiffSide{http.StatusNotFound, errors.New("unknown repository")}
+		}
+		return current(other)
+	}
+
+	if r.readOnly() {
+		return nil, side, nil, &errDiffSide{http.StatusBadRequest, errors.New("git refs need a source tree; the repository is served from snapshot files")}
+	}
+	abs, err := filepath.Abs(r.spec.Path)
+	if err != nil {
+		return nil, side, nil, err
+	}
+	commit, err := analyzer.ResolveGitRef(c.Request.Context(), abs, spec)
+	if err != nil {
+		return nil, side, nil, &errDiffSide{http.StatusBadRequest, err}
+	}
+	side.Commit = commit
+	if cached, ok := r.cachedRefSnapshot(c.Request.Context(), abs, commit); ok {
+		side.ContentHash = cached.Header.Stats.ContentHash
+		return analyzer.NewGraph(cached.Relations, r.entry.IsInternal), side, nil, nil
+	}
+	job, graph := r.jobs.submitAnalysis(commit)
+	if graph == nil {
+		return nil, side, job, nil
+	}
+	side.ContentHash = job.snapshot().ContentHash
+	return graph, side, nil, nil
+}
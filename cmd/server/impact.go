@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPatchBytes bounds the patch POST /api/impact reads
+const maxPatchBytes = 16 << 20
+
+// handleImpact reports the functions a change touches, their transitive callers and the roots reaching
+// them, with a call path for each.
+// GET: base (required) and head (optional) are git commits, branches or tags; the change is the diff
+// from base to head, or to the served revision without head.
+// POST: the body is a unified diff already applied to head (or the served revision); base optionally
+// names the revision before it, so removed functions are reported.
+// Without head the served relations are used; with it, head is analyzed through the commit cache.
+// format=text answers with the plain-text summary instead of JSON.
+func handleImpact(c *gin.Context) {
+	r := repoOf(c)
+	ctx := c.Request.Context()
+	base := strings.TrimSpace(c.Query("base"))
+	head := strings.TrimSpace(c.Query("head"))
+	if base == "" && c.Request.Method == http.MethodGet {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "'base' is required: a git commit, branch or tag"})
+		return
+	}
+	abs, err := filepath.Abs(r.spec.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, analyzer.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var baseCommit string
+	if base != "" {
+		if baseCommit, err = analyzer.ResolveGitRef(ctx, abs, base); err != nil {
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	// The head side: the served relations and their revision, or head analyzed on demand
+	var graph *analyzer.Graph
+	var roots []analyzer.Root
+	var headCommit string
+	if head == "" {
+		r.data.mu.RLock()
+		graph, roots, headCommit = r.data.graph, r.data.roots, r.data.source.Commit
+		r.data.mu.RUnlock()
+		if r.currentRef() == "" {
+			// Served from the working tree; the commit only describes where it started
+			headCommit = ""
+		}
+	} else {
+		if _, err := analyzer.ResolveGitRef(ctx, abs, head); err != nil {
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		snapshot, _, source, _, err := r.refSnapshot(ctx, nil, abs, head)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, analyzer.ErrorResponse{Error: head + ": " + err.Error()})
+			return
+		}
+		graph, headCommit = analyzer.NewGraph(snapshot.Relations, r.entry.IsInternal), source.Commit
+		// Classified like the served revision, so impact reports the entry points /api/relations lists
+		read, err := analyzer.GitBatchReader(ctx, abs, headCommit, r.entry.SourceFiles(graph))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, analyzer.ErrorResponse{Error: head + ": " + err.Error()})
+			return
+		}
+		roots = r.entry.Roots(graph, read)
+	}
+	readNew := analyzer.DirReader(abs)
+	if headCommit != "" {
+		readNew = analyzer.GitFileReader(ctx, abs, headCommit)
+	}
+	var readOld analyzer.SourceReader
+	if baseCommit != "" {
+		readOld = analyzer.GitFileReader(ctx, abs, baseCommit)
+	}
+
+	var patch []byte
+	if c.Request.Method == http.MethodPost {
+		patch, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, analyzer.ErrorResponse{Error: "patch too large"})
+			return
+		}
+	} else {
+		patch, err = analyzer.GitDiff(ctx, abs, baseCommit, headCommit)
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, analyzer.ErrorResponse{Error: err.Error()})
+		return
+	}
+	changes, err := analyzer.ParsePatch(bytes.NewReader(patch))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "invalid patch: " + err.Error()})
+		return
+	}
+	changed, err := analyzer.ChangedFunctions(changes, readNew, readOld)
+	if err != nil {
+		// The patch names files the head revision does not have
+		c.JSON(http.StatusUnprocessableEntity, analyzer.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	report := analyzer.Impact(graph, changed, roots)
+	report.Base, report.Head = base, head
+	if c.Query("format") == "text" {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Status(http.StatusOK)
+		report.WriteSummary(c.Writer)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
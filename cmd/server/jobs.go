@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+)
+
+// jobHistory is how many finished reload jobs GET /api/jobs keeps
+const jobHistory = 20
+
+// reloadJob is one asynchronous rescan. Its exported state is guarded by mu; the manager owns the rest.
+type reloadJob struct {
+	mu      sync.Mutex
+	state   analyzer.ReloadJob
+	started time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{} // closed once the job has finished
+
+	commit string          // commit an "analyze" job analyzes for GET /api/diff; "" for reloads
+	graph  *analyzer.Graph // the analyzed commit, kept only when the commit cache could not store it; guarded by mu
+}
+
+// setPhase records the analysis phase load is in; a nil job (the initial load) ignores it
+func (j *reloadJob) setPhase(phase string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.state.Phase = phase
+	j.mu.Unlock()
+}
+
+// progress is the analyzer.ParseOptions.Progress hook; reports may arrive out of order from parallel workers
+func (j *reloadJob) progress(phase string, done, total int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state.Phase = phase
+	switch phase {
+	case analyzer.PhaseWalking:
+		j.state.FilesTotal = done
+	case analyzer.PhaseParsing:
+		j.state.FilesTotal = total
+		if done > j.state.FilesParsed {
+			j.state.FilesParsed = done
+		}
+	}
+}
+
+// snapshot returns the job's current state with the elapsed time filled in
+func (j *reloadJob) snapshot() analyzer.ReloadJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state := j.state
+	state.Files = append([]string(nil), j.state.Files...)
+	switch {
+	case state.FinishedAt != nil && state.StartedAt != nil:
+		state.ElapsedMs = state.FinishedAt.Sub(*state.StartedAt).Milliseconds()
+	case state.StartedAt != nil:
+		state.ElapsedMs = time.Since(j.started).Milliseconds()
+	}
+	return state
+}
+
+// jobManager runs reload and analyze jobs one at a time, so scans never compete for the CPU. A reload
+// requested while another is queued joins that job instead of adding another, so bursts of reloads cost
+// at most one scan after the running one; an analysis of a commit already queued or running joins it.
+type jobManager struct {
+	run func(ctx context.Context, job *reloadJob) error
+
+	mu      sync.Mutex
+	nextID  int
+	running *reloadJob
+	queue   []*reloadJob // waiting jobs, oldest first; at most one reload
+	jobs    map[string]*reloadJob
+	order   []string // job IDs, oldest first
+}
+
+func newJobManager(run func(ctx context.Context, job *reloadJob) error) *jobManager {
+	return &jobManager{run: run, jobs: make(map[string]*reloadJob)}
+}
+
+// submit returns the queued reload (joining it) or a new one, which starts at once when nothing is running
+func (m *jobManager) submit(reason string, files []string) *reloadJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, job := range m.queue {
+		if job.commit != "" {
+			continue
+		}
+		job.mu.Lock()
+		job.state.Requests++
+		if job.state.Reason != reason {
+			job.state.Reason = "reload"
+		}
+		job.state.Files = mergeFiles(job.state.Files, files)
+		job.mu.Unlock()
+		return job
+	}
+
+	job := m.newJob(reason, "")
+	job.state.Files = mergeFiles(nil, files)
+	m.enqueue(job)
+	return job
+}
+
+// submitAnalysis returns the newest "analyze" job of commit that succeeded, still in the history, with the
+// graph it kept; otherwise the queued or running analysis of commit (joining it) or a new one, and no
+// graph. Analyses the commit cache stored keep no graph, so callers look there first and a commit whose
+// cached analysis went missing is analyzed again.
+func (m *jobManager) submitAnalysis(commit string) (*reloadJob, *analyzer.Graph) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.order) - 1; i >= 0; i-- {
+		job := m.jobs[m.order[i]]
+		if job.commit != commit {
+			continue
+		}
+		job.mu.Lock()
+		status, graph := job.state.Status, job.graph
+		if status == analyzer.JobQueued || status == analyzer.JobRunning {
+			job.state.Requests++
+		}
+		job.mu.Unlock()
+		switch {
+		case graph != nil:
+			return job, graph
+		case status == analyzer.JobQueued || status == analyzer.JobRunning:
+			return job, nil
+		}
+	}
+
+	job := m.newJob("analyze", commit)
+	job.state.Commit = commit
+	m.enqueue(job)
+	return job, nil
+}
+
+// newJob creates a queued job and adds it to the history; m.mu must be held
+func (m *jobManager) newJob(reason, commit string) *reloadJob {
+	m.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &reloadJob{
+		state: analyzer.ReloadJob{
+			ID:        fmt.Sprintf("job-%d", m.nextID),
+			Status:    analyzer.JobQueued,
+			Reason:    reason,
+			Requests:  1,
+			CreatedAt: time.Now(),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		commit: commit,
+	}
+	m.jobs[job.state.ID] = job
+	m.order = append(m.order, job.state.ID)
+	m.prune()
+	return job
+}
+
+// enqueue starts job at once when nothing is running and queues it otherwise; m.mu must be held
+func (m *jobManager) enqueue(job *reloadJob) {
+	if m.running == nil {
+		m.start(job)
+	} else {
+		m.queue = append(m.queue, job)
+	}
+}
+
+// start runs job in the background and then the queued job, if any; m.mu must be held
+func (m *jobManager) start(job *reloadJob) {
+	m.running = job
+	now := time.Now()
+	job.mu.Lock()
+	job.state.Status = analyzer.JobRunning
+	job.state.StartedAt = &now
+	job.started = now
+	job.mu.Unlock()
+
+	go func() {
+		err := m.run(job.ctx, job)
+
+		finished := time.Now()
+		job.mu.Lock()
+		job.state.FinishedAt = &finished
+		job.state.Phase = ""
+		switch {
+		case err == nil:
+			job.state.Status = analyzer.JobSucceeded
+		case errors.Is(err, context.Canceled):
+			job.state.Status = analyzer.JobCancelled
+			job.state.Error = err.Error()
+		default:
+			job.state.Status = analyzer.JobFailed
+			job.state.Error = err.Error()
+		}
+		job.mu.Unlock()
+		job.cancel()
+		close(job.done)
+
+		m.mu.Lock()
+		m.running = nil
+		if len(m.queue) > 0 {
+			next := m.queue[0]
+			m.queue = m.queue[1:]
+			m.start(next)
+		}
+		m.mu.Unlock()
+	}()
+}
+
+// cancel stops a queued or running job; it reports false for unknown or finished jobs
+func (m *jobManager) cancel(id string) (*reloadJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	if job == nil {
+		return nil, false
+	}
+	if i := slices.Index(m.queue, job); i >= 0 {
+		// Never started: finish it here so it does not run later
+		m.queue = slices.Delete(m.queue, i, i+1)
+		now := time.Now()
+		job.mu.Lock()
+		job.state.Status = analyzer.JobCancelled
+		job.state.Error = context.Canceled.Error()
+		job.state.FinishedAt = &now
+		job.mu.Unlock()
+		job.cancel()
+		close(job.done)
+		return job, true
+	}
+	if job != m.running {
+		return job, false
+	}
+	job.cancel()
+	return job, true
+}
+
+// cancelAll cancels the queued and running jobs, for a repository that is no longer hosted, and
+// returns them
+func (m *jobManager) cancelAll() []*reloadJob {
+	m.mu.Lock()
+	var ids []string
+	for _, job := range append([]*reloadJob{m.running}, m.queue...) {
+		if job != nil {
+			ids = append(ids, job.state.ID)
+		}
+	}
+	m.mu.Unlock()
+	var cancelled []*reloadJob
+	for _, id := range ids {
+		if job, ok := m.cancel(id); ok {
+			cancelled = append(cancelled, job)
+		}
+	}
+	return cancelled
+}
+
+// get looks up a job by ID
+func (m *jobManager) get(id string) *reloadJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jobs[id]
+}
+
+// list returns the known jobs, newest first
+func (m *jobManager) list() []analyzer.ReloadJob {
+	m.mu.Lock()
+	jobs := make([]*reloadJob, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, m.jobs[m.order[i]])
+	}
+	m.mu.Unlock()
+
+	states := make([]analyzer.ReloadJob, len(jobs))
+	for i, job := range jobs {
+		states[i] = job.snapshot()
+	}
+	return states
+}
+
+// prune forgets the oldest finished jobs beyond jobHistory; m.mu must be held
+func (m *jobManager) prune() {
+	for len(m.order) > jobHistory {
+		oldest := m.jobs[m.order[0]]
+		if oldest == m.running || slices.Contains(m.queue, oldest) {
+			return
+		}
+		delete(m.jobs, m.order[0])
+		m.order = m.order[1:]
+	}
+}
+
+// mergeFiles adds the changed paths of a coalesced watch reload, keeping them sorted and unique
+func mergeFiles(existing, added []string) []string {
+	if len(added) == 0 {
+		return existing
+	}
+	seen := make(map[string]bool, len(existing)+len(added))
+	for _, f := range existing {
+		seen[f] = true
+	}
+	for _, f := range added {
+		seen[f] = true
+	}
+	merged := make([]string, 0, len(seen))
+	for f := range seen {
+		merged = append(merged, f)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// runReload is the job body: load, then notify /events subscribers when the relations changed
+func (r *repo) runReload(ctx context.Context, job *reloadJob) error {
+	if job.commit != "" {
+		return r.runAnalysis(ctx, job)
+	}
+	state := job.snapshot()
+	log.Printf("Reload %s of %s started (%s, %d request(s))", state.ID, r.spec.ID, state.Reason, state.Requests)
+
+	r.data.mu.RLock()
+	previous := r.data.hash
+	r.data.mu.RUnlock()
+
+	if err := r.load(ctx, job); err != nil {
+		log.Printf("Reload %s of %s failed: %v", state.ID, r.spec.ID, err)
+		if !errors.Is(err, context.Canceled) {
+			r.data.mu.Lock()
+			r.data.loadErr = err.Error()
+			r.data.mu.Unlock()
+		}
+		return err
+	}
+
+	r.data.mu.RLock()
+	hash, loadedAt, source := r.data.hash, r.data.loadedAt, r.data.source
+	r.data.mu.RUnlock()
+	job.mu.Lock()
+	job.state.ContentHash = hash
+	job.state.LoadedAt = &loadedAt
+	job.state.Source = &source
+	files := append([]string(nil), job.state.Files...)
+	reason := job.state.Reason
+	job.mu.Unlock()
+
+	if hash != previous {
+		r.events.publish(analyzer.ChangeEvent{Reason: reason, Files: files, ContentHash: hash, LoadedAt: loadedAt})
+	}
+	log.Printf("Reload %s of %s completed", state.ID, r.spec.ID)
+	return nil
+}
+
+// runAnalysis is the body of an "analyze" job: analyze a commit through the commit cache for GET /api/diff,
+// without serving it. The history keeps the content hash and status; the graph only when the commit cache
+// could not store it.
+func (r *repo) runAnalysis(ctx context.Context, job *reloadJob) error {
+	state := job.snapshot()
+	log.Printf("Analysis %s of %s at %s started (%d request(s))", state.ID, r.spec.ID, job.commit, state.Requests)
+	abs, err := filepath.Abs(r.spec.Path)
+	if err != nil {
+		return err
+	}
+	snapshot, _, source, stored, err := r.refSnapshot(ctx, job, abs, job.commit)
+	if err != nil {
+		log.Printf("Analysis %s of %s failed: %v", state.ID, r.spec.ID, err)
+		return err
+	}
+	job.mu.Lock()
+	if !stored {
+		// Without the commit cache GET /api/diff has nowhere else to find the result
+		job.graph = analyzer.NewGraph(snapshot.Relations, r.entry.IsInternal)
+	}
+	job.state.ContentHash = snapshot.Header.Stats.ContentHash
+	job.state.Source = &source
+	job.mu.Unlock()
+	log.Printf("Analysis %s of %s completed", state.ID, r.spec.ID)
+	return nil
+}
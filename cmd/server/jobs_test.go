@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+)
+
+// blockingRun is a jobManager body that holds every job until released and gives analyze jobs a graph,
+// except those of commits the commit cache stores
+type blockingRun struct {
+	release chan struct{}
+	started chan string
+	stored  map[string]bool
+}
+
+func newBlockingRun() *blockingRun {
+	return &blockingRun{release: make(chan struct{}), started: make(chan string, 10)}
+}
+
+func (b *blockingRun) run(ctx context.Context, job *reloadJob) error {
+	b.started <- job.state.ID
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if job.commit != "" && !b.stored[job.commit] {
+		job.mu.Lock()
+		job.graph = analyzer.NewGraph(nil, nil)
+		job.mu.Unlock()
+	}
+	return nil
+}
+
+// finish releases the running job and waits until it is done
+func (b *blockingRun) finish(t *testing.T, job *reloadJob) {
+	t.Helper()
+	b.release <- struct{}{}
+	<-job.done
+}
+
+func TestJobManagerQueue(t *testing.T) {
+	b := newBlockingRun()
+	b.stored = map[string]bool{"def": true}
+	m := newJobManager(b.run)
+
+	running := m.submit("reload", nil)
+	<-b.started
+	reload := m.submit("watch", []string{"a.go"})
+	analysis, graph := m.submitAnalysis("abc")
+	if graph != nil {
+		t.Fatal("submitAnalysis() returned a graph before any analysis ran")
+	}
+
+	tests := []struct {
+		name string
+		got  *reloadJob
+		want *reloadJob
+	}{
+		{"a reload joins the queued reload, not the queued analysis", m.submit("reload", []string{"b.go"}), reload},
+		{"an analysis of the same commit joins the queued one", first(m.submitAnalysis("abc")), analysis},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, tt.got.state.ID, tt.want.state.ID)
+		}
+	}
+	other, _ := m.submitAnalysis("def")
+	if other == analysis || other == reload {
+		t.Fatalf("an analysis of another commit joined %s", other.state.ID)
+	}
+	if state := reload.snapshot(); state.Requests != 2 || len(state.Files) != 2 || state.Reason != "reload" {
+		t.Errorf("joined reload = %+v, want 2 requests of reason reload for a.go and b.go", state)
+	}
+
+	// Jobs run one at a time, in submission order
+	for _, job := range []*reloadJob{running, reload, analysis} {
+		if job != running {
+			if id := <-b.started; id != job.state.ID {
+				t.Fatalf("started %s, want %s", id, job.state.ID)
+			}
+		}
+		b.finish(t, job)
+	}
+	if state := analysis.snapshot(); state.Status != analyzer.JobSucceeded || state.Commit != "abc" || state.Reason != "analyze" || state.Requests != 2 {
+		t.Errorf("analysis = %+v, want a succeeded analyze job of abc with 2 requests", state)
+	}
+
+	// A finished analysis is served from the history without another job
+	if job, graph := m.submitAnalysis("abc"); job != analysis || graph == nil {
+		t.Errorf("submitAnalysis(abc) after it succeeded = %v, %v; want %s and its graph", job, graph, analysis.state.ID)
+	}
+
+	// Cancelling a queued analysis removes it from the queue
+	if id := <-b.started; id != other.state.ID {
+		t.Fatalf("started %s, want %s", id, other.state.ID)
+	}
+	queued, _ := m.submitAnalysis("ghi")
+	if _, ok := m.cancel(queued.state.ID); !ok {
+		t.Fatal("cancel(queued analysis) = false")
+	}
+	if state := queued.snapshot(); state.Status != analyzer.JobCancelled {
+		t.Errorf("cancelled analysis status = %s", state.Status)
+	}
+	b.finish(t, other)
+
+	// An analysis the commit cache stored keeps no graph: the history cannot serve it
+	again, graph := m.submitAnalysis("def")
+	if again == other || graph != nil {
+		t.Errorf("submitAnalysis(def) after a stored analysis = %s, %v; want a new job", again.state.ID, graph)
+	}
+	<-b.started
+	b.finish(t, again)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) != 0 {
+		t.Errorf("queue = %d jobs after the last job finished", len(m.queue))
+	}
+}
+
+func first(job *reloadJob, _ *analyzer.Graph) *reloadJob {
+	return job
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
-	"sort"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -19,29 +22,95 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// cache holds the in-memory representation of relations + index for quick lookups.
+// cache holds the in-memory call graph all endpoints query.
 type cache struct {
 	mu        sync.RWMutex
-	functions []analyzer.FunctionInfo         // raw filtered function infos (with Calls)
-	relations []analyzer.OutRelation          // flattened relations list
-	index     map[string]analyzer.OutRelation // composite key name|filePath
-	roots     []analyzer.OutRelation          // relations whose function is not called by any other (entry points)
+	functions []analyzer.FunctionInfo // raw filtered function infos (with Calls)
+	relations []analyzer.OutRelation  // the analyzed relations, in canonical order
+	graph     *analyzer.Graph         // relations and the tests calling them, with forward and reverse adjacency
+	roots     []analyzer.Root         // entry points, by kind then node (see analyzer.RootClassifier)
+	hash      string                  // content hash of relations in canonical order
+	header    analyzer.SnapshotHeader // metadata of the loaded or generated snapshot
+	source    analyzer.DataSource     // where the relations came from
 	loadedAt  time.Time
+	loadErr   string // why the latest load failed, "" after a success
 }
 
-var global cache
+// downloadFlushEvery is the number of relations encoded between flushes of /api/download
+const downloadFlushEvery = 500
 
 func main() {
 	var repoPath string
 	var addr string
 	var includeExternal bool
 	var skipFolders string
+	var cacheDir string
+	var noCache bool
+	var workers int
+	var watch bool
+	var watchDebounce time.Duration
+	var watchPoll bool
+	var snapshotMode string
+	var snapshotFiles stringList
+	var configPath string
+	var maxUploadMB, maxUnpackedMB int64
+	var maxUploads int
+	var ref string
+	var rootKinds string
+	var manageRepos bool
+	var allowUploads bool
+	var repoRoots stringList
 	flag.StringVar(&repoPath, "path", ".", "path to repository root")
 	flag.StringVar(&addr, "addr", ":8080", "listen address")
 	flag.BoolVar(&includeExternal, "include-external", false, "include external library calls in relations (store all calls in memory)")
 	flag.StringVar(&skipFolders, "skip-folders", "", "comma-separated list of folder patterns to skip when scanning external dependencies (e.g., 'golang.org,google.golang.org')")
+	flag.StringVar(&cacheDir, "cache-dir", "", "directory of the per-file analysis cache (default: the user cache directory)")
+	flag.BoolVar(&noCache, "no-cache", false, "reparse every file on each scan instead of reusing cached results for unchanged files")
+	flag.IntVar(&workers, "workers", 0, "number of files parsed in parallel (default: GOMAXPROCS)")
+	flag.BoolVar(&watch, "watch", false, "watch the repository and reanalyze automatically when Go files change")
+	flag.DurationVar(&watchDebounce, "watch-debounce", analyzer.DefaultWatchDebounce, "quiet period after the last file change before a watch reload")
+	flag.BoolVar(&watchPoll, "watch-poll", false, "poll for changes instead of using native file notifications")
+	flag.StringVar(&snapshotMode, "snapshot", snapshotPrefer, "use of functionmap.bin/functionmap.json in the repository: prefer (only while they match the source tree), ignore (always rescan) or only (never rescan, even when stale)")
+	flag.Var(&snapshotFiles, "snapshot-file", "serve this relation file (functionmap.json, .bin or a bare relation array) read-only, without a source tree; repeat to merge several")
+	flag.StringVar(&configPath, "config", "", "JSON file listing the repositories to host (replaces -path and -snapshot-file; the analysis flags become defaults)")
+	flag.Int64Var(&maxUploadMB, "max-upload-mb", 64, "largest archive POST /api/analyze accepts, in MiB")
+	flag.Int64Var(&maxUnpackedMB, "max-unpacked-mb", 512, "largest total size an uploaded archive may unpack to, in MiB")
+	flag.BoolVar(&allowUploads, "allow-uploads", false, "allow POST /api/analyze to unpack and analyze uploaded archives (no authentication: trusted networks only)")
+	flag.IntVar(&maxUploads, "max-uploads", 10, "uploaded repositories hosted at once with -allow-uploads")
+	flag.StringVar(&ref, "ref", "", "serve this commit, branch or tag of the git repository at -path instead of its working tree")
+	flag.BoolVar(&manageRepos, "manage-repos", false, "allow POST /api/repos and DELETE /api/repos/{id} for configured repositories (no authentication: trusted networks only)")
+	flag.Var(&repoRoots, "repo-root", "directory repositories added with POST /api/repos must lie in; repeat to allow several (default: any)")
+	flag.StringVar(&rootKinds, "root-kinds", "", "comma-separated root kinds listed by /api/relations: main, init, handler, test, api, unknown (default: all)")
 	flag.Parse()
 
+	switch snapshotMode {
+	case snapshotPrefer, snapshotIgnore, snapshotOnly:
+	default:
+		log.Fatalf("invalid -snapshot %q: want prefer, ignore or only", snapshotMode)
+	}
+	if watch && snapshotMode == snapshotOnly {
+		log.Fatalf("-watch reanalyzes the source tree and cannot be combined with -snapshot=only")
+	}
+	if watch && len(snapshotFiles) > 0 {
+		log.Fatalf("-watch needs a source tree and cannot be combined with -snapshot-file")
+	}
+	if configPath != "" && len(snapshotFiles) > 0 {
+		log.Fatalf("-snapshot-file cannot be combined with -config; list the files in the config instead")
+	}
+	if ref != "" && (configPath != "" || len(snapshotFiles) > 0) {
+		log.Fatalf("-ref selects a revision of -path; set \"ref\" per repository in -config instead")
+	}
+	if len(repoRoots) > 0 && !manageRepos {
+		log.Fatalf("-repo-root restricts POST /api/repos and needs -manage-repos")
+	}
+	admin, err := newRepoAdmin(manageRepos, repoRoots)
+	if err != nil {
+		log.Fatalf("invalid -repo-root: %v", err)
+	}
+	if rootKinds != "" && configPath != "" {
+		log.Fatalf("-root-kinds configures the single repository; set \"entryPoints\" per repository in -config instead")
+	}
+
 	// Parse skip patterns
 	var skipPatterns []string
 	if skipFolders != "" {
@@ -52,8 +121,54 @@ func main() {
 		log.Printf("Skipping external dependency folders matching: %v", skipPatterns)
 	}
 
-	if err := load(repoPath, includeExternal, skipPatterns); err != nil {
-		log.Fatalf("initial load failed: %v", err)
+	// Process-wide settings; each repository brings its own analysis flags
+	base := loadOptions{cacheDir: cacheDir, noCache: noCache, workers: workers}
+	watchOpts := analyzer.WatchOptions{Debounce: watchDebounce, Poll: watchPoll}
+	// The analysis flags are the defaults of -config entries and POST /api/repos bodies
+	defaults := analyzer.RepoSpec{IncludeExternal: includeExternal, SkipFolders: skipPatterns, Snapshot: snapshotMode, Watch: watch}
+
+	if configPath != "" {
+		// Hosted repositories load in the background; their endpoints answer 503 until ready
+		list, err := loadReposConfig(configPath, defaults, base, watchOpts)
+		if err != nil {
+			log.Fatalf("loading -config failed: %v", err)
+		}
+		for _, r := range list {
+			if err := repos.add(r); err != nil {
+				log.Fatalf("loading -config failed: %v", err)
+			}
+			log.Printf("Hosting repository %s (%s)", r.spec.ID, r.describe())
+			r.open()
+		}
+	} else {
+		// A single repository is loaded before serving, and startup fails if it cannot be
+		spec := defaults
+		spec.ID = "default"
+		if len(snapshotFiles) > 0 {
+			// Snapshot files stand in for the repository; -path and the analysis flags are unused
+			spec.SnapshotFiles = snapshotFiles
+		} else {
+			spec.Path, spec.Ref = repoPath, ref
+		}
+		if rootKinds != "" {
+			spec.EntryPoints = &analyzer.EntryPoints{Kinds: strings.Split(rootKinds, ",")}
+			for i, kind := range spec.EntryPoints.Kinds {
+				spec.EntryPoints.Kinds[i] = strings.TrimSpace(kind)
+			}
+		}
+		r, err := newRepo(spec, ".", base, watchOpts)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := r.load(context.Background(), nil); err != nil {
+			log.Fatalf("initial load failed: %v", err)
+		}
+		if err := repos.add(r); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if r.spec.Watch {
+			go r.watch()
+		}
 	}
 
 	// Create Gin router
@@ -62,31 +177,33 @@ func main() {
 	// Add CORS middleware
 	router.Use(corsMiddleware())
 
-	// API routes
-	router.GET("/api/relations", handleRelations)
-	router.GET("/api/search", handleSearch)
-	router.POST("/api/reload", func(c *gin.Context) {
-		log.Printf("Reloading data from repository: %s", repoPath)
-		if err := load(repoPath, includeExternal, skipPatterns); err != nil {
-			log.Printf("Reload failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		log.Printf("Data reload completed successfully")
-		c.JSON(http.StatusOK, gin.H{"status": "reloaded", "loadedAt": global.loadedAt})
-	})
-
-	router.GET("/api/download", func(c *gin.Context) {
-		c.Header("Content-Type", "application/json")
-		c.Header("Content-Disposition", "attachment; filename=function_relations.json")
-		// Pretty-print the in-memory relations for download
-		data, err := json.MarshalIndent(global.relations, "", "  ")
-		if err != nil {
-			c.String(http.StatusInternalServerError, fmt.Sprintf("failed to format JSON: %v", err))
-			return
-		}
-		c.Data(http.StatusOK, "application/json", data)
-	})
+	// Repository management
+	router.GET("/api/repos", handleListRepos)
+	router.POST("/api/repos", handleAddRepo(defaults, base, watchOpts, admin))
+	router.GET("/api/repos/:id", withNamedRepo, handleGetRepo)
+	router.DELETE("/api/repos/:id", handleRemoveRepo(admin))
+	router.POST("/api/analyze", handleAnalyze(uploadLimits{enabled: allowUploads, archiveBytes: maxUploadMB << 20, unpackedBytes: maxUnpackedMB << 20, repos: maxUploads}, defaults, base))
+
+	// Per-repository API routes, under /api/repos/{id} and, for the first repository, directly under /api
+	for _, api := range []*gin.RouterGroup{router.Group("/api", withDefaultRepo), router.Group("/api/repos/:id", withNamedRepo)} {
+		api.GET("/relations", requireData, handleRelations)
+		api.GET("/search", requireData, handleSearch)
+		api.GET("/callers", requireData, handleCallers)
+		api.GET("/expand", requireData, handleExpand)
+		api.GET("/paths", requireData, handlePaths)
+		api.GET("/neighborhood", requireData, handleNeighborhood)
+		api.POST("/reload", requireSource, handleReload)
+		api.GET("/jobs", requireSource, handleJobs)
+		api.GET("/jobs/:job", requireSource, handleJob)
+		api.DELETE("/jobs/:job", requireSource, handleCancelJob)
+		api.GET("/refs", requireSource, handleRefs)
+		api.POST("/ref", requireSource, handleSelectRef)
+		api.GET("/diff", handleDiff)
+		api.GET("/impact", requireSource, requireData, handleImpact)
+		api.POST("/impact", requireSource, requireData, handleImpact)
+		api.GET("/events", handleEvents)
+		api.GET("/download", requireData, handleDownload)
+	}
 
 	// Get the absolute path to the executable to locate static files
 	execDir, err := os.Executable()
@@ -150,233 +267,466 @@ func main() {
 	log.Fatal(router.Run(addr))
 }
 
-// loadExistingFunctionMap tries to load existing functionmap.json file for consistency
-func loadExistingFunctionMap(functionMapPath string) ([]analyzer.OutRelation, error) {
-	data, err := os.ReadFile(functionMapPath)
-	if err != nil {
-		return nil, err
+// Snapshot modes (-snapshot): how load treats functionmap.bin and functionmap.json in the repository root
+const (
+	snapshotPrefer = "prefer" // serve a snapshot only while it matches the source tree, otherwise rescan
+	snapshotIgnore = "ignore" // always rescan
+	snapshotOnly   = "only"   // never rescan; serve a stale snapshot rather than none
+)
+
+// loadRepoSnapshot returns the first usable snapshot in root, trying functionmap.bin before
+// functionmap.json. A snapshot is usable when it was generated for this module with the same flags
+// and, unless opts.snapshot is snapshotOnly, from exactly the current source tree. When none is usable
+// the error lists why each one was skipped.
+func loadRepoSnapshot(ctx context.Context, root, module string, opts loadOptions) (analyzer.Snapshot, analyzer.DataSource, error) {
+	flags := analyzer.SnapshotFlags{IncludeExternal: opts.includeExternal, SkipPatterns: opts.skipPatterns}
+	var fingerprint string
+	var reasons []string
+	var stale *analyzer.Snapshot
+	var staleSource analyzer.DataSource
+
+	candidates := []struct {
+		kind string
+		read func(path string) (analyzer.Snapshot, error)
+	}{
+		{analyzer.SourceSnapshotBinary, analyzer.LoadBinarySnapshot},
+		{analyzer.SourceSnapshotJSON, analyzer.LoadSnapshot},
 	}
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return analyzer.Snapshot{}, analyzer.DataSource{}, err
+		}
+		path := filepath.Join(root, candidate.kind)
+		if stat, err := os.Stat(path); err != nil || stat.IsDir() {
+			continue
+		}
+		snapshot, err := candidate.read(path)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %v", candidate.kind, err))
+			continue
+		}
 
-	var relations []analyzer.OutRelation
-	if err := json.Unmarshal(data, &relations); err != nil {
-		return nil, err
+		// The fingerprint walks the whole tree, so compute it once and only when a snapshot exists
+		if fingerprint == "" {
+			if fingerprint, err = analyzer.SourceFingerprint(root); err != nil {
+				return analyzer.Snapshot{}, analyzer.DataSource{}, err
+			}
+		}
+		err = snapshot.Header.CheckFresh(module, flags, fingerprint)
+		source := analyzer.DataSource{Kind: candidate.kind, Fresh: err == nil, GeneratedAt: snapshot.Header.GeneratedAt()}
+		if err == nil {
+			return snapshot, source, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %v", candidate.kind, err))
+		if opts.snapshot == snapshotOnly && errors.Is(err, analyzer.ErrStaleSnapshot) && stale == nil {
+			stale, staleSource = &snapshot, source
+			staleSource.Reason = err.Error()
+		}
 	}
 
-	return relations, nil
+	if stale != nil {
+		return *stale, staleSource, nil
+	}
+	if len(reasons) == 0 {
+		return analyzer.Snapshot{}, analyzer.DataSource{}, fmt.Errorf("no %s or %s in %s", analyzer.SourceSnapshotBinary, analyzer.SourceSnapshotJSON, root)
+	}
+	return analyzer.Snapshot{}, analyzer.DataSource{}, errors.New(strings.Join(reasons, "; "))
+}
+
+// loadOptions are the analysis settings fixed at startup and reused by every reload
+type loadOptions struct {
+	includeExternal bool
+	skipPatterns    []string
+	cacheDir        string // per-file analysis cache directory ("" for the default)
+	noCache         bool
+	workers         int    // parallel parsers (0 for GOMAXPROCS)
+	snapshot        string // snapshotPrefer, snapshotIgnore or snapshotOnly
 }
 
-// load (re)scans repository, rebuilds structures and populates cache.
-func load(root string, includeExternal bool, skipPatterns []string) error {
-	abs, err := filepath.Abs(root)
+// load (re)loads the repository into its cache: snapshot files for read-only repositories, the source
+// tree (or its snapshots) otherwise
+func (r *repo) load(ctx context.Context, job *reloadJob) error {
+	if r.readOnly() {
+		return r.loadSnapshotFiles()
+	}
+	return r.scan(ctx, job)
+}
+
+// scan (re)scans the repository, rebuilds structures and populates the cache. Cancelling ctx aborts a
+// scan and leaves the cache untouched. Progress is reported to job, which is nil for a startup load.
+func (r *repo) scan(ctx context.Context, job *reloadJob) error {
+	opts := r.opts
+	abs, err := filepath.Abs(r.spec.Path)
 	if err != nil {
 		return err
 	}
+	if ref := r.currentRef(); ref != "" {
+		return r.scanRef(ctx, job, abs, ref)
+	}
 
 	log.Printf("Scanning repository: %s", abs)
+	job.setPhase(analyzer.PhaseWalking)
 
-	// First, try to load existing functionmap.json if it exists
-	var relations []analyzer.OutRelation
-	var functions []analyzer.FunctionInfo
-	functionMapPath := filepath.Join(abs, "functionmap.json")
-
-	// Always try to load functionmap.json if it exists, regardless of includeExternal flag
-	if stat, err := os.Stat(functionMapPath); err == nil && !stat.IsDir() {
-		log.Printf("Found existing functionmap.json, attempting to load...")
-		if loadedRelations, err := loadExistingFunctionMap(functionMapPath); err == nil {
-			log.Printf("Successfully loaded %d relations from functionmap.json", len(loadedRelations))
-			relations = loadedRelations
-		} else {
-			log.Printf("Failed to load functionmap.json: %v, falling back to scanning", err)
+	// Serve a snapshot while it still matches the sources (or, with -snapshot=only, whatever is there)
+	var source analyzer.DataSource
+	if opts.snapshot != snapshotIgnore {
+		start := time.Now()
+		module, _ := analyzer.GetModule(abs)
+		snapshot, snapshotSource, err := loadRepoSnapshot(ctx, abs, module, opts)
+		switch {
+		case err == nil:
+			log.Printf("Loaded %d relations from %s in %v", len(snapshot.Relations), snapshotSource.Kind, time.Since(start))
+			if !snapshotSource.Fresh {
+				log.Printf("Warning: serving a stale snapshot (%s)", snapshotSource.Reason)
+			}
+			r.install(snapshot, nil, snapshotSource)
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case opts.snapshot == snapshotOnly:
+			return fmt.Errorf("no usable snapshot (-snapshot=only): %w", err)
+		default:
+			log.Printf("Not using a snapshot, rescanning: %v", err)
+			source.Reason = err.Error()
 		}
 	}
 
-	// If we couldn't load the existing file, scan and generate relations
-	if len(relations) == 0 {
-		module, err := analyzer.GetModule(abs)
-		if err != nil {
-			return err
+	// Otherwise scan and generate relations
+	snapshot, functions, err := r.analyze(ctx, job, abs, abs)
+	if err != nil {
+		return err
+	}
+	source.Kind, source.Fresh, source.GeneratedAt = analyzer.SourceScan, true, snapshot.Header.GeneratedAt()
+	r.install(snapshot, functions, source)
+	return nil
+}
+
+// scanRef loads the repository at abs as of a git ref
+func (r *repo) scanRef(ctx context.Context, job *reloadJob, abs, ref string) error {
+	snapshot, functions, source, _, err := r.refSnapshot(ctx, job, abs, ref)
+	if err != nil {
+		return err
+	}
+	r.install(snapshot, functions, source)
+	return nil
+}
+
+// refSnapshot analyzes the repository at abs as of a git ref without installing the result. Each
+// commit is analyzed once per set of analysis flags and kept in the commit cache, so switching back to
+// a ref analyzed before is a file read. functions is nil for a cached commit; stored reports whether
+// cachedRefSnapshot finds the snapshot from now on.
+func (r *repo) refSnapshot(ctx context.Context, job *reloadJob, abs, ref string) (snapshot analyzer.Snapshot, functions []analyzer.FunctionInfo, source analyzer.DataSource, stored bool, err error) {
+	opts := r.opts
+	job.setPhase(analyzer.PhaseCheckout)
+	commit, err := analyzer.ResolveGitRef(ctx, abs, ref)
+	if err != nil {
+		return analyzer.Snapshot{}, nil, analyzer.DataSource{}, false, err
+	}
+	log.Printf("Loading %s at %s (commit %s)", abs, ref, commit)
+	source = analyzer.DataSource{Kind: analyzer.SourceScan, Fresh: true, Ref: ref, Commit: commit}
+
+	flags := analyzer.SnapshotFlags{IncludeExternal: opts.includeExternal, SkipPatterns: opts.skipPatterns}
+	refCache, err := analyzer.OpenRefCache(ctx, opts.cacheDir, abs, flags)
+	if err != nil {
+		log.Printf("Commit cache disabled: %v", err)
+		refCache = nil
+	}
+	if refCache != nil && !opts.noCache {
+		start := time.Now()
+		cached, err := refCache.Load(commit)
+		if err == nil {
+			log.Printf("Loaded %d relations of commit %s from the commit cache in %v", len(cached.Relations), commit, time.Since(start))
+			source.Kind, source.GeneratedAt = analyzer.SourceRefCache, cached.Header.GeneratedAt()
+			return cached, nil, source, true, nil
+		}
+		if !analyzer.IsNotCached(err) {
+			log.Printf("Warning: ignoring unreadable cached analysis of %s: %v", commit, err)
 		}
+	}
 
-		log.Println("Scanning Go files for functions...")
-		// Walk & extract
-		err = filepath.Walk(abs, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
-				return nil
-			}
-			fns, ferr := analyzer.FindFunctions(path, abs, module)
-			if ferr != nil {
-				return ferr
-			}
-			functions = append(functions, fns...)
-			return nil
-		})
-		if err != nil {
-			return err
+	// The revision is written to a private directory from the object database; the work tree is untouched
+	tmp, err := os.MkdirTemp("", "gomindmapper-ref-")
+	if err != nil {
+		return analyzer.Snapshot{}, nil, analyzer.DataSource{}, false, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := analyzer.CheckoutGitRef(ctx, abs, commit, tmp); err != nil {
+		return analyzer.Snapshot{}, nil, analyzer.DataSource{}, false, err
+	}
+
+	snapshot, functions, err = r.analyze(ctx, job, tmp, abs)
+	if err != nil {
+		return analyzer.Snapshot{}, nil, analyzer.DataSource{}, false, err
+	}
+	snapshot.Header.VCS = analyzer.GitRefVCS(commit)
+	if refCache != nil {
+		if err := refCache.Save(commit, snapshot); err != nil {
+			log.Printf("Warning: failed to cache the analysis of %s: %v", commit, err)
+		} else {
+			stored = !opts.noCache
 		}
+	}
+	source.GeneratedAt = snapshot.Header.GeneratedAt()
+	return snapshot, functions, source, stored, nil
+}
 
-		log.Printf("Found %d functions in local repository", len(functions))
+// cachedRefSnapshot returns the analysis of commit from the commit cache. It reports false on a miss,
+// with -no-cache, or when the cache cannot be opened.
+func (r *repo) cachedRefSnapshot(ctx context.Context, abs, commit string) (analyzer.Snapshot, bool) {
+	opts := r.opts
+	if opts.noCache {
+		return analyzer.Snapshot{}, false
+	}
+	flags := analyzer.SnapshotFlags{IncludeExternal: opts.includeExternal, SkipPatterns: opts.skipPatterns}
+	refCache, err := analyzer.OpenRefCache(ctx, opts.cacheDir, abs, flags)
+	if err != nil {
+		return analyzer.Snapshot{}, false
+	}
+	cached, err := refCache.Load(commit)
+	return cached, err == nil
+}
 
-		// If include-external is true, scan external modules (same as CLI)
-		var externalFunctions []analyzer.FunctionInfo
-		if includeExternal {
-			log.Println("Scanning external modules...")
-			if len(skipPatterns) > 0 {
-				log.Printf("Skipping external dependency folders matching: %v", skipPatterns)
-			}
+// analyze parses the Go module at root and builds its relation snapshot. cacheRoot names the per-file
+// cache to use, which is the repository's own even when root is a checked-out revision of it.
+func (r *repo) analyze(ctx context.Context, job *reloadJob, root, cacheRoot string) (analyzer.Snapshot, []analyzer.FunctionInfo, error) {
+	opts := r.opts
+	includeExternal, skipPatterns := opts.includeExternal, opts.skipPatterns
+	job.setPhase(analyzer.PhaseWalking)
+	module, err := analyzer.GetModule(root)
+	if err != nil {
+		return analyzer.Snapshot{}, nil, err
+	}
 
-			// Add memory monitoring for large datasets
-			var m runtime.MemStats
-			runtime.GC()
-			runtime.ReadMemStats(&m)
-			log.Printf("Memory before external scanning: %.2f MB", float64(m.Alloc)/1024/1024)
+	// Unchanged files are served from the per-file analysis cache
+	var fileCache *analyzer.FileCache
+	if !opts.noCache {
+		if fileCache, err = analyzer.OpenFileCache(opts.cacheDir, cacheRoot); err != nil {
+			log.Printf("Analysis cache disabled: %v", err)
+			fileCache = nil
+		}
+	}
 
-			extFuncs, err := analyzer.ScanExternalModules(abs, functions, skipPatterns)
-			if err != nil {
-				log.Printf("Warning: failed to scan external modules: %v", err)
-			} else {
-				externalFunctions = extFuncs
-				log.Printf("Successfully scanned external modules and found %d external functions", len(externalFunctions))
+	log.Println("Scanning Go files for functions...")
+	// Every file is read and parsed once; type and interface detection reuse the parsed project
+	project, err := analyzer.ParseProject(ctx, root, analyzer.ParseOptions{
+		Module:   module,
+		Workers:  opts.workers,
+		AllCalls: includeExternal,
+		Cache:    fileCache,
+		Progress: job.progress,
+	})
+	if err != nil {
+		return analyzer.Snapshot{}, nil, err
+	}
+	functions := project.Functions()
 
-				// Memory check after scanning
-				runtime.ReadMemStats(&m)
-				log.Printf("Memory after external scanning: %.2f MB", float64(m.Alloc)/1024/1024)
+	log.Printf("Found %d functions in local repository", len(functions))
 
-				functions = append(functions, externalFunctions...)
-			}
+	// If include-external is true, scan external modules (same as CLI)
+	var externalFunctions []analyzer.FunctionInfo
+	if includeExternal {
+		log.Println("Scanning external modules...")
+		job.setPhase(analyzer.PhaseExternalScan)
+		if len(skipPatterns) > 0 {
+			log.Printf("Skipping external dependency folders matching: %v", skipPatterns)
 		}
 
-		// Add interface implementation detection for better call resolution
-		if !includeExternal {
-			log.Println("Detecting interface implementations...")
-			functions = analyzer.EnhanceProjectFunctionsWithTypeInfo(functions, abs)
+		// Add memory monitoring for large datasets
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		log.Printf("Memory before external scanning: %.2f MB", float64(m.Alloc)/1024/1024)
+
+		extFuncs, err := analyzer.ScanExternalModules(ctx, project, skipPatterns)
+		if ctx.Err() != nil {
+			return analyzer.Snapshot{}, nil, ctx.Err()
 		}
+		if err != nil {
+			log.Printf("Warning: failed to scan external modules: %v", err)
+		} else {
+			externalFunctions = extFuncs
+			log.Printf("Successfully scanned external modules and found %d external functions", len(externalFunctions))
 
-		// Optimize performance for large datasets with parallel processing
-		log.Printf("Processing %d total functions (including %d external)...", len(functions), len(externalFunctions))
+			// Memory check after scanning
+			runtime.ReadMemStats(&m)
+			log.Printf("Memory after external scanning: %.2f MB", float64(m.Alloc)/1024/1024)
 
-		// Build relations (parallelized for large datasets)
-		start := time.Now()
-		relations = buildRelationsParallel(functions, includeExternal)
-		log.Printf("Relation building completed in %v", time.Since(start))
+			functions = append(functions, externalFunctions...)
+		}
 	}
 
-	// stable sort by name then filePath
-	sort.Slice(relations, func(i, j int) bool {
-		if relations[i].Name == relations[j].Name {
-			return relations[i].FilePath < relations[j].FilePath
+	// Add interface implementation detection for better call resolution
+	if !includeExternal {
+		log.Println("Detecting interface implementations...")
+		job.setPhase(analyzer.PhaseTypeResolution)
+		if functions, err = analyzer.EnhanceProjectFunctionsWithTypeInfo(ctx, functions, project); err != nil {
+			return analyzer.Snapshot{}, nil, err
 		}
-		return relations[i].Name < relations[j].Name
-	})
+	}
 
-	// composite key function
-	ck := func(name, file string) string { return name + "|" + file }
+	log.Printf("Processing %d total functions (including %d external)...", len(functions), len(externalFunctions))
 
-	calledSet := make(map[string]bool)
-	idx := make(map[string]analyzer.OutRelation, len(relations))
-	internalPrefixes := []string{"analyzer.", "main.load", "main.findFunctions"}
-	isInternal := func(name string) bool {
-		for _, p := range internalPrefixes {
-			if strings.HasPrefix(name, p) {
-				return true
-			}
-		}
-		return false
-	}
-	for _, r := range relations {
-		for _, c := range r.Called {
-			calledSet[ck(c.Name, c.FilePath)] = true
-		}
-		idx[ck(r.Name, r.FilePath)] = r
+	// Build relations in parallel; only those of functions changed since the previous analysis, and of
+	// their callers, are rebuilt
+	job.setPhase(analyzer.PhaseBuildingRelations)
+	start := time.Now()
+	relations, err := fileCache.Relations(ctx, opts.workers, functions, includeExternal)
+	if err != nil {
+		return analyzer.Snapshot{}, nil, err
 	}
-	var roots []analyzer.OutRelation
-	for _, r := range relations {
-		if !calledSet[ck(r.Name, r.FilePath)] && !isInternal(r.Name) {
-			roots = append(roots, r)
+	log.Printf("Relation building completed in %v", time.Since(start))
+
+	if fileCache != nil {
+		hits, misses := fileCache.Stats()
+		reused, rebuilt := fileCache.RelationStats()
+		log.Printf("Analysis cache: %d results reused, %d recomputed; %d relations reused, %d rebuilt", hits, misses, reused, rebuilt)
+		if err := fileCache.Save(); err != nil {
+			log.Printf("Warning: failed to save analysis cache: %v", err)
 		}
 	}
-	sort.Slice(roots, func(i, j int) bool {
-		if roots[i].Name == roots[j].Name {
-			return roots[i].FilePath < roots[j].FilePath
-		}
-		return roots[i].Name < roots[j].Name
+
+	// NewSnapshot sorts relations by name, filePath and line
+	snapshot, err := analyzer.NewSnapshot(root, relations, len(functions), analyzer.SnapshotFlags{
+		IncludeExternal: includeExternal,
+		SkipPatterns:    skipPatterns,
 	})
+	if err != nil {
+		return analyzer.Snapshot{}, nil, err
+	}
+	snapshot.Header.Run = analyzer.NewSnapshotRun(root)
+	return snapshot, functions, nil
+}
 
-	// Use the functions array whether loaded from file or generated
+// install builds the call graph for a loaded or generated snapshot, classifies its roots and swaps it
+// into the cache. functions are the raw analysis results, nil for snapshots.
+func (r *repo) install(snapshot analyzer.Snapshot, functions []analyzer.FunctionInfo, source analyzer.DataSource) {
+	header, relations := snapshot.Header, snapshot.Relations
+	hash := header.Stats.ContentHash
+
+	// Relations are in canonical order, so node IDs are already sorted; tests come after them. Roots
+	// come from a binary snapshot classified the same way, or handlers are found in the analyzed
+	// revision's source; snapshot files have none.
+	nodes := relations
+	if tests := r.testRelations(relations, source); len(tests) > 0 {
+		nodes = append(relations[:len(relations):len(relations)], tests...)
+	}
+	graph := analyzer.NewGraph(nodes, r.entry.IsInternal)
+	roots, prebuilt := r.entry.PrebuiltRoots(snapshot)
+	if prebuilt {
+		roots = r.entry.WithTestRoots(graph, roots)
+	} else {
+		var read analyzer.SourceReader
+		if r.spec.Path != "" {
+			read = analyzer.DirReader(r.spec.Path)
+			if source.Ref != "" {
+				var err error
+				if read, err = analyzer.GitBatchReader(r.ctx, r.spec.Path, source.Commit, r.entry.SourceFiles(graph)); err != nil {
+					log.Printf("Warning: cannot read %s at %s, handlers are only found by rules: %v", r.spec.ID, source.Commit, err)
+					read = nil
+				}
+			}
+		}
+		roots = r.entry.Roots(graph, read)
+	}
 
-	global.mu.Lock()
-	global.functions = functions
-	global.relations = relations
-	global.index = idx
-	global.roots = roots
-	global.loadedAt = time.Now()
-	global.mu.Unlock()
+	r.data.mu.Lock()
+	r.data.functions = functions
+	r.data.relations = graph.Relations[:len(relations)]
+	r.data.graph = graph
+	r.data.roots = roots
+	r.data.hash = hash
+	r.data.header = header
+	r.data.source = source
+	r.data.loadedAt = time.Now()
+	r.data.loadErr = ""
+	loadedAt := r.data.loadedAt
+	r.data.mu.Unlock()
 
 	// Log statistics about the loaded data
-	log.Printf("Data loaded successfully:")
+	log.Printf("Data loaded successfully for %s:", r.spec.ID)
 	log.Printf("  - Total functions detected: %d", len(functions))
 	log.Printf("  - Total relations built: %d", len(relations))
-	log.Printf("  - Total root functions (entry points): %d", len(roots))
-	log.Printf("  - Total functions in index: %d", len(idx))
-	log.Printf("  - Data loaded at: %s", global.loadedAt.Format("2006-01-02 15:04:05"))
-
-	return nil
+	log.Printf("  - Total tests: %d", graph.Len()-len(relations))
+	log.Printf("  - Total root functions (entry points): %d (prebuilt: %t)", len(roots), prebuilt)
+	log.Printf("  - Total graph nodes: %d", graph.Len())
+	log.Printf("  - Content hash: %s", hash)
+	log.Printf("  - Source: %s (fresh: %t)", source.Kind, source.Fresh)
+	log.Printf("  - Data loaded at: %s", loadedAt.Format("2006-01-02 15:04:05"))
 }
 
-// buildRelationsParallel builds relations with parallel processing for large datasets
-func buildRelationsParallel(functions []analyzer.FunctionInfo, includeExternal bool) []analyzer.OutRelation {
-	// For small datasets, use the original sequential method
-	if len(functions) < 5000 {
-		return analyzer.BuildRelations(functions, includeExternal)
+// testRelations returns the relations of the repository's tests when test roots are listed. The analysis
+// skips _test.go files, so they are scanned here, and only in a working tree: refs and snapshot files
+// have none at hand.
+func (r *repo) testRelations(relations []analyzer.OutRelation, source analyzer.DataSource) []analyzer.OutRelation {
+	if r.spec.Path == "" || source.Ref != "" || !r.entry.Lists(analyzer.RootTest) {
+		return nil
 	}
-
-	// For large datasets, use parallel processing
-	numWorkers := runtime.NumCPU()
-	chunkSize := (len(functions) + numWorkers - 1) / numWorkers
-
-	// Create channels for results
-	type relationChunk struct {
-		relations []analyzer.OutRelation
-		index     int
+	module, _ := analyzer.GetModule(r.spec.Path)
+	index, err := analyzer.FindTests(r.ctx, r.spec.Path, module)
+	if err != nil {
+		log.Printf("Warning: cannot scan the tests of %s, none are listed as roots: %v", r.spec.ID, err)
+		return nil
 	}
-	resultChan := make(chan relationChunk, numWorkers)
-	var wg sync.WaitGroup
+	return index.Relations(relations)
+}
 
-	// Launch workers
-	for i := 0; i < numWorkers; i++ {
-		start := i * chunkSize
-		end := start + chunkSize
-		if end > len(functions) {
-			end = len(functions)
+// loadSnapshotFiles serves relation files without a source tree: functionmap.json documents (either
+// schema), bare relation arrays such as those downloaded from /api/download, or .bin snapshots.
+// Several files are merged into one graph.
+func (r *repo) loadSnapshotFiles() error {
+	paths := r.spec.SnapshotFiles
+	snapshots := make([]analyzer.Snapshot, 0, len(paths))
+	var legacy []string
+	for _, path := range paths {
+		start := time.Now()
+		snapshot, err := analyzer.LoadSnapshotFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
 		}
-		if start >= len(functions) {
-			break
+		log.Printf("Loaded %d relations from %s in %v", len(snapshot.Relations), path, time.Since(start))
+		if snapshot.Header.Migrated {
+			log.Printf("Warning: %s is a legacy relation array; its module and flags are unknown", path)
+			legacy = append(legacy, path)
 		}
-
-		wg.Add(1)
-		go func(start, end, workerIndex int) {
-			defer wg.Done()
-			chunkFunctions := functions[start:end]
-			chunkRelations := analyzer.BuildRelations(chunkFunctions, includeExternal)
-			resultChan <- relationChunk{relations: chunkRelations, index: workerIndex}
-		}(start, end, i)
+		snapshots = append(snapshots, snapshot)
+	}
+	reason := "serving snapshot files without a source tree"
+	if len(legacy) > 0 {
+		reason += "; legacy files record no module or flags: " + strings.Join(legacy, ", ")
 	}
 
-	// Close channel when all workers complete
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	// Collect results
-	var allRelations []analyzer.OutRelation
-	for chunk := range resultChan {
-		allRelations = append(allRelations, chunk.relations...)
+	merged, err := analyzer.MergeSnapshots(snapshots)
+	if err != nil {
+		return err
 	}
+	r.install(merged, nil, analyzer.DataSource{
+		Kind:        analyzer.SourceSnapshotFile,
+		Files:       paths,
+		Reason:      reason,
+		GeneratedAt: merged.Header.GeneratedAt(),
+	})
+	return nil
+}
 
-	return allRelations
+// watch submits a reload job whenever analysis inputs under the repository change; it runs until the
+// repository is removed
+func (r *repo) watch() {
+	abs, err := filepath.Abs(r.spec.Path)
+	if err != nil {
+		log.Printf("Watch disabled for %s: %v", r.spec.ID, err)
+		return
+	}
+	log.Printf("Watching %s for changes", abs)
+	err = analyzer.WatchTree(r.ctx, abs, r.watchOpts, func(changed []string) {
+		// A selected git ref does not change with the working tree
+		if ref := r.currentRef(); ref != "" {
+			log.Printf("Ignoring changes in %d file(s) of %s while serving %s", len(changed), r.spec.ID, ref)
+			return
+		}
+		job := r.jobs.submit("watch", changed)
+		log.Printf("Detected changes in %d file(s) of %s, reload %s: %s", len(changed), r.spec.ID, job.snapshot().ID, strings.Join(changed, ", "))
+	})
+	log.Printf("Watch of %s stopped: %v", r.spec.ID, err)
 }
 
 // Local interface-detection helper removed; server uses analyzer.EnhanceProjectFunctionsWithTypeInfo.
@@ -387,11 +737,13 @@ func buildRelationsParallel(functions []analyzer.FunctionInfo, includeExternal b
 // Legacy call filtering removed; server uses same behavior as CLI.
 
 // handleRelations returns paginated root relations with full dependency closure for each root on the page.
-// Query params: page (1-based), pageSize
-// Response: { page, pageSize, totalRoots, roots: [...root names...], data: [OutRelation ...] }
+// Query params: page (1-based), pageSize, kind (comma-separated root kinds, default all), includeInternals,
+// maxDepth and maxNodes (closure limits)
+// Response: { page, pageSize, totalRoots, roots: [...root names...], rootKinds, kindCounts, data: [OutRelation ...], truncated }
 func handleRelations(c *gin.Context) {
-	global.mu.RLock()
-	defer global.mu.RUnlock()
+	data := &repoOf(c).data
+	data.mu.RLock()
+	defer data.mu.RUnlock()
 	page := utils.ParseInt(c.Query("page"), 1)
 	pageSize := utils.ParseInt(c.Query("pageSize"), 10)
 	includeInternals := strings.EqualFold(c.Query("includeInternals"), "true")
@@ -401,8 +753,27 @@ func handleRelations(c *gin.Context) {
 	if pageSize <= 0 || pageSize > 200 {
 		pageSize = 10
 	}
+	var kinds []string
+	if kind := strings.TrimSpace(c.Query("kind")); kind != "" {
+		for _, k := range strings.Split(kind, ",") {
+			k = strings.TrimSpace(k)
+			if !slices.Contains(analyzer.RootKinds, k) {
+				c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: fmt.Sprintf("invalid kind %q: want one of %s", k, strings.Join(analyzer.RootKinds, ", "))})
+				return
+			}
+			kinds = append(kinds, k)
+		}
+	}
 
-	totalRoots := len(global.roots)
+	kindCounts := make(map[string]int)
+	var roots []analyzer.Root
+	for _, root := range data.roots {
+		kindCounts[root.Kind]++
+		if kinds == nil || slices.Contains(kinds, root.Kind) {
+			roots = append(roots, root)
+		}
+	}
+	totalRoots := len(roots)
 	start := (page - 1) * pageSize
 	if start > totalRoots {
 		start = totalRoots
@@ -411,68 +782,45 @@ func handleRelations(c *gin.Context) {
 	if end > totalRoots {
 		end = totalRoots
 	}
-	selectedRoots := global.roots[start:end]
-
-	// Collect dependency closure using composite keys
-	closureMap := make(map[string]analyzer.OutRelation)
-	ck := func(name, file string) string { return name + "|" + file }
-	var collect func(name, file string)
-	collect = func(name, file string) {
-		k := ck(name, file)
-		if _, exists := closureMap[k]; exists {
-			return
-		}
-		rel, ok := global.index[k]
-		if !ok {
-			return
-		}
-		if !includeInternals && (strings.HasPrefix(name, "analyzer.") || name == "main.findFunctions" || name == "main.load") {
-			for _, c := range rel.Called {
-				collect(c.Name, c.FilePath)
-			}
-			return
-		}
-		closureMap[k] = rel
-		for _, c := range rel.Called {
-			collect(c.Name, c.FilePath)
-		}
+	selectedRoots := make([]analyzer.NodeID, 0, end-start)
+	rootKinds := make([]string, 0, end-start)
+	for _, root := range roots[start:end] {
+		selectedRoots = append(selectedRoots, root.ID)
+		rootKinds = append(rootKinds, root.Kind)
 	}
-	for _, r := range selectedRoots {
-		collect(r.Name, r.FilePath)
-	}
-
-	var closure []analyzer.OutRelation
-	for _, v := range closureMap {
-		closure = append(closure, v)
-	}
-	sort.Slice(closure, func(i, j int) bool {
-		if closure[i].Name == closure[j].Name {
-			return closure[i].FilePath < closure[j].FilePath
-		}
-		return closure[i].Name < closure[j].Name
-	})
-
-	c.JSON(http.StatusOK, gin.H{
-		"page":             page,
-		"pageSize":         pageSize,
-		"totalRoots":       totalRoots,
-		"roots":            selectedRoots,
-		"data":             closure,
-		"loadedAt":         global.loadedAt,
-		"includeInternals": includeInternals,
+	limits := closureLimits(c)
+	closure, truncated := data.graph.BoundedClosure(selectedRoots, includeInternals, limits)
+
+	c.JSON(http.StatusOK, analyzer.RelationsResponse{
+		Page:             page,
+		PageSize:         pageSize,
+		Kinds:            kinds,
+		TotalRoots:       totalRoots,
+		KindCounts:       kindCounts,
+		Roots:            data.graph.RelationsOf(selectedRoots),
+		RootKinds:        rootKinds,
+		Data:             data.graph.RelationsOf(closure),
+		MaxDepth:         limits.MaxDepth,
+		MaxNodes:         limits.MaxNodes,
+		Truncated:        data.graph.FunctionRefs(truncated),
+		LoadedAt:         data.loadedAt,
+		ContentHash:      data.hash,
+		Source:           data.source,
+		IncludeInternals: includeInternals,
 	})
 }
 
 // handleSearch searches for functions by name and returns their dependency closure with pagination
-// Query params: q (search query), page (1-based), pageSize
-// Response: { query, page, pageSize, totalResults, matchingFunctions: [...], data: [OutRelation ...] }
+// Query params: q (search query), page (1-based), pageSize, maxDepth and maxNodes (closure limits)
+// Response: { query, page, pageSize, totalResults, matchingFunctions: [...], data: [OutRelation ...], truncated }
 func handleSearch(c *gin.Context) {
-	global.mu.RLock()
-	defer global.mu.RUnlock()
+	data := &repoOf(c).data
+	data.mu.RLock()
+	defer data.mu.RUnlock()
 
 	query := strings.TrimSpace(c.Query("q"))
 	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "search query 'q' is required"})
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "search query 'q' is required"})
 		return
 	}
 
@@ -489,33 +837,9 @@ func handleSearch(c *gin.Context) {
 	// Convert query to lowercase for case-insensitive search
 	lowerQuery := strings.ToLower(query)
 
-	// First, try to find functions by name only (prioritized search)
-	var matchingFunctions []analyzer.OutRelation
-	for _, rel := range global.relations {
-		lowerName := strings.ToLower(rel.Name)
-		if strings.Contains(lowerName, lowerQuery) {
-			matchingFunctions = append(matchingFunctions, rel)
-		}
-	}
-
-	// If no results found in function names, search in both function names and file paths
-	if len(matchingFunctions) == 0 {
-		for _, rel := range global.relations {
-			lowerName := strings.ToLower(rel.Name)
-			lowerPath := strings.ToLower(rel.FilePath)
-			if strings.Contains(lowerName, lowerQuery) || strings.Contains(lowerPath, lowerQuery) {
-				matchingFunctions = append(matchingFunctions, rel)
-			}
-		}
-	}
-
-	// Sort matching functions for consistent pagination
-	sort.Slice(matchingFunctions, func(i, j int) bool {
-		if matchingFunctions[i].Name == matchingFunctions[j].Name {
-			return matchingFunctions[i].FilePath < matchingFunctions[j].FilePath
-		}
-		return matchingFunctions[i].Name < matchingFunctions[j].Name
-	})
+	// Names are searched first; file paths only when no name matches. Node IDs are in canonical
+	// order, so matches need no sorting for consistent pagination.
+	matchingFunctions := data.graph.Search(lowerQuery)
 
 	// Apply pagination to matching functions
 	totalResults := len(matchingFunctions)
@@ -529,72 +853,336 @@ func handleSearch(c *gin.Context) {
 	}
 	paginatedMatches := matchingFunctions[start:end]
 
-	// Build dependency closure for paginated matching functions
-	closureMap := make(map[string]analyzer.OutRelation)
-	ck := func(name, file string) string { return name + "|" + file }
+	// Dependency closure of the paginated matches, excluding internal functions
+	limits := closureLimits(c)
+	closure, truncated := data.graph.BoundedClosure(paginatedMatches, false, limits)
+
+	c.JSON(http.StatusOK, analyzer.SearchResponse{
+		Query:             query,
+		Page:              page,
+		PageSize:          pageSize,
+		TotalResults:      totalResults,
+		MatchingFunctions: data.graph.RelationsOf(paginatedMatches),
+		Data:              data.graph.RelationsOf(closure),
+		MaxDepth:          limits.MaxDepth,
+		MaxNodes:          limits.MaxNodes,
+		Truncated:         data.graph.FunctionRefs(truncated),
+		LoadedAt:          data.loadedAt,
+		ContentHash:       data.hash,
+		Source:            data.source,
+	})
+}
 
-	var collect func(name, file string)
-	collect = func(name, file string) {
-		k := ck(name, file)
-		if _, exists := closureMap[k]; exists {
-			return
-		}
-		rel, ok := global.index[k]
-		if !ok {
-			return
-		}
-		// Exclude internal functions from search results
-		if strings.HasPrefix(name, "analyzer.") || name == "main.findFunctions" || name == "main.load" {
-			for _, c := range rel.Called {
-				collect(c.Name, c.FilePath)
-			}
-			return
-		}
-		closureMap[k] = rel
-		for _, c := range rel.Called {
-			collect(c.Name, c.FilePath)
-		}
+// handleExpand returns one more level below a node: the function and its direct callees, each marked
+// truncated when it calls further. Clients use it to load the frontier of a limited closure lazily.
+// Query params: id (name@filePath as listed in truncated, or a name), includeInternals, maxNodes
+func handleExpand(c *gin.Context) {
+	data := &repoOf(c).data
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	ref := strings.TrimSpace(c.Query("id"))
+	if ref == "" {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "function 'id' is required: a name or name@filePath"})
+		return
 	}
-
-	// Collect closure for each paginated matching function
-	for _, match := range paginatedMatches {
-		collect(match.Name, match.FilePath)
+	fn, id, ok := data.graph.Function(ref)
+	if !ok {
+		c.JSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown function " + ref})
+		return
 	}
+	includeInternals := strings.EqualFold(c.Query("includeInternals"), "true")
 
-	// Convert to slice and sort
-	var closure []analyzer.OutRelation
-	for _, v := range closureMap {
-		closure = append(closure, v)
+	// A function without calls of its own has no node and nothing below it
+	var closure, truncated []analyzer.NodeID
+	if id >= 0 {
+		limits := closureLimits(c)
+		limits.MaxDepth = 1
+		closure, truncated = data.graph.BoundedClosure([]analyzer.NodeID{id}, includeInternals, limits)
 	}
-	sort.Slice(closure, func(i, j int) bool {
-		if closure[i].Name == closure[j].Name {
-			return closure[i].FilePath < closure[j].FilePath
-		}
-		return closure[i].Name < closure[j].Name
-	})
 
-	c.JSON(http.StatusOK, gin.H{
-		"query":             query,
-		"page":              page,
-		"pageSize":          pageSize,
-		"totalResults":      totalResults,
-		"matchingFunctions": paginatedMatches,
-		"data":              closure,
-		"loadedAt":          global.loadedAt,
+	c.JSON(http.StatusOK, analyzer.ExpandResponse{
+		Function:         fn,
+		Data:             data.graph.RelationsOf(closure),
+		Truncated:        data.graph.FunctionRefs(truncated),
+		LoadedAt:         data.loadedAt,
+		ContentHash:      data.hash,
+		Source:           data.source,
+		IncludeInternals: includeInternals,
 	})
 }
 
 // Helpers --------------------------------------------------------------------------------
 
+// closureLimits reads the maxDepth and maxNodes query params; missing or non-positive values mean no limit
+func closureLimits(c *gin.Context) analyzer.ClosureLimits {
+	limits := analyzer.ClosureLimits{
+		MaxDepth: utils.ParseInt(c.Query("maxDepth"), 0),
+		MaxNodes: utils.ParseInt(c.Query("maxNodes"), 0),
+	}
+	if limits.MaxDepth < 0 {
+		limits.MaxDepth = 0
+	}
+	if limits.MaxNodes < 0 {
+		limits.MaxNodes = 0
+	}
+	return limits
+}
+
 // Simplified duplicate of CLI findFunctions (cannot import from main package) ----------------------------------------
 // Duplicated helper functions removed in favor of shared analyzer helpers.
 
-// Basic CORS middleware for Gin
+// handleDownload streams the relations as a versioned snapshot document in chunks, so memory stays
+// constant regardless of graph size. install replaces (never mutates) the slice, so it is safe to keep
+// encoding it after releasing the lock.
+func handleDownload(c *gin.Context) {
+	data := &repoOf(c).data
+	data.mu.RLock()
+	snapshot := analyzer.Snapshot{Header: data.header, Relations: data.relations}
+	data.mu.RUnlock()
+	c.Header("Content-Type", "application/json")
+	c.Header("Content-Disposition", "attachment; filename=function_relations.json")
+	c.Header("X-Content-Hash", snapshot.Header.Stats.ContentHash)
+	c.Status(http.StatusOK)
+	if err := analyzer.WriteSnapshotStream(c.Writer, snapshot, downloadFlushEvery); err != nil {
+		log.Printf("Download aborted: %v", err)
+	}
+}
+
+// requireSource rejects endpoints that rescan or read the source tree when there is none
+func requireSource(c *gin.Context) {
+	if repoOf(c).readOnly() {
+		c.AbortWithStatusJSON(http.StatusForbidden, analyzer.ErrorResponse{Error: "read-only: the repository is served from snapshot files without a source tree"})
+		return
+	}
+	c.Next()
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// handleReload queues a rescan and answers 202 with the job at once. Requests made while a job is waiting
+// to run join it. With ?wait=true the response is held until the job finishes, as before jobs existed.
+func handleReload(c *gin.Context) {
+	r := repoOf(c)
+	job := r.jobs.submit("reload", nil)
+	state := job.snapshot()
+	log.Printf("Reload of %s requested: %s (%s)", r.spec.ID, state.ID, state.Status)
+	respondWithJob(c, job)
+}
+
+// respondWithJob answers a request that queued job: 202 with the job, or with ?wait=true the finished job
+func respondWithJob(c *gin.Context, job *reloadJob) {
+	state := job.snapshot()
+	// The job lives next to the requested endpoint, with or without the /api/repos/{id} prefix
+	c.Header("Location", path.Dir(c.Request.URL.Path)+"/jobs/"+state.ID)
+
+	if c.Query("wait") != "true" {
+		c.JSON(http.StatusAccepted, state)
+		return
+	}
+	select {
+	case <-job.done:
+	case <-c.Request.Context().Done():
+		// The client gave up waiting; the job keeps running for everyone else that joined it
+		return
+	}
+	state = job.snapshot()
+	if state.Status != analyzer.JobSucceeded {
+		c.JSON(http.StatusInternalServerError, analyzer.ErrorResponse{Error: state.Error})
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// handleRefs lists the branches and tags of the repository's git repository with the selected ref
+func handleRefs(c *gin.Context) {
+	r := repoOf(c)
+	refs, err := analyzer.GitRefs(c.Request.Context(), r.spec.Path)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, analyzer.ErrorResponse{Error: err.Error()})
+		return
+	}
+	r.data.mu.RLock()
+	commit := r.data.source.Commit
+	r.data.mu.RUnlock()
+	c.JSON(http.StatusOK, analyzer.RefsResponse{Ref: r.currentRef(), Commit: commit, Refs: refs})
+}
+
+// handleSelectRef switches the repository to a git commit, branch or tag ({"ref": ""} returns to the
+// working tree) and queues the reload that loads it, answering like POST /reload. The ref is resolved
+// first so an unknown one answers 400 instead of queueing a job bound to fail.
+func handleSelectRef(c *gin.Context) {
+	r := repoOf(c)
+	var req analyzer.RefRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "invalid ref request: " + err.Error()})
+		return
+	}
+	ref := strings.TrimSpace(req.Ref)
+	if ref != "" {
+		if _, err := analyzer.ResolveGitRef(c.Request.Context(), r.spec.Path, ref); err != nil {
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	r.setRef(ref)
+	job := r.jobs.submit("ref", nil)
+	state := job.snapshot()
+	log.Printf("Switch of %s to %q requested: %s (%s)", r.spec.ID, ref, state.ID, state.Status)
+	respondWithJob(c, job)
+}
+
+// handleJobs lists recent reload jobs, newest first
+func handleJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, analyzer.JobsResponse{Jobs: repoOf(c).jobs.list()})
+}
+
+// handleJob reports one reload job's status, phase and progress
+func handleJob(c *gin.Context) {
+	job := repoOf(c).jobs.get(c.Param("job"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown job " + c.Param("job")})
+		return
+	}
+	c.JSON(http.StatusOK, job.snapshot())
+}
+
+// handleCancelJob cancels a queued or running reload job; the previously loaded data stays in place
+func handleCancelJob(c *gin.Context) {
+	job, ok := repoOf(c).jobs.cancel(c.Param("job"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown job " + c.Param("job")})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusConflict, analyzer.ErrorResponse{Error: "job " + c.Param("job") + " has already finished"})
+		return
+	}
+	c.JSON(http.StatusAccepted, job.snapshot())
+}
+
+// sseHeartbeat is how often an idle /api/events stream sends a comment to keep proxies from closing it
+const sseHeartbeat = 25 * time.Second
+
+// changeHub fans a repository's change events out to every /events subscriber
+type changeHub struct {
+	mu     sync.Mutex
+	subs   map[chan analyzer.ChangeEvent]struct{}
+	closed bool
+}
+
+func newChangeHub() *changeHub {
+	return &changeHub{subs: make(map[chan analyzer.ChangeEvent]struct{})}
+}
+
+// subscribe returns a channel of change events; it is closed at once when the hub already is
+func (h *changeHub) subscribe() chan analyzer.ChangeEvent {
+	ch := make(chan analyzer.ChangeEvent, 8)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	h.subs[ch] = struct{}{}
+	return ch
+}
+
+func (h *changeHub) unsubscribe(ch chan analyzer.ChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// close ends every subscription, for a repository that is no longer hosted
+func (h *changeHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// publish never blocks: a subscriber that fell behind loses its oldest event, so the newest always arrives
+func (h *changeHub) publish(event analyzer.ChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+	}
+}
+
+// handleEvents streams server-sent events: "ready" with the currently loaded data on connect, then
+// "change" after every reload that changed the relations
+func handleEvents(c *gin.Context) {
+	r := repoOf(c)
+	ch := r.events.subscribe()
+	defer r.events.unsubscribe(ch)
+
+	r.data.mu.RLock()
+	ready := analyzer.ChangeEvent{Reason: "connected", ContentHash: r.data.hash, LoadedAt: r.data.loadedAt}
+	r.data.mu.RUnlock()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("ready", ready)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				// The repository was removed
+				return false
+			}
+			c.SSEvent("change", event)
+		case <-heartbeat.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
+
+// corsMiddleware lets any origin use the API, as the React dev server does, except the routes that add,
+// remove or upload repositories: those get no CORS headers, so browsers refuse them from other origins.
+// Preflights are judged by the method they announce.
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type")
+		method := c.Request.Method
+		if method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			method = c.GetHeader("Access-Control-Request-Method")
+		}
+		if !isAdminRoute(method, c.Request.URL.Path) {
+			c.Header("Access-Control-Allow-Origin", "*")
+			c.Header("Access-Control-Allow-Methods", "GET,HEAD,POST,DELETE,OPTIONS")
+			c.Header("Access-Control-Expose-Headers", "Location, X-Content-Hash")
+			c.Header("Access-Control-Allow-Headers", "Content-Type")
+		}
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusOK)
 			return
@@ -602,3 +1190,17 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// isAdminRoute reports whether a request adds, removes or uploads a repository
+func isAdminRoute(method, urlPath string) bool {
+	switch {
+	case urlPath == "/api/analyze":
+		return true
+	case urlPath == "/api/repos":
+		return method == http.MethodPost
+	case strings.HasPrefix(urlPath, "/api/repos/"):
+		id := strings.Trim(strings.TrimPrefix(urlPath, "/api/repos/"), "/")
+		return method == http.MethodDelete && id != "" && !strings.Contains(id, "/")
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/chinmay-sawant/gomindmapper/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// handleNeighborhood returns the subgraph around a function: every function within up caller hops and down
+// callee hops of it, with the calls between them marked by the direction they were walked in.
+// Query params: id (function name, or name@filePath when the name is declared in several files), up
+// (caller hops, default 1), down (callee hops, default 1), includeInternals
+func handleNeighborhood(c *gin.Context) {
+	data := &repoOf(c).data
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	ref := strings.TrimSpace(c.Query("id"))
+	if ref == "" {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "function 'id' is required: a name or name@filePath"})
+		return
+	}
+	up := utils.ParseInt(c.Query("up"), 1)
+	down := utils.ParseInt(c.Query("down"), 1)
+	if up < 0 || up > analyzer.MaxNeighborhoodHops || down < 0 || down > analyzer.MaxNeighborhoodHops {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: fmt.Sprintf("'up' and 'down' must be between 0 and %d", analyzer.MaxNeighborhoodHops)})
+		return
+	}
+	fn, _, ok := data.graph.Function(ref)
+	if !ok {
+		c.JSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown function " + ref})
+		return
+	}
+	includeInternals := strings.EqualFold(c.Query("includeInternals"), "true")
+
+	nodes, edges := data.graph.Neighborhood(fn, up, down, includeInternals)
+	c.JSON(http.StatusOK, analyzer.NeighborhoodResponse{
+		Function:         fn,
+		Up:               up,
+		Down:             down,
+		Nodes:            nodes,
+		Edges:            edges,
+		LoadedAt:         data.loadedAt,
+		ContentHash:      data.hash,
+		Source:           data.source,
+		IncludeInternals: includeInternals,
+	})
+}
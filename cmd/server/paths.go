@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/chinmay-sawant/gomindmapper/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// handlePaths returns the shortest call path, or the k shortest simple paths, from one function to
+// another, with the line of each call when the repository has a source tree.
+// Query params: from, to (function names, or name@filePath), k (default 1, at most analyzer.MaxPaths),
+// format=text for the plain-text summary
+func handlePaths(c *gin.Context) {
+	r := repoOf(c)
+	fromRef, toRef := strings.TrimSpace(c.Query("from")), strings.TrimSpace(c.Query("to"))
+	if fromRef == "" || toRef == "" {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "'from' and 'to' are required: function names or name@filePath"})
+		return
+	}
+	k := utils.ParseInt(c.Query("k"), 1)
+	if k < 1 || k > analyzer.MaxPaths {
+		c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: fmt.Sprintf("'k' must be between 1 and %d", analyzer.MaxPaths)})
+		return
+	}
+
+	// Graphs are immutable once installed, so the search runs without holding the cache lock
+	r.data.mu.RLock()
+	graph, commit := r.data.graph, r.data.source.Commit
+	r.data.mu.RUnlock()
+	from, fromID, ok := graph.Function(fromRef)
+	if !ok {
+		c.JSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown function " + fromRef})
+		return
+	}
+	to, _, ok := graph.Function(toRef)
+	if !ok {
+		c.JSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown function " + toRef})
+		return
+	}
+
+	result := analyzer.PathsResponse{From: from, To: to, K: k, Paths: []analyzer.CallPath{}}
+	if fromID >= 0 {
+		result.Paths = graph.ShortestPaths(fromID, to, k)
+	}
+	if !r.readOnly() {
+		if abs, err := filepath.Abs(r.spec.Path); err == nil {
+			read := analyzer.DirReader(abs)
+			if r.currentRef() != "" {
+				read = analyzer.GitFileReader(c.Request.Context(), abs, commit)
+			}
+			analyzer.AddCallLines(result.Paths, read)
+		}
+	}
+
+	if c.Query("format") == "text" {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Status(http.StatusOK)
+		result.WriteSummary(c.Writer)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
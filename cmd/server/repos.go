@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/gin-gonic/gin"
+)
+
+// repo is one repository (or set of snapshot files) hosted by the server, with its own cache, reload
+// jobs and change stream
+type repo struct {
+	spec      analyzer.RepoSpec // as configured, with absolute paths
+	entry     *analyzer.RootClassifier
+	opts      loadOptions
+	watchOpts analyzer.WatchOptions
+	data      cache
+	jobs      *jobManager
+	events    *changeHub
+
+	ctx     context.Context // cancelled when the repository is removed
+	stop    context.CancelFunc
+	tempDir string // unpacked upload owned by the repository, deleted with it; "" otherwise
+
+	refMu sync.Mutex
+	ref   string // git ref the next load analyzes, "" for the working tree
+}
+
+// currentRef returns the git ref loads analyze, "" for the working tree
+func (r *repo) currentRef() string {
+	r.refMu.Lock()
+	defer r.refMu.Unlock()
+	return r.ref
+}
+
+// setRef selects the git ref the next load analyzes
+func (r *repo) setRef(ref string) {
+	r.refMu.Lock()
+	r.ref = ref
+	r.refMu.Unlock()
+}
+
+// readOnly reports a repository served from snapshot files, which has no source tree to rescan
+func (r *repo) readOnly() bool {
+	return len(r.spec.SnapshotFiles) > 0
+}
+
+// repoIDPattern keeps IDs usable as a URL path segment
+var repoIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// newRepo validates spec and prepares a repository without loading it. base carries the process-wide
+// analysis settings (cache, workers); the per-repository ones come from spec. Relative paths are
+// resolved against dir.
+func newRepo(spec analyzer.RepoSpec, dir string, base loadOptions, watchOpts analyzer.WatchOptions) (*repo, error) {
+	if !repoIDPattern.MatchString(spec.ID) {
+		return nil, fmt.Errorf("invalid repository id %q: use letters, digits, '.', '_' and '-'", spec.ID)
+	}
+	if (spec.Path == "") == (len(spec.SnapshotFiles) == 0) {
+		return nil, fmt.Errorf("repository %s: set exactly one of path and snapshotFiles", spec.ID)
+	}
+	if spec.Snapshot == "" {
+		spec.Snapshot = snapshotPrefer
+	}
+	switch spec.Snapshot {
+	case snapshotPrefer, snapshotIgnore, snapshotOnly:
+	default:
+		return nil, fmt.Errorf("repository %s: invalid snapshot mode %q: want prefer, ignore or only", spec.ID, spec.Snapshot)
+	}
+	if spec.Watch && (spec.Snapshot == snapshotOnly || len(spec.SnapshotFiles) > 0) {
+		return nil, fmt.Errorf("repository %s: watch needs a source tree that is reanalyzed", spec.ID)
+	}
+	if spec.Ref != "" && len(spec.SnapshotFiles) > 0 {
+		return nil, fmt.Errorf("repository %s: ref needs a git repository, not snapshot files", spec.ID)
+	}
+	var entryPoints analyzer.EntryPoints
+	if spec.EntryPoints != nil {
+		entryPoints = *spec.EntryPoints
+	}
+	entry, err := analyzer.NewRootClassifier(entryPoints)
+	if err != nil {
+		return nil, fmt.Errorf("repository %s: entryPoints: %w", spec.ID, err)
+	}
+
+	resolve := func(path string) string {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		return filepath.Clean(path)
+	}
+	if spec.Path != "" {
+		spec.Path = resolve(spec.Path)
+		if stat, err := os.Stat(spec.Path); err != nil || !stat.IsDir() {
+			return nil, fmt.Errorf("repository %s: %s is not a directory", spec.ID, spec.Path)
+		}
+	}
+	files := make([]string, len(spec.SnapshotFiles))
+	for i, file := range spec.SnapshotFiles {
+		files[i] = resolve(file)
+	}
+	spec.SnapshotFiles = files
+	if len(files) == 0 {
+		spec.SnapshotFiles = nil
+	}
+
+	opts := base
+	opts.includeExternal, opts.skipPatterns, opts.snapshot = spec.IncludeExternal, spec.SkipFolders, spec.Snapshot
+	ctx, stop := context.WithCancel(context.Background())
+	r := &repo{spec: spec, entry: entry, opts: opts, watchOpts: watchOpts, events: newChangeHub(), ctx: ctx, stop: stop, ref: spec.Ref}
+	r.jobs = newJobManager(r.runReload)
+	return r, nil
+}
+
+// open loads the repository in the background as its first job, which it returns, and starts
+// watching it if configured
+func (r *repo) open() *reloadJob {
+	job := r.jobs.submit("initial", nil)
+	if r.spec.Watch {
+		go r.watch()
+	}
+	return job
+}
+
+// close stops the watcher, cancels pending reloads and ends every /events stream. An uploaded tree is
+// deleted once no scan reads it anymore.
+func (r *repo) close() {
+	r.stop()
+	cancelled := r.jobs.cancelAll()
+	r.events.close()
+	if r.tempDir != "" {
+		go func() {
+			for _, job := range cancelled {
+				<-job.done
+			}
+			if err := os.RemoveAll(r.tempDir); err != nil {
+				log.Printf("Warning: failed to remove %s: %v", r.tempDir, err)
+			}
+		}()
+	}
+}
+
+// info describes the repository for the /api/repos endpoints
+func (r *repo) info() analyzer.RepoInfo {
+	info := analyzer.RepoInfo{
+		ID:            r.spec.ID,
+		Path:          r.spec.Path,
+		SnapshotFiles: r.spec.SnapshotFiles,
+		Default:       repos.first() == r,
+		ReadOnly:      r.readOnly(),
+		Uploaded:      r.tempDir != "",
+		Watch:         r.spec.Watch,
+		Ref:           r.currentRef(),
+		Status:        analyzer.RepoLoading,
+	}
+	r.data.mu.RLock()
+	defer r.data.mu.RUnlock()
+	info.Error = r.data.loadErr
+	if r.data.graph == nil {
+		if info.Error != "" {
+			info.Status = analyzer.RepoFailed
+		}
+		return info
+	}
+	loadedAt, source, stats := r.data.loadedAt, r.data.source, r.data.header.Stats
+	info.Status = analyzer.RepoReady
+	info.ContentHash = r.data.hash
+	info.LoadedAt, info.Source, info.Stats = &loadedAt, &source, &stats
+	return info
+}
+
+// registry holds the hosted repositories
+type registry struct {
+	mu    sync.RWMutex
+	repos map[string]*repo
+	order []string // IDs in registration order
+}
+
+var repos = registry{repos: make(map[string]*repo)}
+
+func (g *registry) add(r *repo) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.repos[r.spec.ID] != nil {
+		return fmt.Errorf("repository %s already exists", r.spec.ID)
+	}
+	g.repos[r.spec.ID] = r
+	g.order = append(g.order, r.spec.ID)
+	return nil
+}
+
+func (g *registry) get(id string) *repo {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.repos[id]
+}
+
+// first returns the repository served by the unprefixed /api routes, nil when there is none
+func (g *registry) first() *repo {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if len(g.order) == 0 {
+		return nil
+	}
+	return g.repos[g.order[0]]
+}
+
+func (g *registry) remove(id string) *repo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.removeLocked(id)
+}
+
+func (g *registry) removeLocked(id string) *repo {
+	r := g.repos[id]
+	if r == nil {
+		return nil
+	}
+	delete(g.repos, id)
+	for i, existing := range g.order {
+		if existing == id {
+			g.order = append(g.order[:i:i], g.order[i+1:]...)
+			break
+		}
+	}
+	return r
+}
+
+// removeUnlessDefault removes r unless it serves the unprefixed /api routes or was removed already
+func (g *registry) removeUnlessDefault(r *repo) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.repos[r.spec.ID] != r {
+		return fmt.Errorf("repository %s was removed already", r.spec.ID)
+	}
+	if g.order[0] == r.spec.ID {
+		return fmt.Errorf("repository %s is the default repository served by the unprefixed /api routes and cannot be removed", r.spec.ID)
+	}
+	g.removeLocked(r.spec.ID)
+	return nil
+}
+
+func (g *registry) list() []*repo {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	list := make([]*repo, len(g.order))
+	for i, id := range g.order {
+		list[i] = g.repos[id]
+	}
+	return list
+}
+
+// decodeRepoSpec reads one repository entry; fields it omits keep the values in defaults
+func decodeRepoSpec(data []byte, defaults analyzer.RepoSpec) (analyzer.RepoSpec, error) {
+	spec := defaults
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return analyzer.RepoSpec{}, err
+	}
+	return spec, nil
+}
+
+// loadReposConfig reads the -config file. Relative paths in it are resolved against its directory.
+func loadReposConfig(path string, defaults analyzer.RepoSpec, base loadOptions, watchOpts analyzer.WatchOptions) ([]*repo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Repos []json.RawMessage `json:"repos"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(config.Repos) == 0 {
+		return nil, fmt.Errorf("%s: no repositories configured", path)
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*repo
+	seen := make(map[string]bool)
+	for i, raw := range config.Repos {
+		spec, err := decodeRepoSpec(raw, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("%s: repository %d: %w", path, i+1, err)
+		}
+		if seen[spec.ID] {
+			return nil, fmt.Errorf("%s: duplicate repository id %q", path, spec.ID)
+		}
+		seen[spec.ID] = true
+		r, err := newRepo(spec, dir, base, watchOpts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+// repoKey is the gin context key of the repository a request addresses
+const repoKey = "repo"
+
+// repoOf returns the repository selected by withDefaultRepo or withNamedRepo
+func repoOf(c *gin.Context) *repo {
+	return c.MustGet(repoKey).(*repo)
+}
+
+// withDefaultRepo routes the unprefixed /api endpoints to the first repository
+func withDefaultRepo(c *gin.Context) {
+	r := repos.first()
+	if r == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "no repositories are hosted; add one with POST /api/repos"})
+		return
+	}
+	c.Set(repoKey, r)
+	c.Next()
+}
+
+// withNamedRepo routes /api/repos/{id}/... to that repository
+func withNamedRepo(c *gin.Context) {
+	r := repos.get(c.Param("id"))
+	if r == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown repository " + c.Param("id")})
+		return
+	}
+	c.Set(repoKey, r)
+	c.Next()
+}
+
+// requireData answers 503 until the repository's first load has succeeded
+func requireData(c *gin.Context) {
+	r := repoOf(c)
+	r.data.mu.RLock()
+	loaded, loadErr := r.data.graph != nil, r.data.loadErr
+	r.data.mu.RUnlock()
+	if !loaded {
+		message := "repository " + r.spec.ID + " is still loading"
+		if loadErr != "" {
+			message = "repository " + r.spec.ID + " failed to load: " + loadErr
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, analyzer.ErrorResponse{Error: message})
+		return
+	}
+	c.Next()
+}
+
+// handleListRepos lists the hosted repositories
+func handleListRepos(c *gin.Context) {
+	list := repos.list()
+	infos := make([]analyzer.RepoInfo, len(list))
+	for i, r := range list {
+		infos[i] = r.info()
+	}
+	c.JSON(http.StatusOK, analyzer.ReposResponse{Repos: infos})
+}
+
+// handleGetRepo reports one repository's status
+func handleGetRepo(c *gin.Context) {
+	c.JSON(http.StatusOK, repoOf(c).info())
+}
+
+// repoAdmin guards the endpoints that add and remove hosted repositories, which can point the server at
+// any directory it can read
+type repoAdmin struct {
+	enabled bool     // POST /api/repos and DELETE of configured repositories are allowed (-manage-repos)
+	roots   []string // directories added repositories must lie in, symlinks resolved; none allows any (-repo-root)
+}
+
+// newRepoAdmin resolves roots to absolute paths without symlinks
+func newRepoAdmin(enabled bool, roots []string) (repoAdmin, error) {
+	admin := repoAdmin{enabled: enabled}
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err == nil {
+			abs, err = filepath.EvalSymlinks(abs)
+		}
+		if err != nil {
+			return repoAdmin{}, fmt.Errorf("repository root %s: %w", root, err)
+		}
+		admin.roots = append(admin.roots, abs)
+	}
+	return admin, nil
+}
+
+// allows reports whether path, after resolving symlinks, lies inside one of the roots. Paths that do not
+// exist are refused when roots are configured.
+func (a repoAdmin) allows(path string) bool {
+	if len(a.roots) == 0 {
+		return true
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	for _, root := range a.roots {
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAddRepo registers a repository from a RepoSpec body and answers 202 while it loads in the
+// background. Relative paths are resolved against the server's working directory. The body must be
+// JSON, so browsers preflight cross-origin requests, which CORS then refuses.
+func handleAddRepo(defaults analyzer.RepoSpec, base loadOptions, watchOpts analyzer.WatchOptions, admin repoAdmin) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !admin.enabled {
+			c.JSON(http.StatusForbidden, analyzer.ErrorResponse{Error: "adding repositories is disabled; start the server with -manage-repos"})
+			return
+		}
+		if c.ContentType() != "application/json" {
+			c.JSON(http.StatusUnsupportedMediaType, analyzer.ErrorResponse{Error: "send the repository as Content-Type: application/json"})
+			return
+		}
+		body, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		spec, err := decodeRepoSpec(body, defaults)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "invalid repository: " + err.Error()})
+			return
+		}
+		r, err := newRepo(spec, ".", base, watchOpts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		for _, path := range append([]string{r.spec.Path}, r.spec.SnapshotFiles...) {
+			if path != "" && !admin.allows(path) {
+				r.close()
+				c.JSON(http.StatusForbidden, analyzer.ErrorResponse{Error: path + " is outside the repository roots the server allows (-repo-root)"})
+				return
+			}
+		}
+		if err := repos.add(r); err != nil {
+			r.close()
+			c.JSON(http.StatusConflict, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		log.Printf("Repository %s added (%s)", r.spec.ID, r.describe())
+		r.open()
+		c.Header("Location", "/api/repos/"+r.spec.ID)
+		c.JSON(http.StatusAccepted, r.info())
+	}
+}
+
+// handleRemoveRepo stops hosting a repository, cancelling its reloads and closing its event streams.
+// Uploaded repositories can always be removed, configured ones only with -manage-repos. The default
+// repository is never removed, since the unprefixed /api routes would silently move to the next one.
+func handleRemoveRepo(admin repoAdmin) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := repos.get(c.Param("id"))
+		if r == nil {
+			c.JSON(http.StatusNotFound, analyzer.ErrorResponse{Error: "unknown repository " + c.Param("id")})
+			return
+		}
+		if r.tempDir == "" && !admin.enabled {
+			c.JSON(http.StatusForbidden, analyzer.ErrorResponse{Error: "removing repositories is disabled; start the server with -manage-repos"})
+			return
+		}
+		if err := repos.removeUnlessDefault(r); err != nil {
+			c.JSON(http.StatusConflict, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		info := r.info()
+		info.Default = false
+		r.close()
+		log.Printf("Repository %s removed", r.spec.ID)
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+// describe names what the repository serves, for logs
+func (r *repo) describe() string {
+	if r.readOnly() {
+		return fmt.Sprintf("%d snapshot file(s)", len(r.spec.SnapshotFiles))
+	}
+	return r.spec.Path
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRepoAdminAllows(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"src/billing", "src2", "outside"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "outside"), filepath.Join(base, "src", "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "src", "billing"), filepath.Join(base, "outside", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		roots []string
+		path  string
+		want  bool
+	}{
+		{"no roots allow any path", nil, filepath.Join(base, "outside"), true},
+		{"inside a root", []string{"src"}, filepath.Join(base, "src", "billing"), true},
+		{"the root itself", []string{"src"}, filepath.Join(base, "src"), true},
+		{"sibling sharing the root's prefix", []string{"src"}, filepath.Join(base, "src2"), false},
+		{"parent traversal", []string{"src"}, filepath.Join(base, "src", "..", "outside"), false},
+		{"symlink out of a root", []string{"src"}, filepath.Join(base, "src", "escape"), false},
+		{"symlink into a root", []string{"src"}, filepath.Join(base, "outside", "link"), true},
+		{"missing path", []string{"src"}, filepath.Join(base, "src", "missing"), false},
+		{"second root", []string{"src", "outside"}, filepath.Join(base, "outside"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var roots []string
+			for _, root := range tt.roots {
+				roots = append(roots, filepath.Join(base, root))
+			}
+			admin, err := newRepoAdmin(true, roots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := admin.allows(tt.path); got != tt.want {
+				t.Errorf("allows(%s) = %t, want %t", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// testRepo registers a repository over dir without loading it and removes it when the test ends
+func testRepo(t *testing.T, id, dir string) *repo {
+	t.Helper()
+	r, err := newRepo(analyzer.RepoSpec{ID: id, Path: dir, Snapshot: snapshotIgnore}, ".", loadOptions{noCache: true}, analyzer.WatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.add(r); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if repos.remove(id) != nil {
+			r.close()
+		}
+	})
+	return r
+}
+
+func TestRepoManagement(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	base := t.TempDir()
+	allowed, other := filepath.Join(base, "allowed"), filepath.Join(base, "other")
+	for _, dir := range []string{allowed, other} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testRepo(t, "first", other)
+	testRepo(t, "configured", other)
+	uploaded := testRepo(t, "uploaded", other)
+	uploaded.tempDir = filepath.Join(base, "upload")
+	if err := os.MkdirAll(uploaded.tempDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	enabled, err := newRepoAdmin(true, []string{allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled := repoAdmin{}
+
+	tests := []struct {
+		name        string
+		admin       repoAdmin
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"add disabled by default", disabled, http.MethodPost, "/api/repos", "application/json", `{"id":"new","path":"` + allowed + `"}`, http.StatusForbidden},
+		{"add without a JSON content type", enabled, http.MethodPost, "/api/repos", "text/plain", `{"id":"new","path":"` + allowed + `"}`, http.StatusUnsupportedMediaType},
+		{"add outside the roots", enabled, http.MethodPost, "/api/repos", "application/json", `{"id":"new","path":"` + other + `"}`, http.StatusForbidden},
+		{"add snapshot files outside the roots", enabled, http.MethodPost, "/api/repos", "application/json", `{"id":"new","snapshotFiles":["` + filepath.Join(other, "go.mod") + `"]}`, http.StatusForbidden},
+		{"add inside the roots", enabled, http.MethodPost, "/api/repos", "application/json; charset=utf-8", `{"id":"new","path":"` + allowed + `","snapshot":"ignore"}`, http.StatusAccepted},
+		{"remove a configured repository while disabled", disabled, http.MethodDelete, "/api/repos/configured", "", "", http.StatusForbidden},
+		{"remove the default repository", enabled, http.MethodDelete, "/api/repos/first", "", "", http.StatusConflict},
+		{"remove an upload while disabled", disabled, http.MethodDelete, "/api/repos/uploaded", "", "", http.StatusOK},
+		{"remove a configured repository", enabled, http.MethodDelete, "/api/repos/configured", "", "", http.StatusOK},
+		{"remove an unknown repository", enabled, http.MethodDelete, "/api/repos/configured", "", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/repos", handleAddRepo(analyzer.RepoSpec{}, loadOptions{noCache: true}, analyzer.WatchOptions{}, tt.admin))
+			router.DELETE("/api/repos/:id", handleRemoveRepo(tt.admin))
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("%s %s = %d %s, want %d", tt.method, tt.target, w.Code, w.Body, tt.want)
+			}
+			if w.Code == http.StatusAccepted {
+				if r := repos.remove("new"); r != nil {
+					r.close()
+				}
+			}
+		})
+	}
+}
+
+func TestCORSKeepsAdminRoutesSameOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(corsMiddleware())
+	router.Any("/api/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		method    string
+		path      string
+		preflight string // method announced by an OPTIONS preflight
+		allowed   bool
+	}{
+		{method: http.MethodGet, path: "/api/relations", allowed: true},
+		{method: http.MethodHead, path: "/api/repos", allowed: true},
+		{method: http.MethodPost, path: "/api/reload", allowed: true},
+		{method: http.MethodPost, path: "/api/repos/web/impact", allowed: true},
+		{method: http.MethodDelete, path: "/api/repos/web/jobs/job-1", allowed: true},
+		{method: http.MethodOptions, path: "/api/ref", preflight: http.MethodPost, allowed: true},
+		{method: http.MethodGet, path: "/api/repos/web", allowed: true},
+		{method: http.MethodPost, path: "/api/repos"},
+		{method: http.MethodDelete, path: "/api/repos/web"},
+		{method: http.MethodPost, path: "/api/analyze"},
+		{method: http.MethodOptions, path: "/api/repos", preflight: http.MethodPost},
+		{method: http.MethodOptions, path: "/api/repos/web", preflight: http.MethodDelete},
+		{method: http.MethodOptions, path: "/api/analyze", preflight: http.MethodPost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path+" "+tt.preflight, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", "https://other.example")
+			if tt.preflight != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.preflight)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if got := w.Header().Get("Access-Control-Allow-Origin") == "*"; got != tt.allowed {
+				t.Errorf("cross-origin allowed = %t, want %t", got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestLoadSnapshotFilesNamesLegacyFiles(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "legacy.json")
+	if err := os.WriteFile(legacy, []byte(`[{"name":"a.A","line":1,"filePath":"a.go","called":null}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	current := filepath.Join(dir, "functionmap.json")
+	snapshot, err := analyzer.NewSnapshot(dir, []analyzer.OutRelation{{Name: "b.B", Line: 1, FilePath: "b.go"}}, 1, analyzer.SnapshotFlags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzer.WriteSnapshot(current, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		files  []string
+		legacy bool
+	}{
+		{"current only", []string{current}, false},
+		{"with a legacy file", []string{current, legacy}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRepo(analyzer.RepoSpec{ID: "files", SnapshotFiles: tt.files}, ".", loadOptions{}, analyzer.WatchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.load(context.Background(), nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(r.data.source.Reason, legacy); got != tt.legacy {
+				t.Errorf("reason %q names %s: %t, want %t", r.data.source.Reason, legacy, got, tt.legacy)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/gin-gonic/gin"
+)
+
+// uploadLimits bound what POST /api/analyze accepts
+type uploadLimits struct {
+	enabled       bool  // -allow-uploads
+	archiveBytes  int64 // compressed request body
+	unpackedBytes int64 // total size of the unpacked files
+	repos         int   // uploaded repositories hosted at once; 0 disables uploads
+}
+
+// uploadMu serializes the upload slot check with the registration that fills the slot
+var uploadMu sync.Mutex
+
+// uploadCount is the number of hosted repositories created by POST /api/analyze
+func uploadCount() int {
+	n := 0
+	for _, r := range repos.list() {
+		if r.tempDir != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// handleAnalyze unpacks an uploaded zip or tar.gz of a Go module into a private temporary directory
+// and hosts it as a new repository, analyzed in the background like one added by POST /api/repos.
+// The archive is the raw request body or the "archive" field of a multipart form. Query params: id
+// (default upload-N), wait (hold the response until the analysis has finished). Uploads are off unless
+// the server runs with -allow-uploads. External calls are never included: the uploaded go.mod would
+// decide which module cache directories of the host are read.
+func handleAnalyze(limits uploadLimits, defaults analyzer.RepoSpec, base loadOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limits.enabled || limits.repos <= 0 {
+			c.JSON(http.StatusForbidden, analyzer.ErrorResponse{Error: "archive uploads are disabled; start the server with -allow-uploads"})
+			return
+		}
+		if strings.EqualFold(c.Query("includeExternal"), "true") {
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: "includeExternal is not available for uploads"})
+			return
+		}
+
+		dir, err := os.MkdirTemp("", "gomindmapper-upload-")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		registered := false
+		defer func() {
+			if !registered {
+				os.RemoveAll(dir)
+			}
+		}()
+
+		archive := filepath.Join(dir, "archive")
+		if err := saveUpload(c, archive, limits.archiveBytes); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, analyzer.ErrorResponse{Error: fmt.Sprintf("archive exceeds %d bytes", limits.archiveBytes)})
+				return
+			}
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		src := filepath.Join(dir, "src")
+		if err := analyzer.ExtractArchive(archive, src, limits.unpackedBytes); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, analyzer.ErrArchiveTooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.JSON(status, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		os.Remove(archive)
+		root, err := moduleRoot(src)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		spec := uploadSpec(defaults, c.Query("id"), root)
+		// Every upload is a new tree: per-file cache entries would never be reused
+		opts := base
+		opts.noCache = true
+
+		uploadMu.Lock()
+		if uploadCount() >= limits.repos {
+			uploadMu.Unlock()
+			c.JSON(http.StatusConflict, analyzer.ErrorResponse{Error: fmt.Sprintf("%d uploaded repositories are hosted already; DELETE one first", limits.repos)})
+			return
+		}
+		if spec.ID == "" {
+			spec.ID = nextUploadID()
+		}
+		r, err := newRepo(spec, dir, opts, analyzer.WatchOptions{})
+		if err == nil {
+			r.tempDir = dir
+			err = repos.add(r)
+		}
+		uploadMu.Unlock()
+		if err != nil {
+			status := http.StatusBadRequest
+			if repos.get(spec.ID) != nil {
+				status = http.StatusConflict
+			}
+			c.JSON(status, analyzer.ErrorResponse{Error: err.Error()})
+			return
+		}
+		registered = true
+
+		log.Printf("Repository %s added from an uploaded archive (%s)", r.spec.ID, r.spec.Path)
+		job := r.open()
+		c.Header("Location", "/api/repos/"+r.spec.ID)
+		if c.Query("wait") != "true" {
+			c.JSON(http.StatusAccepted, r.info())
+			return
+		}
+		select {
+		case <-job.done:
+		case <-c.Request.Context().Done():
+			return
+		}
+		if state := job.snapshot(); state.Status != analyzer.JobSucceeded {
+			// Nothing worth keeping: drop the repository so it does not hold an upload slot
+			repos.remove(r.spec.ID)
+			r.close()
+			c.JSON(http.StatusUnprocessableEntity, analyzer.ErrorResponse{Error: "analysis failed: " + state.Error})
+			return
+		}
+		c.JSON(http.StatusOK, r.info())
+	}
+}
+
+// nextUploadID returns the first free upload-N ID; uploadMu must be held
+func nextUploadID() string {
+	for n := 1; ; n++ {
+		id := fmt.Sprintf("upload-%d", n)
+		if repos.get(id) == nil {
+			return id
+		}
+	}
+}
+
+// saveUpload writes the archive from the request body, or from its "archive" multipart field, to path
+func saveUpload(c *gin.Context, path string, limit int64) error {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	var archive io.Reader = body
+	if mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type")); err == nil && mediaType == "multipart/form-data" {
+		parts, err := multipartArchive(body, params["boundary"])
+		if err != nil {
+			return err
+		}
+		archive = parts
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, archive)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n == 0 {
+		err = errors.New("empty archive: send a zip or tar.gz as the request body or the \"archive\" form field")
+	}
+	return err
+}
+
+// multipartArchive returns the content of the "archive" field of a multipart body
+func multipartArchive(body io.Reader, boundary string) (io.Reader, error) {
+	form := multipart.NewReader(body, boundary)
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("multipart form has no \"archive\" field")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "archive" {
+			return part, nil
+		}
+	}
+}
+
+// moduleRoot finds the go.mod of an unpacked archive: at its root, or inside its only top-level
+// directory as in archives of a repository snapshot
+func moduleRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		nested := filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(nested, "go.mod")); err == nil {
+			return nested, nil
+		}
+	}
+	return "", errors.New("no go.mod at the archive root or in its single top-level directory")
+}
+
+// uploadSpec returns the settings of an uploaded repository rooted at root. Snapshots shipped in the
+// archive are never trusted, so the upload is always analyzed from its sources, and nothing is watched.
+// External calls are left out whatever -include-external says, so no dependency source is read.
+func uploadSpec(defaults analyzer.RepoSpec, id, root string) analyzer.RepoSpec {
+	spec := defaults
+	spec.ID, spec.Path, spec.SnapshotFiles, spec.Ref = id, root, nil, ""
+	spec.Snapshot, spec.Watch = snapshotIgnore, false
+	spec.IncludeExternal, spec.SkipFolders = false, nil
+	return spec
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUploadSpecNeverTrustsSnapshots(t *testing.T) {
+	defaults := analyzer.RepoSpec{
+		Snapshot:        snapshotPrefer,
+		Watch:           true,
+		SnapshotFiles:   []string{"/srv/functionmap.bin"},
+		Ref:             "main",
+		IncludeExternal: true,
+		SkipFolders:     []string{"golang.org"},
+	}
+	spec := uploadSpec(defaults, "upload-1", "/tmp/src")
+	if spec.Snapshot != snapshotIgnore {
+		t.Errorf("Snapshot = %q, want %q", spec.Snapshot, snapshotIgnore)
+	}
+	if spec.Watch || spec.SnapshotFiles != nil || spec.Ref != "" {
+		t.Errorf("Watch, SnapshotFiles, Ref = %t, %v, %q; want false, nil, empty", spec.Watch, spec.SnapshotFiles, spec.Ref)
+	}
+	if spec.IncludeExternal {
+		t.Error("IncludeExternal = true, want false whatever the server default")
+	}
+	if spec.ID != "upload-1" || spec.Path != "/tmp/src" {
+		t.Errorf("spec = %+v", spec)
+	}
+}
+
+func TestHandleAnalyzeRefusals(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := uploadLimits{enabled: true, archiveBytes: 1 << 20, unpackedBytes: 1 << 20, repos: 1}
+	tests := []struct {
+		name   string
+		limits uploadLimits
+		query  string
+		status int
+	}{
+		{"off by default", uploadLimits{archiveBytes: 1 << 20, unpackedBytes: 1 << 20, repos: 10}, "", http.StatusForbidden},
+		{"no upload slots", uploadLimits{enabled: true, repos: 0}, "", http.StatusForbidden},
+		{"external calls", enabled, "?includeExternal=true", http.StatusBadRequest},
+		{"empty archive", enabled, "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/analyze", handleAnalyze(tt.limits, analyzer.RepoSpec{}, loadOptions{}))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/analyze"+tt.query, strings.NewReader("")))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
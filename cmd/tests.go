@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+)
+
+// runTests prints the tests that statically reach a change, as go test commands or as JSON.
+// Usage: tests [-path dir] [-base ref] [-head ref] [-patch file] [-json] [analysis flags] [file.go | package.Function ...]
+// The change is the git diff from -base to -head (the working tree by default) or a patch, like impact,
+// and/or the files and functions given as arguments; a file argument counts as changed in full.
+func runTests(args []string) int {
+	fs := flag.NewFlagSet("tests", flag.ExitOnError)
+	path := fs.String("path", ".", "repository the change applies to; the go test commands run from here")
+	base := fs.String("base", "", "commit, branch or tag the change starts from")
+	head := fs.String("head", "", "commit, branch or tag the change ends at (default: the working tree)")
+	patchFile := fs.String("patch", "", "unified diff to analyze instead of base..head (- reads stdin)")
+	asJSON := fs.Bool("json", false, "print the selection as JSON (schema tests) instead of go test commands")
+	var settings analysisSettings
+	settings.register(fs)
+	fs.Parse(args)
+
+	if *base == "" && *patchFile == "" && fs.NArg() == 0 {
+		fmt.Println("usage: tests [-path dir] [-base ref [-head ref] | -patch file] [-json] [file.go | package.Function ...]")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	absPath, err := filepath.Abs(*path)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// Analysis progress goes to stderr: stdout holds only the commands or the JSON
+	settings.progress = os.Stderr
+	selection, err := selectTests(ctx, absPath, *base, *head, *patchFile, fs.Args(), settings)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(selection); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	}
+	if err := selection.WriteCommands(os.Stdout); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// selectTests gathers the change, walks its callers in the analysis of head and matches the tests of head
+func selectTests(ctx context.Context, absPath, base, head, patchFile string, operands []string, settings analysisSettings) (analyzer.TestSelection, error) {
+	diff := change{readNew: analyzer.DirReader(absPath)}
+	if base != "" || patchFile != "" {
+		var err error
+		if diff, err = readChange(ctx, absPath, base, head, patchFile); err != nil {
+			return analyzer.TestSelection{}, err
+		}
+	}
+
+	var named []analyzer.ChangedFunction
+	for _, operand := range operands {
+		if !strings.HasSuffix(operand, ".go") {
+			named = append(named, analyzer.ChangedFunction{Name: operand, Change: analyzer.ChangeModified})
+			continue
+		}
+		abs, err := filepath.Abs(operand)
+		if err != nil {
+			return analyzer.TestSelection{}, err
+		}
+		rel, err := filepath.Rel(absPath, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return analyzer.TestSelection{}, fmt.Errorf("%s is outside %s", operand, absPath)
+		}
+		rel = filepath.ToSlash(rel)
+		diff.files = append(diff.files, analyzer.FileChange{OldPath: rel, NewPath: rel, NewLines: []analyzer.LineRange{{Start: 1, End: math.MaxInt32}}})
+	}
+
+	changed, err := analyzer.ChangedFunctions(diff.files, diff.readNew, diff.readOld)
+	if err != nil {
+		return analyzer.TestSelection{}, err
+	}
+	changed = append(changed, named...)
+
+	// Tests are read from the same revision as the call graph, checked out once for both
+	testRoot, tree := absPath, ""
+	if head != "" {
+		commit, err := analyzer.ResolveGitRef(ctx, absPath, head)
+		if err != nil {
+			return analyzer.TestSelection{}, err
+		}
+		tmp, err := os.MkdirTemp("", "gomindmapper-ref-")
+		if err != nil {
+			return analyzer.TestSelection{}, err
+		}
+		defer os.RemoveAll(tmp)
+		if err := analyzer.CheckoutGitRef(ctx, absPath, commit, tmp); err != nil {
+			return analyzer.TestSelection{}, err
+		}
+		testRoot, tree = tmp, tmp
+	}
+	snapshot, err := analyzeCheckout(ctx, absPath, head, tree, settings)
+	if err != nil {
+		return analyzer.TestSelection{}, err
+	}
+	module, err := analyzer.GetModule(testRoot)
+	if err != nil {
+		return analyzer.TestSelection{}, err
+	}
+	index, err := analyzer.FindTests(ctx, testRoot, module)
+	if err != nil {
+		return analyzer.TestSelection{}, err
+	}
+
+	report, err := impactOf(ctx, testRoot, "", snapshot, changed)
+	if err != nil {
+		return analyzer.TestSelection{}, err
+	}
+	return analyzer.SelectTests(index, report, diff.files), nil
+}
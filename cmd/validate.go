@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chinmay-sawant/gomindmapper/cmd/analyzer"
+)
+
+// runValidate checks output files against their published JSON Schemas.
+// Usage: validate [-kind name] file...  (kind is inferred from the file name when omitted)
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	kind := fs.String("kind", "", "artifact schema to validate against: "+strings.Join(analyzer.SchemaArtifactNames(), ", "))
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Println("usage: validate [-kind name] file...")
+		return 2
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println(err)
+			status = 1
+			continue
+		}
+
+		name := *kind
+		if name == "" {
+			detected, ok := analyzer.DetectArtifact(path, data)
+			if !ok {
+				fmt.Printf("%s: cannot infer artifact kind, pass -kind\n", path)
+				status = 1
+				continue
+			}
+			name = detected
+		}
+
+		violations, err := analyzer.ValidateArtifact(name, data)
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			status = 1
+			continue
+		}
+		if len(violations) > 0 {
+			fmt.Printf("%s: INVALID against %s schema (%d violations)\n", path, name, len(violations))
+			for _, v := range violations {
+				fmt.Printf("  %s\n", v)
+			}
+			status = 1
+			continue
+		}
+		fmt.Printf("%s: valid %s\n", path, name)
+	}
+	return status
+}
+
+// runSchema writes the published JSON Schemas generated from the Go types.
+// Usage: schema [-out dir] [name]  (prints a single schema to stdout when name is given)
+func runSchema(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	out := fs.String("out", "schemas", "directory to write <name>.schema.json files to")
+	fs.Parse(args)
+
+	if fs.NArg() == 1 {
+		artifact, ok := analyzer.FindSchemaArtifact(fs.Arg(0))
+		if !ok {
+			fmt.Printf("unknown artifact %q (known: %s)\n", fs.Arg(0), strings.Join(analyzer.SchemaArtifactNames(), ", "))
+			return 2
+		}
+		data, err := artifact.MarshalSchema()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		os.Stdout.Write(data)
+		return 0
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	for _, artifact := range analyzer.SchemaArtifacts {
+		data, err := artifact.MarshalSchema()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		path := filepath.Join(*out, artifact.Name+".schema.json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return 0
+}
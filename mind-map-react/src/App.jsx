@@ -100,6 +100,30 @@ const defaultEmployeeAppData = [
   }
 ];
 
+// API root; ?repo=<id> views one repository of a multi-repository server instead of its default one
+const repoParam = new URLSearchParams(window.location.search).get('repo');
+const apiBase = `${window.location.origin}/api${repoParam ? `/repos/${encodeURIComponent(repoParam)}` : ''}`;
+
+// Share of a reload job's progress bar reached at the start of each analysis phase
+const reloadPhaseStart = {
+  walking: 0,
+  parsing: 5,
+  'external-scan': 70,
+  'type-resolution': 85,
+  'building-relations': 92,
+};
+
+// reloadPercent estimates how far a reload job has got; parsing advances with the file count
+function reloadPercent(job) {
+  if (!job || job.status === 'queued') return 0;
+  if (job.status !== 'running') return 100;
+  const start = reloadPhaseStart[job.phase] ?? 0;
+  if (job.phase === 'parsing' && job.filesTotal > 0) {
+    return start + (reloadPhaseStart['external-scan'] - start) * (job.filesParsed / job.filesTotal);
+  }
+  return start;
+}
+
 function App() {
   return (
     <Routes>
@@ -130,6 +154,9 @@ function MindMapApp() {
   const [fullLocalData, setFullLocalData] = useState(null);
   const [useLocalPagination, setUseLocalPagination] = useState(false);
   const [localSearchResults, setLocalSearchResults] = useState([]);
+  const [reloadJob, setReloadJob] = useState(null);
+  // Snapshot-file servers have no source tree to rescan
+  const [readOnly, setReadOnly] = useState(false);
   const appRef = useRef(null);
   const searchTimeoutRef = useRef(null);
   const searchInputRef = useRef(null);
@@ -253,8 +280,8 @@ function MindMapApp() {
     setServerError('');
     try {
       const url = query 
-        ? `${window.location.origin}/api/search?q=${encodeURIComponent(query)}&page=${p}&pageSize=${ps}`
-        : `${window.location.origin}/api/relations?page=${p}&pageSize=${ps}`;
+        ? `${apiBase}/search?q=${encodeURIComponent(query)}&page=${p}&pageSize=${ps}`
+        : `${apiBase}/relations?page=${p}&pageSize=${ps}`;
       const res = await fetch(url);
       if (!res.ok) throw new Error(`HTTP ${res.status}`);
       const json = await res.json();
@@ -269,6 +296,7 @@ function MindMapApp() {
       }
       setPage(json.page || p);
       setPageSize(json.pageSize || ps);
+      setReadOnly(json.source?.kind === 'snapshot-file');
       const label = query 
         ? `Search: "${query}" (${json.totalResults || 0} matches, page ${json.page})` 
         : `Server Roots Page ${json.page}`;
@@ -288,6 +316,44 @@ function MindMapApp() {
     }
   }, [useServer, useLocalPagination, page, pageSize, fetchPage, searchQuery]);
 
+  // Refetch the current page or search when the server reports changed relations
+  // (watch mode or a reload from another client)
+  const viewRef = useRef({ page, pageSize, searchQuery });
+  viewRef.current = { page, pageSize, searchQuery };
+  useEffect(() => {
+    if (!useServer || typeof EventSource === 'undefined') return;
+    const source = new EventSource(`${apiBase}/events`);
+    source.addEventListener('change', () => {
+      const { page: p, pageSize: ps, searchQuery: q } = viewRef.current;
+      fetchPage(p, ps, (q || '').trim());
+    });
+    return () => source.close();
+  }, [useServer, fetchPage]);
+
+  // Start a reload job and poll it until it finishes, so the progress bar can follow its phases
+  const startReload = useCallback(async () => {
+    setServerError('');
+    try {
+      const res = await fetch(`${apiBase}/reload`, { method: 'POST' });
+      if (!res.ok) throw new Error(`HTTP ${res.status}`);
+      let job = await res.json();
+      setReloadJob(job);
+      while (job.status === 'queued' || job.status === 'running') {
+        await new Promise(resolve => setTimeout(resolve, 500));
+        const poll = await fetch(`${apiBase}/jobs/${job.id}`);
+        if (!poll.ok) throw new Error(`HTTP ${poll.status}`);
+        job = await poll.json();
+        setReloadJob(job);
+      }
+      if (job.status !== 'succeeded') throw new Error(`Reload ${job.status}${job.error ? `: ${job.error}` : ''}`);
+      await fetchPage(1, pageSize);
+    } catch (e) {
+      setServerError(e.message);
+    } finally {
+      setReloadJob(null);
+    }
+  }, [fetchPage, pageSize]);
+
   // Clear search when switching off server mode or local pagination
   useEffect(() => {
     if (!useServer && !useLocalPagination) {
@@ -353,7 +419,9 @@ function MindMapApp() {
     const reader = new FileReader();
     reader.onload = (e) => {
       try {
-        const jsonData = JSON.parse(e.target.result);
+        const parsed = JSON.parse(e.target.result);
+        // functionmap.json v2+ wraps relations in { header, relations }; v1 is a bare array
+        const jsonData = Array.isArray(parsed) ? parsed : (parsed.relations || []);
         
         // Check if the data is large enough to warrant pagination
         const rootNodes = getRootNodes(jsonData);
@@ -477,7 +545,7 @@ function MindMapApp() {
 
   return (
     <div className="App" ref={appRef}>
-      <Navbar onReload={useServer ? () => fetch(`${window.location.origin}/api/reload`, {method:'POST'}).then(()=>fetchPage(1,pageSize)) : null} onDownload={useServer ? `${window.location.origin}/api/download` : null} />
+      <Navbar onReload={useServer && !readOnly && !reloadJob ? startReload : null} onDownload={useServer ? `${apiBase}/download` : null} />
       <header className="app-header">
         <h1>Function Mind Map</h1>
         <div className="header-content">
@@ -660,6 +728,18 @@ function MindMapApp() {
       <main className="app-main">
         {(useServer || useLocalPagination) && loading && <div className="loading-indicator">Loading...</div>}
         {useServer && serverError && <div className="error-indicator">Error: {serverError}</div>}
+        {useServer && reloadJob && (
+          <div className="reload-progress">
+            <div className="reload-progress-label">
+              Reload {reloadJob.status}
+              {reloadJob.phase && ` · ${reloadJob.phase}`}
+              {reloadJob.filesTotal > 0 && ` · ${reloadJob.filesParsed}/${reloadJob.filesTotal} files`}
+            </div>
+            <div className="reload-progress-track">
+              <div className="reload-progress-bar" style={{ width: `${reloadPercent(reloadJob)}%` }} />
+            </div>
+          </div>
+        )}
         <MindMap 
           data={functionData} 
           selectedNode={selectedNode}
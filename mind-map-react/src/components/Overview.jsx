@@ -105,7 +105,7 @@ const Overview = () => {
             <div className="primary-card card">
               <h3>Run the application (single command)</h3>
               <p className="lead muted">Run the server against a target repository/subfolder — this example uses <code>gopdfsuit</code>:</p>
-              <pre className="cmd"><code>go run cmd/server/main.go -path gopdfsuit -addr :8080 --include-external=true --skip-folders="golang.org,gin-gonic,bytedance,ugorji,go-playground"</code></pre>
+              <pre className="cmd"><code>go run ./cmd/server -path gopdfsuit -addr :8080 --include-external=true --skip-folders="golang.org,gin-gonic,bytedance,ugorji,go-playground"</code></pre>
               <div className="flag-list">
                 <strong>Flags</strong>
                 <ul>
@@ -122,7 +122,7 @@ const Overview = () => {
               <div className="card">
                 <h4>Build & Run (production)</h4>
                 <pre><code>cd &lt;repo-root&gt; <br/>
-go run cmd/server/main.go -path . -addr :8080</code></pre>
+go run ./cmd/server -path . -addr :8080</code></pre>
                 <p className="muted">Starts the Go server which serves the Overview at <code>/gomindmapper/</code> and the app at <code>/gomindmapper/view/</code>.</p>
               </div>
 
@@ -143,7 +143,7 @@ npm run dev <br/>
 
 // Terminal 2 <br/>
 cd &lt;repo-root&gt; <br/>
-go run cmd/server/main.go -path . -addr :8080</code></pre>
+go run ./cmd/server -path . -addr :8080</code></pre>
                 <p className="muted">Use Vite dev server for UI hot-reload while the Go server provides live data. Open <code>http://localhost:5173/gomindmapper/view</code>.</p>
               </div>
 